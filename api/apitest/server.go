@@ -0,0 +1,113 @@
+// Package apitest provides a fake Xandr API server with authentication for testing service clients.
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Server is a fake API server. It serves /auth and handlers registered with Handle.
+// Handlers are called only for requests with a valid token.
+type Server struct {
+	*httptest.Server
+
+	mux      *http.ServeMux
+	mu       sync.Mutex
+	tokens   map[string]bool
+	password string
+	logins   int
+}
+
+// NewServer starts fake API server which accepts any username with the password.
+func NewServer(password string) *Server {
+	s := &Server{
+		mux:      http.NewServeMux(),
+		tokens:   make(map[string]bool),
+		password: password,
+	}
+
+	s.mux.HandleFunc("/auth", s.handleAuth)
+	s.Server = httptest.NewServer(s.mux)
+
+	return s
+}
+
+// Handle registers handler for the path.
+func (s *Server) Handle(path string, h http.HandlerFunc) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		ok := s.tokens[r.Header.Get("Authorization")]
+		s.mu.Unlock()
+
+		if !ok {
+			WriteError(w, http.StatusUnauthorized, "NOAUTH", "Authentication failed - not logged in")
+			return
+		}
+
+		h(w, r)
+	})
+}
+
+// ExpireTokens invalidates all issued tokens.
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = make(map[string]bool)
+}
+
+// Logins returns number of successful logins.
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Auth struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auth"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "SYNTAX", err.Error())
+		return
+	}
+
+	if req.Auth.Username == "" || req.Auth.Password != s.password {
+		WriteError(w, http.StatusUnauthorized, "UNAUTH", "No match found for user/pass")
+		return
+	}
+
+	s.mu.Lock()
+	s.logins++
+	token := fmt.Sprintf("token-%d", s.logins)
+	s.tokens[token] = true
+	s.mu.Unlock()
+
+	WriteResponse(w, map[string]interface{}{"token": token})
+}
+
+// WriteResponse writes {"response": resp} with status OK.
+func WriteResponse(w http.ResponseWriter, resp map[string]interface{}) {
+	resp["status"] = "OK"
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"response": resp})
+}
+
+// WriteError writes API error response.
+func WriteError(w http.ResponseWriter, code int, id, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"response": map[string]interface{}{
+			"status":   "error",
+			"error_id": id,
+			"error":    msg,
+		},
+	})
+}
@@ -0,0 +1,284 @@
+// Package api implements authentication and request helpers for Xandr API services
+// described on https://learn.microsoft.com/en-us/xandr/digital-platform-api/api-semantics
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const DefaultBaseURL = "https://api.appnexus.com"
+
+// maxErrorSize limits error response bodies buffered by DoRaw.
+const maxErrorSize = 64 * 1024
+
+// Error is returned when API responds with error status.
+type Error struct {
+	StatusCode int
+	ID         string // error_id, e.g. NOAUTH, SYNTAX, UNAUTH
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api error %d %s: %s", e.StatusCode, e.ID, e.Message)
+}
+
+// Session keeps authentication token shared by service clients.
+type Session struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	token    string
+	username string
+	password string
+}
+
+// NewSession creates session for the API at baseURL. Empty baseURL means DefaultBaseURL.
+func NewSession(baseURL string) *Session {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Session{
+		BaseURL:    baseURL,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Login authenticates the session. Credentials are kept to re-authenticate when the token expires.
+func (s *Session) Login(ctx context.Context, username, password string) error {
+	s.mu.Lock()
+	s.username = username
+	s.password = password
+	s.mu.Unlock()
+
+	return s.login(ctx)
+}
+
+func (s *Session) login(ctx context.Context) error {
+	s.mu.Lock()
+	req := map[string]interface{}{
+		"auth": map[string]string{
+			"username": s.username,
+			"password": s.password,
+		},
+	}
+	s.mu.Unlock()
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+
+	if err := s.do(ctx, http.MethodPost, "/auth", nil, req, &resp, ""); err != nil {
+		return err
+	}
+
+	if resp.Token == "" {
+		return errors.New("auth response has no token")
+	}
+
+	s.mu.Lock()
+	s.token = resp.Token
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Token returns current authentication token.
+func (s *Session) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// Do sends request to the service path and decodes "response" object of the reply into resp.
+// If the token has expired, the session logs in again and repeats the request once.
+func (s *Session) Do(ctx context.Context, method, path string, query url.Values, body, resp interface{}) error {
+	err := s.do(ctx, method, path, query, body, resp, s.Token())
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.ID != "NOAUTH" {
+		return err
+	}
+
+	ok, lerr := s.relogin(ctx)
+	if lerr != nil {
+		return lerr
+	}
+	if !ok {
+		return err
+	}
+
+	return s.do(ctx, method, path, query, body, resp, s.Token())
+}
+
+// relogin logs in again if the session has credentials. It returns false if it has not.
+func (s *Session) relogin(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	canLogin := s.username != ""
+	s.mu.Unlock()
+
+	if !canLogin {
+		return false, nil
+	}
+
+	return true, s.login(ctx)
+}
+
+// DoRaw sends authenticated request to the path or absolute URL and returns the http response as is.
+// The caller should close the response body. If the token has expired, the session logs in again
+// and repeats the request once. Requests with a body are repeated only if http.NewRequest can
+// rewind it, e.g. for bytes.Reader; otherwise the NOAUTH response is returned.
+func (s *Session) DoRaw(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Response, error) {
+	u := s.resolve(path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.doRaw(req)
+	if err != nil || !noAuth(res) || (req.Body != nil && req.GetBody == nil) {
+		return res, err
+	}
+
+	ok, err := s.relogin(ctx)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	if !ok {
+		return res, nil
+	}
+
+	res.Body.Close()
+
+	retry := req.Clone(ctx)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.doRaw(retry)
+}
+
+func (s *Session) doRaw(req *http.Request) (*http.Response, error) {
+	if token := s.Token(); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	return s.HTTPClient.Do(req)
+}
+
+// noAuth reports whether the response is a NOAUTH error. The body of an error response
+// is buffered and remains readable.
+func noAuth(res *http.Response) bool {
+	if res.StatusCode < 400 {
+		return false
+	}
+
+	buf, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorSize))
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), res.Body), res.Body}
+
+	var envelope struct {
+		Response struct {
+			ErrorID string `json:"error_id"`
+		} `json:"response"`
+	}
+
+	json.Unmarshal(buf, &envelope)
+
+	return envelope.Response.ErrorID == "NOAUTH"
+}
+
+// resolve makes absolute URL from a service path.
+func (s *Session) resolve(path string) string {
+	u, err := url.Parse(path)
+	if err != nil || u.IsAbs() {
+		return path
+	}
+
+	base, err := url.Parse(s.BaseURL)
+	if err != nil {
+		return path
+	}
+
+	return base.ResolveReference(u).String()
+}
+
+func (s *Session) do(ctx context.Context, method, path string, query url.Values, body, resp interface{}, token string) error {
+	u := s.resolve(path)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var r io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	res, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var envelope struct {
+		Response json.RawMessage `json:"response"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("%s %s: status %d: cannot decode response: %w", method, path, res.StatusCode, err)
+	}
+
+	var status struct {
+		Status  string `json:"status"`
+		ErrorID string `json:"error_id"`
+		Error   string `json:"error"`
+	}
+
+	if err := json.Unmarshal(envelope.Response, &status); err != nil {
+		return fmt.Errorf("%s %s: cannot decode response: %w", method, path, err)
+	}
+
+	if res.StatusCode >= 300 || status.ErrorID != "" || status.Status == "error" {
+		return &Error{StatusCode: res.StatusCode, ID: status.ErrorID, Message: status.Error}
+	}
+
+	if resp == nil {
+		return nil
+	}
+
+	return json.Unmarshal(envelope.Response, resp)
+}
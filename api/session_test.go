@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/api/apitest"
+)
+
+func TestSessionRelogin(t *testing.T) {
+	srv := apitest.NewServer("secret")
+	defer srv.Close()
+
+	srv.Handle("/member", func(w http.ResponseWriter, r *http.Request) {
+		apitest.WriteResponse(w, map[string]interface{}{"member": map[string]interface{}{"id": 7}})
+	})
+
+	s := NewSession(srv.URL)
+	ctx := context.Background()
+
+	if err := s.Login(ctx, "user", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	var resp struct {
+		Member struct {
+			ID int `json:"id"`
+		} `json:"member"`
+	}
+
+	srv.ExpireTokens()
+
+	if err := s.Do(ctx, http.MethodGet, "/member", nil, nil, &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Member.ID != 7 {
+		t.Fatal("invalid member id:", resp.Member.ID)
+	}
+
+	if srv.Logins() != 2 {
+		t.Fatal("session should log in again, logins:", srv.Logins())
+	}
+}
+
+func TestSessionLoginFailed(t *testing.T) {
+	srv := apitest.NewServer("secret")
+	defer srv.Close()
+
+	s := NewSession(srv.URL)
+
+	err := s.Login(context.Background(), "user", "wrong")
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatal("expected api error, got", err)
+	}
+
+	if apiErr.ID != "UNAUTH" || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("invalid error: %+v", apiErr)
+	}
+}
+
+func TestSessionDoRawRelogin(t *testing.T) {
+	srv := apitest.NewServer("secret")
+	defer srv.Close()
+
+	srv.Handle("/download", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(append([]byte("data:"), body...))
+	})
+
+	s := NewSession(srv.URL)
+	ctx := context.Background()
+
+	if err := s.Login(ctx, "user", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.ExpireTokens()
+
+	res, err := s.DoRaw(ctx, http.MethodPost, "/download", nil, strings.NewReader("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusOK || string(data) != "data:abc" {
+		t.Fatalf("unexpected response %d %q", res.StatusCode, data)
+	}
+
+	if srv.Logins() != 2 {
+		t.Fatal("session should log in again, logins:", srv.Logins())
+	}
+
+	// without credentials the NOAUTH response is returned readable
+	s = NewSession(srv.URL)
+
+	res, err = s.DoRaw(ctx, http.MethodGet, "/download", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusUnauthorized || !strings.Contains(string(data), "NOAUTH") {
+		t.Fatalf("unexpected response %d %q", res.StatusCode, data)
+	}
+}
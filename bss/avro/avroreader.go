@@ -0,0 +1,107 @@
+package avro
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// AvroReader reads user records from a file in Xandr BSS avro uploading format.
+// Avro expiration -2 (default) is read as xgen.DefaultExpiration and 0 (max) as xgen.MaxExpiration.
+type AvroReader struct {
+	ocfReader *goavro.OCFReader
+}
+
+// device_id.domain enum symbols from the xandr schema.
+var deviceDomains = map[string]xgen.Domain{
+	"idfa": xgen.IDFA,
+	"aaid": xgen.AAID,
+}
+
+// NewAvroReader creates avro reader for data in Xandr BSS avro uploading format.
+func NewAvroReader(r io.Reader) (*AvroReader, error) {
+	ocfReader, err := goavro.NewOCFReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AvroReader{ocfReader: ocfReader}, nil
+}
+
+// Read returns next user record or io.EOF at the end of the file.
+func (r *AvroReader) Read() (*UserRecord, error) {
+	if !r.ocfReader.Scan() {
+		if err := r.ocfReader.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	datum, err := r.ocfReader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	record, ok := datum.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected record type %T", datum)
+	}
+
+	user := &UserRecord{}
+
+	if err := parseUID(user, record["uid"]); err != nil {
+		return nil, err
+	}
+
+	items, _ := record["segments"].([]interface{})
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("seg[%d]: unexpected type %T", i, item)
+		}
+
+		seg := xgen.Segment{}
+		seg.ID, _ = m["id"].(int32)
+		seg.Code, _ = m["code"].(string)
+		seg.MemberID, _ = m["member_id"].(int32)
+		expiration, _ := m["expiration"].(int32)
+		seg.Expiration = fromAvroExpiration(expiration)
+		seg.Value, _ = m["value"].(int32)
+		seg.Timestamp, _ = m["timestamp"].(int64)
+
+		user.Segments = append(user.Segments, seg)
+	}
+
+	return user, nil
+}
+
+func parseUID(user *UserRecord, v interface{}) error {
+	uid, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("unexpected uid type %T", v)
+	}
+
+	for name, value := range uid {
+		switch name {
+		case "long":
+			n, _ := value.(int64)
+			user.UID = fmt.Sprintf("%d", n)
+			user.Domain = xgen.XandrID
+		case "device_id":
+			m, _ := value.(map[string]interface{})
+			user.UID, _ = m["id"].(string)
+			symbol, _ := m["domain"].(string)
+			domain, ok := deviceDomains[symbol]
+			if !ok {
+				return fmt.Errorf("unsupported device domain: %s", symbol)
+			}
+			user.Domain = domain
+		default:
+			return fmt.Errorf("unsupported uid type: %s", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package avro
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestAvroReader(t *testing.T) {
+	var out bytes.Buffer
+
+	wr, err := NewAvroWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := []*UserRecord{
+		{
+			UID: "12345",
+			Segments: []xgen.Segment{
+				{ID: 100, Expiration: 1440, Value: 123},
+				{Code: "abc", MemberID: 7, Expiration: xgen.Expired},
+			},
+		},
+		{
+			UID:      "12346",
+			Segments: []xgen.Segment{{ID: 101}},
+		},
+	}
+
+	if err := wr.Append(users); err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := NewAvroReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range users {
+		user, err := rd.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, user) {
+			t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, user)
+		}
+	}
+
+	if _, err := rd.Read(); err != io.EOF {
+		t.Fatal("expected EOF, got", err)
+	}
+}
+
+func TestAvroExpiration(t *testing.T) {
+	codec, err := goavro.NewCodec(xandrSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	wr, err := NewAvroWriter(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &UserRecord{UID: "12345", Segments: []xgen.Segment{
+		{ID: 100, Expiration: xgen.DefaultExpiration},
+		{ID: 101, Expiration: xgen.MaxExpiration},
+		{ID: 102, Expiration: xgen.Expired},
+	}}
+
+	if err := wr.Append([]*UserRecord{user}); err != nil {
+		t.Fatal(err)
+	}
+
+	// default expiration is written as -2
+	ocfr, err := goavro.NewOCFReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []int32
+	for ocfr.Scan() {
+		datum, err := ocfr.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, seg := range datum.(map[string]interface{})["segments"].([]interface{}) {
+			actual = append(actual, seg.(map[string]interface{})["expiration"].(int32))
+		}
+	}
+
+	expected := []int32{-2, xgen.MaxExpiration, -1}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, actual)
+	}
+
+	// avro default and max expirations are read as xgen values
+	var raw bytes.Buffer
+
+	ocfw, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &raw, Codec: codec})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ocfw.Append([]interface{}{map[string]interface{}{
+		"uid": map[string]interface{}{"long": int64(12345)},
+		"segments": []interface{}{
+			map[string]interface{}{"id": int32(100), "expiration": int32(-2)},
+			map[string]interface{}{"id": int32(101), "expiration": int32(0)},
+			map[string]interface{}{"id": int32(102), "expiration": int32(1440)},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rd, err := NewAvroReader(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ur, err := rd.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSegments := []xgen.Segment{
+		{ID: 100, Expiration: xgen.DefaultExpiration},
+		{ID: 101, Expiration: xgen.MaxExpiration},
+		{ID: 102, Expiration: 1440},
+	}
+	if !reflect.DeepEqual(ur.Segments, expectedSegments) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expectedSegments, ur.Segments)
+	}
+
+	// expiration out of range cannot be written
+	user.Segments[0].Expiration = -2
+	if err := wr.Append([]*UserRecord{user}); err == nil || err.Error() != "seg[0].Expiration is not in the range [-1, 259200]" {
+		t.Fatal("unexpected error:", err)
+	}
+}
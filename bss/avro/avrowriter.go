@@ -13,6 +13,19 @@ import (
 
 type UserRecord = xgen.UserRecord
 
+// RecordError is returned if a user record cannot be encoded, e.g. it has an invalid UID or segment.
+type RecordError struct {
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
 type AvroWriter struct {
 	ocfWriter *goavro.OCFWriter
 }
@@ -23,6 +36,7 @@ type AvroWriter struct {
 var xandrSchema string
 
 // NewAvroWriter creates avro writer for generating data in Xandr BSS avro uploading format.
+// xgen.DefaultExpiration is written as avro expiration -2, expiration out of [-1, xgen.MaxExpiration] fails.
 func NewAvroWriter(w io.Writer) (*AvroWriter, error) {
 	ocfConfig := goavro.OCFConfig{
 		Schema: xandrSchema,
@@ -56,26 +70,71 @@ func newXandrID(id string) (map[string]interface{}, error) {
 func newDeviceID(id string, domain xgen.Domain) (map[string]interface{}, error) {
 	// TODO: validate UUID
 
+	var symbol string
+	for s, d := range deviceDomains {
+		if d == domain {
+			symbol = s
+		}
+	}
+
+	if symbol == "" {
+		return nil, fmt.Errorf("unsupported device domain: %s", domain)
+	}
+
 	return map[string]interface{}{
 			"device_id": map[string]interface{}{
 				"id":     id,
-				"domain": string(domain),
+				"domain": symbol,
 			},
 		},
 		nil
 }
 
+// Avro expiration values which differ from Segment.Expiration.
+const (
+	avroMaxExpiration     = 0  // max expiration, 180 days
+	avroDefaultExpiration = -2 // member default expiration
+)
+
+// toAvroExpiration converts Segment.Expiration to avro expiration.
+func toAvroExpiration(e int32) (int32, bool) {
+	switch {
+	case e == xgen.DefaultExpiration:
+		return avroDefaultExpiration, true
+	case e < xgen.Expired || e > xgen.MaxExpiration:
+		return 0, false
+	}
+	return e, true
+}
+
+// fromAvroExpiration converts avro expiration to Segment.Expiration. Values out of
+// the avro range are returned unchanged to be reported by validation.
+func fromAvroExpiration(e int32) int32 {
+	switch e {
+	case avroDefaultExpiration:
+		return xgen.DefaultExpiration
+	case avroMaxExpiration:
+		return xgen.MaxExpiration
+	}
+	return e
+}
+
 func newSegments(segments []xgen.Segment) ([]map[string]interface{}, error) {
 	var list []map[string]interface{}
 
-	for _, segment := range segments {
-		// TODO: validate segment fields
+	for i, segment := range segments {
+		expiration, ok := toAvroExpiration(segment.Expiration)
+		if !ok {
+			return nil, fmt.Errorf("seg[%d].Expiration is not in the range [-1, %d]", i, xgen.MaxExpiration)
+		}
+
 		item := map[string]interface{}{
 			"id":         segment.ID,
 			"value":      segment.Value,
-			"expiration": segment.Expiration,
+			"expiration": expiration,
 			"code":       segment.Code,
 			"member_id":  segment.MemberID,
+			"timestamp":  segment.Timestamp,
 		}
 		list = append(list, item)
 	}
@@ -84,33 +143,13 @@ func newSegments(segments []xgen.Segment) ([]map[string]interface{}, error) {
 }
 
 func (w *AvroWriter) Append(users []*UserRecord) error {
-	var err error
 	var records []interface{}
 
 	for _, user := range users {
-		var uid map[string]interface{}
-
-		switch user.Domain {
-		case xgen.XandrID:
-			uid, err = newXandrID(user.UID)
-		case xgen.AAID, xgen.IDFA:
-			uid, err = newDeviceID(user.UID, user.Domain)
-		}
-
-		if err != nil {
-			return err
-		}
-
-		segments, err := newSegments(user.Segments)
+		record, err := newRecord(user)
 		if err != nil {
 			return err
 		}
-
-		record := map[string]interface{}{
-			"uid":      uid,
-			"segments": segments,
-		}
-
 		records = append(records, record)
 	}
 
@@ -120,3 +159,33 @@ func (w *AvroWriter) Append(users []*UserRecord) error {
 
 	return nil
 }
+
+func newRecord(user *UserRecord) (map[string]interface{}, error) {
+	var uid map[string]interface{}
+	var err error
+
+	switch user.Domain {
+	case xgen.XandrID:
+		uid, err = newXandrID(user.UID)
+	case xgen.AAID, xgen.IDFA:
+		uid, err = newDeviceID(user.UID, user.Domain)
+	default:
+		err = fmt.Errorf("invalid domain: %s", user.Domain)
+	}
+
+	if err != nil {
+		return nil, &RecordError{Err: err}
+	}
+
+	segments, err := newSegments(user.Segments)
+	if err != nil {
+		return nil, &RecordError{Err: err}
+	}
+
+	record := map[string]interface{}{
+		"uid":      uid,
+		"segments": segments,
+	}
+
+	return record, nil
+}
@@ -0,0 +1,125 @@
+package avro
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// CanonicalBlockSize is the number of records per block written by CanonicalWriter.
+const CanonicalBlockSize = 1000
+
+// CanonicalWriter writes object container files which are byte-identical for the same sequence of records.
+// Unlike AvroWriter it writes header metadata in sorted order, uses a sync marker derived from the schema
+// and starts a new block every CanonicalBlockSize records regardless of Append calls.
+type CanonicalWriter struct {
+	w      io.Writer
+	codec  *goavro.Codec
+	sync   [16]byte
+	header bool
+	block  []byte
+	count  int
+	err    error
+}
+
+// NewCanonicalWriter creates canonical writer. The header is written with the first block.
+func NewCanonicalWriter(w io.Writer) (*CanonicalWriter, error) {
+	codec, err := goavro.NewCodec(xandrSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	cw := &CanonicalWriter{w: w, codec: codec}
+
+	sum := sha256.Sum256([]byte(codec.Schema()))
+	copy(cw.sync[:], sum[:])
+
+	return cw, nil
+}
+
+// Encode returns binary encoding of the user record.
+func (cw *CanonicalWriter) Encode(user *UserRecord) ([]byte, error) {
+	record, err := newRecord(user)
+	if err != nil {
+		return nil, err
+	}
+	return cw.codec.BinaryFromNative(nil, record)
+}
+
+// Append writes users.
+func (cw *CanonicalWriter) Append(users []*UserRecord) error {
+	for _, user := range users {
+		rec, err := cw.Encode(user)
+		if err != nil {
+			return err
+		}
+		if err := cw.WriteEncoded(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteEncoded writes record returned by Encode.
+func (cw *CanonicalWriter) WriteEncoded(rec []byte) error {
+	cw.block = append(cw.block, rec...)
+	cw.count++
+
+	if cw.count == CanonicalBlockSize {
+		return cw.flush()
+	}
+
+	return cw.err
+}
+
+// Close writes the last block. The file has only the header if no records were written.
+func (cw *CanonicalWriter) Close() error {
+	return cw.flush()
+}
+
+func (cw *CanonicalWriter) flush() error {
+	if cw.err != nil {
+		return cw.err
+	}
+
+	var buf bytes.Buffer
+
+	if !cw.header {
+		cw.header = true
+		buf.WriteString("Obj\x01")
+
+		// metadata map with keys in sorted order followed by the end of the map
+		buf.Write(appendLong(nil, 2))
+		for _, kv := range [][2]string{{"avro.codec", "null"}, {"avro.schema", cw.codec.Schema()}} {
+			buf.Write(appendBytes(nil, []byte(kv[0])))
+			buf.Write(appendBytes(nil, []byte(kv[1])))
+		}
+		buf.Write(appendLong(nil, 0))
+		buf.Write(cw.sync[:])
+	}
+
+	if cw.count > 0 {
+		buf.Write(appendLong(nil, int64(cw.count)))
+		buf.Write(appendBytes(nil, cw.block))
+		buf.Write(cw.sync[:])
+		cw.block = cw.block[:0]
+		cw.count = 0
+	}
+
+	_, cw.err = cw.w.Write(buf.Bytes())
+
+	return cw.err
+}
+
+// appendLong appends zig-zag varint encoding of the avro long.
+func appendLong(b []byte, n int64) []byte {
+	return binary.AppendVarint(b, n)
+}
+
+// appendBytes appends avro bytes: the length followed by the data.
+func appendBytes(b []byte, data []byte) []byte {
+	return append(appendLong(b, int64(len(data))), data...)
+}
@@ -0,0 +1,220 @@
+package avro
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func randomRecord(rnd *rand.Rand) *UserRecord {
+	ur := &UserRecord{Domain: []xgen.Domain{xgen.XandrID, xgen.IDFA, xgen.AAID}[rnd.Intn(3)]}
+
+	if ur.Domain == xgen.XandrID {
+		ur.UID = strconv.FormatInt(rnd.Int63(), 10)
+	} else {
+		ur.UID = fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", rnd.Uint32(), rnd.Intn(1<<16), rnd.Intn(1<<16), rnd.Intn(1<<16), rnd.Int63n(1<<48))
+	}
+
+	for i, n := 0, rnd.Intn(10); i < n; i++ {
+		seg := xgen.Segment{
+			Expiration: rnd.Int31n(xgen.MaxExpiration+2) - 1,
+			Value:      rnd.Int31(),
+			Timestamp:  rnd.Int63n(1 << 40),
+		}
+		if rnd.Intn(2) == 0 {
+			seg.ID = 1 + rnd.Int31()
+		} else {
+			seg.Code = fmt.Sprintf("code-%d", rnd.Intn(1000))
+			seg.MemberID = 1 + rnd.Int31n(10000)
+		}
+		ur.Segments = append(ur.Segments, seg)
+	}
+
+	return ur
+}
+
+// roundTrip writes users by AvroWriter and CanonicalWriter and reads them back.
+func roundTrip(users []*UserRecord) ([][]*UserRecord, error) {
+	var results [][]*UserRecord
+
+	for _, canonical := range []bool{false, true} {
+		var buf bytes.Buffer
+		var err error
+
+		if canonical {
+			var w *CanonicalWriter
+			if w, err = NewCanonicalWriter(&buf); err == nil {
+				if err = w.Append(users); err == nil {
+					err = w.Close()
+				}
+			}
+		} else {
+			var w *AvroWriter
+			if w, err = NewAvroWriter(&buf); err == nil {
+				err = w.Append(users)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		rd, err := NewAvroReader(&buf)
+		if err != nil {
+			return nil, err
+		}
+
+		var list []*UserRecord
+		for {
+			ur, err := rd.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, ur)
+		}
+
+		results = append(results, list)
+	}
+
+	return results, nil
+}
+
+func TestAvroRoundTripProperty(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 20; i++ {
+		users := make([]*UserRecord, rnd.Intn(2*CanonicalBlockSize))
+		for j := range users {
+			users[j] = randomRecord(rnd)
+		}
+
+		results, err := roundTrip(users)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, actual := range results {
+			if !reflect.DeepEqual(actual, users) {
+				t.Fatalf("%d users are not read back", len(users))
+			}
+		}
+	}
+}
+
+func FuzzAvroRoundTrip(f *testing.F) {
+	f.Add(int64(12345), "", uint8(0), int32(100), "", int32(0), int32(1440), int32(5), int64(1700000000))
+	f.Add(int64(0), "6d92078a-8246-4ba4-ae5b-76104861e7dc", uint8(1), int32(0), "code", int32(7), int32(-1), int32(0), int64(0))
+	f.Add(int64(-1), "x", uint8(3), int32(-5), "\xff", int32(-1), int32(-2), int32(-1), int64(-1))
+
+	f.Fuzz(func(t *testing.T, xandrID int64, deviceID string, domain uint8, id int32, code string, memberID, expiration, value int32, ts int64) {
+		ur := &UserRecord{
+			UID:    strconv.FormatInt(xandrID, 10),
+			Domain: []xgen.Domain{xgen.XandrID, xgen.IDFA, xgen.AAID, "4"}[domain%4],
+			Segments: []xgen.Segment{
+				{ID: id, Code: code, MemberID: memberID, Expiration: expiration, Value: value, Timestamp: ts},
+			},
+		}
+		if ur.Domain != xgen.XandrID {
+			ur.UID = deviceID
+		}
+
+		results, err := roundTrip([]*UserRecord{ur})
+		if err != nil {
+			return
+		}
+
+		for _, actual := range results {
+			if len(actual) != 1 || !reflect.DeepEqual(actual[0], ur) {
+				t.Fatalf("\nexpected: %+v\nactual  : %+v", ur, actual)
+			}
+		}
+	})
+}
+
+func benchmarkRecords() []*UserRecord {
+	rnd := rand.New(rand.NewSource(1))
+
+	users := make([]*UserRecord, 1000)
+	for i := range users {
+		users[i] = randomRecord(rnd)
+	}
+
+	return users
+}
+
+func BenchmarkAvroWriter(b *testing.B) {
+	users := benchmarkRecords()
+
+	w, err := NewAvroWriter(io.Discard)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := w.Append(users[i%len(users) : i%len(users)+1]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCanonicalWriter(b *testing.B) {
+	users := benchmarkRecords()
+
+	w, err := NewCanonicalWriter(io.Discard)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := w.Append(users[i%len(users) : i%len(users)+1]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAvroReader(b *testing.B) {
+	users := benchmarkRecords()
+
+	var buf bytes.Buffer
+
+	w, err := NewAvroWriter(&buf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := w.Append(users); err != nil {
+		b.Fatal(err)
+	}
+
+	data := buf.Bytes()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	var rd *AvroReader
+
+	for i := 0; i < b.N; i++ {
+		if i%len(users) == 0 {
+			if rd, err = NewAvroReader(bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if _, err := rd.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
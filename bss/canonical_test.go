@@ -0,0 +1,280 @@
+package bss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/avro"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func canonicalUsers(reverse bool) []*xgen.UserRecord {
+	users := []*xgen.UserRecord{
+		{UID: "0001234567890", Segments: []xgen.Segment{{ID: 102, Value: 1}, {ID: 100}}},
+		{UID: "6D92078A-8246-4BA4-AE5B-76104861E7DC", Domain: xgen.IDFA, Segments: []xgen.Segment{{ID: 100}}},
+		{UID: "1234567889", Segments: []xgen.Segment{{ID: 101, Expiration: xgen.Expired}, {ID: 100, Expiration: 60}}},
+	}
+
+	if reverse {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+		for _, u := range users {
+			for i, j := 0, len(u.Segments)-1; i < j; i, j = i+1, j-1 {
+				u.Segments[i], u.Segments[j] = u.Segments[j], u.Segments[i]
+			}
+		}
+	}
+
+	return users
+}
+
+func TestCanonicalText(t *testing.T) {
+	var outputs []string
+	var hashes []string
+
+	for _, reverse := range []bool{false, true} {
+		var buf bytes.Buffer
+
+		df, err := NewSegmentDataFormatter(&buf, FormatText, &xgen.FullFormat, WithCanonical())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// one record per Append call must not change the output
+		for _, u := range canonicalUsers(reverse) {
+			if err := df.Append([]*xgen.UserRecord{u}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := df.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		outputs = append(outputs, buf.String())
+		hashes = append(hashes, df.Hash())
+	}
+
+	const expected = `1234567889:100:60:0:0#101:-1:0:0
+1234567890:100:0:0:0;102:0:1:0
+6d92078a-8246-4ba4-ae5b-76104861e7dc:100:0:0:0^3
+`
+
+	if outputs[0] != expected || outputs[1] != expected {
+		t.Fatalf("\nexpected:\n%s\nactual:\n%s\n%s", expected, outputs[0], outputs[1])
+	}
+
+	sum := sha256.Sum256([]byte(expected))
+	if h := hex.EncodeToString(sum[:]); hashes[0] != h || hashes[1] != h {
+		t.Fatalf("\nexpected: %s\nactual  : %v", h, hashes)
+	}
+}
+
+func TestCanonicalAvro(t *testing.T) {
+	var outputs [][]byte
+
+	for _, reverse := range []bool{false, true} {
+		var buf bytes.Buffer
+
+		df, err := NewSegmentDataFormatter(&buf, FormatAvro, nil, WithCanonical())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		users := canonicalUsers(reverse)
+
+		// more than one block
+		for i := 0; i < avro.CanonicalBlockSize; i++ {
+			users = append(users, &xgen.UserRecord{UID: "2000000000", Segments: []xgen.Segment{{ID: int32(i + 1)}}})
+		}
+
+		if err := df.Append(users); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := df.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		outputs = append(outputs, buf.Bytes())
+	}
+
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Fatal("avro outputs are different")
+	}
+
+	dr, err := NewSegmentDataReader(bytes.NewReader(outputs[0]), FormatAvro, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uids []string
+	for {
+		ur, err := dr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(uids) < 3 {
+			uids = append(uids, ur.UID)
+		}
+	}
+
+	expected := []string{"1234567889", "1234567890", "2000000000"}
+	if !reflect.DeepEqual(uids, expected) || dr.Line() != avro.CanonicalBlockSize+3 {
+		t.Fatalf("\nexpected: %v %d\nactual  : %v %d", expected, avro.CanonicalBlockSize+3, uids, dr.Line())
+	}
+}
+
+func TestCanonicalSortedRuns(t *testing.T) {
+	for _, format := range []DataFormat{FormatText, FormatAvro} {
+		var outputs [][]byte
+
+		for _, chunk := range []int{0, 7} {
+			dir := t.TempDir()
+
+			var buf bytes.Buffer
+
+			df, err := NewSegmentDataFormatter(&buf, format, &xgen.FullFormat, WithCanonical(),
+				WithSortOptions(&SortOptions{ChunkSize: chunk, TempDir: dir}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 100; i++ {
+				users := canonicalUsers(i%2 == 1)
+				users = append(users, &xgen.UserRecord{UID: fmt.Sprint(2000000000 - i*37%100), Segments: []xgen.Segment{{ID: int32(i + 1)}}})
+				if err := df.Append(users); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			files, _ := os.ReadDir(dir)
+			if chunk > 0 && (len(files) != 57 || df.unwritten() == 0) {
+				t.Fatal("unexpected sorted runs:", len(files), df.unwritten())
+			}
+
+			if err := df.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if files, _ := os.ReadDir(dir); len(files) != 0 {
+				t.Fatal("temporary files are not removed:", len(files))
+			}
+
+			outputs = append(outputs, buf.Bytes())
+		}
+
+		if !bytes.Equal(outputs[0], outputs[1]) {
+			t.Fatalf("%s outputs of merged runs are different", format)
+		}
+	}
+}
+
+func TestCanonicalUsersNotModified(t *testing.T) {
+	users := canonicalUsers(true)
+	before := canonicalUsers(true)
+
+	var buf bytes.Buffer
+
+	df, err := NewSegmentDataFormatter(&buf, FormatText, &xgen.FullFormat, WithCanonical(), WithNormalizer(&Normalizer{Sort: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Append(users); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(users, before) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", before, users)
+	}
+}
+
+func TestCanonicalDomain(t *testing.T) {
+	users := []*xgen.UserRecord{
+		{UID: "00018446744073709551615", Domain: "xandr_id", Segments: []xgen.Segment{{ID: 100}}},
+		{UID: "0001234567890", Segments: []xgen.Segment{{ID: 100}}},
+		{UID: "6D92078A-8246-4BA4-AE5B-76104861E7DC", Domain: " IDFA ", Segments: []xgen.Segment{{ID: 100}}},
+		{UID: "B1C2", Domain: "aaid", Segments: []xgen.Segment{{ID: 100}}},
+	}
+
+	var buf bytes.Buffer
+
+	df, err := NewSegmentDataFormatter(&buf, FormatText, &xgen.FullFormat, WithCanonical())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Append(users); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = `1234567890:100:0:0:0
+18446744073709551615:100:0:0:0
+6d92078a-8246-4ba4-ae5b-76104861e7dc:100:0:0:0^3
+b1c2:100:0:0:0^8
+`
+
+	if buf.String() != expected {
+		t.Fatalf("\nexpected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+}
+
+func TestFormatterHash(t *testing.T) {
+	var buf bytes.Buffer
+
+	df, err := NewSegmentDataFormatter(&buf, FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Append(canonicalUsers(false)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if h := df.Hash(); h != "" {
+		t.Fatal("expected empty hash without WithHash, got", h)
+	}
+
+	buf.Reset()
+
+	df, err = NewSegmentDataFormatter(&buf, FormatText, &xgen.FullFormat, WithHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Append(canonicalUsers(false)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	if h := hex.EncodeToString(sum[:]); df.Hash() != h {
+		t.Fatalf("\nexpected: %s\nactual  : %s", h, df.Hash())
+	}
+}
@@ -0,0 +1,137 @@
+package bss
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// ConsentAction defines what ConsentFilter does with suppressed memberships.
+type ConsentAction int
+
+const (
+	ConsentDrop   ConsentAction = iota // do not write the memberships
+	ConsentRemove                      // write the memberships as removals
+)
+
+// Suppressor reports whether a user opted out, e.g. suppress.Set or suppress.Bloom.
+type Suppressor interface {
+	Contains(uid string) bool
+}
+
+// ConsentRule suppresses segments of users of the domains. Empty Domains match all domains,
+// empty Segments match all segments.
+type ConsentRule struct {
+	Domains  []xgen.Domain
+	Segments []xgen.SegmentKey
+	Action   ConsentAction
+}
+
+// ConsentStats contains ConsentFilter counters for audit.
+type ConsentStats struct {
+	Users           int // processed users
+	SuppressedUsers int // users found in the suppression list
+	DroppedUsers    int // users without memberships left
+	DroppedSegments int
+	RemovedSegments int // memberships converted to removals
+}
+
+// ConsentFilter is a Stage suppressing users found in the suppression lists and segments matched by rules.
+// Suppression lists contain user IDs of a single domain and are chosen by the domain of the user, so an IDFA
+// equal to a suppressed Xandr ID is not suppressed.
+type ConsentFilter struct {
+	Lists  map[xgen.Domain]Suppressor // suppression lists by domain, users of other domains are not checked
+	Action ConsentAction
+	Rules  []ConsentRule
+
+	stats ConsentStats
+}
+
+// Process implements Stage. It returns nil if no memberships are left.
+func (cf *ConsentFilter) Process(ur *xgen.UserRecord) (*xgen.UserRecord, error) {
+	cf.stats.Users++
+
+	// all memberships of an opted out user are suppressed
+	if list := cf.Lists[ur.Domain]; list != nil && list.Contains(ur.UID) {
+		cf.stats.SuppressedUsers++
+		cf.apply(ur, cf.Action, nil)
+	}
+
+	for i := range cf.Rules {
+		rule := &cf.Rules[i]
+		if matchDomain(rule.Domains, ur.Domain) {
+			cf.apply(ur, rule.Action, rule.Segments)
+		}
+	}
+
+	if len(ur.Segments) == 0 {
+		cf.stats.DroppedUsers++
+		return nil, nil
+	}
+
+	return ur, nil
+}
+
+// apply suppresses memberships of the segments, all memberships if segments are empty.
+func (cf *ConsentFilter) apply(ur *xgen.UserRecord, action ConsentAction, segments []xgen.SegmentKey) {
+	kept := ur.Segments[:0]
+
+	for _, seg := range ur.Segments {
+		if !matchSegment(segments, &seg) {
+			kept = append(kept, seg)
+			continue
+		}
+
+		switch {
+		case action == ConsentDrop:
+			cf.stats.DroppedSegments++
+			continue
+		case seg.Expiration != xgen.Expired:
+			cf.stats.RemovedSegments++
+			seg.Expiration = xgen.Expired
+		}
+
+		kept = append(kept, seg)
+	}
+
+	ur.Segments = kept
+}
+
+func matchDomain(domains []xgen.Domain, d xgen.Domain) bool {
+	if len(domains) == 0 {
+		return true
+	}
+	for _, x := range domains {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegment(segments []xgen.SegmentKey, seg *xgen.Segment) bool {
+	if len(segments) == 0 {
+		return true
+	}
+	key := seg.Key()
+	for _, k := range segments {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns counters of processed users.
+func (cf *ConsentFilter) Stats() ConsentStats {
+	return cf.stats
+}
+
+// WriteStats writes counters in the audit log format.
+func (cf *ConsentFilter) WriteStats(w io.Writer) error {
+	s := cf.stats
+	_, err := fmt.Fprintf(w, "consent: users %d, suppressed users %d, dropped users %d, dropped segments %d, removed segments %d\n",
+		s.Users, s.SuppressedUsers, s.DroppedUsers, s.DroppedSegments, s.RemovedSegments)
+	return err
+}
@@ -0,0 +1,77 @@
+package bss
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/suppress"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestConsentFilter(t *testing.T) {
+	xandrList, err := suppress.LoadSet(strings.NewReader("uid\n1234567891\n6D92078A-8246-4BA4-AE5B-76104861E7DC\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idfaList, err := suppress.LoadSet(strings.NewReader("6D92078A-8246-4BA4-AE5B-76104861E7DC\n1234567890\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const input = `1234567890:100;500
+1234567891:100;101
+6d92078a-8246-4ba4-ae5b-76104861e7dc:100^3
+5A2D3E9C-8E7B-4C1D-9F0A-1B2C3D4E5F60:100^8
+1234567892:500
+`
+
+	cf := &ConsentFilter{
+		Lists:  map[xgen.Domain]Suppressor{xgen.XandrID: xandrList, xgen.IDFA: idfaList},
+		Action: ConsentRemove,
+		Rules: []ConsentRule{
+			{Domains: []xgen.Domain{xgen.AAID}, Action: ConsentDrop},
+			{Segments: []xgen.SegmentKey{{ID: 500}}, Action: ConsentDrop},
+		},
+	}
+
+	dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+
+	df, err := NewSegmentDataFormatter(&out, FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Convert(dr, df, &ConvertOptions{Stages: Pipeline{cf}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = `1234567890:100
+1234567891:#100;101
+6d92078a-8246-4ba4-ae5b-76104861e7dc:#100^3
+`
+
+	if out.String() != expected {
+		t.Fatalf("\nexpected:\n%s\nactual:\n%s", expected, out.String())
+	}
+
+	if stats.Users != 3 || stats.Dropped != 2 {
+		t.Fatalf("unexpected convert stats: %+v", stats)
+	}
+
+	expectedStats := ConsentStats{Users: 5, SuppressedUsers: 2, DroppedUsers: 2, DroppedSegments: 3, RemovedSegments: 3}
+	if !reflect.DeepEqual(cf.Stats(), expectedStats) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expectedStats, cf.Stats())
+	}
+}
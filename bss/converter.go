@@ -0,0 +1,164 @@
+package bss
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// LossPolicy defines what converter does when the target format cannot represent a segment field.
+type LossPolicy int
+
+const (
+	LossWarn   LossPolicy = iota // report a warning and drop the field
+	LossFail                     // stop conversion with an error
+	LossIgnore                   // drop the field silently
+)
+
+const defaultBatchSize = 1000
+
+// ConvertOptions configures Convert.
+type ConvertOptions struct {
+	Policies  map[xgen.SegmentFieldName]LossPolicy // per field policy, LossWarn if not set
+	Warn      func(line int, msg string)           // called for LossWarn fields
+	BatchSize int                                  // number of users per Append call
+	Transform func(ur *xgen.UserRecord)            // called for every record before encoding
+	Stages    Pipeline                             // run after Transform, users dropped by a stage are not written
+}
+
+// ConvertStats contains conversion counters.
+type ConvertStats struct {
+	Users    int // written users
+	Dropped  int // users dropped by stages
+	Warnings int
+}
+
+// Convert reads records from dr and writes them to df. Only BatchSize records are kept in memory.
+// Segment fields which df cannot represent are handled according to opts.Policies.
+// Expiration out of the range [-1, xgen.MaxExpiration] is handled by the EXPIRATION policy
+// and replaced with xgen.DefaultExpiration unless the policy fails.
+// Convert does not close df.
+func Convert(dr *SegmentDataReader, df RecordWriter, opts *ConvertOptions) (*ConvertStats, error) {
+	if opts == nil {
+		opts = &ConvertOptions{}
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	fields := make(map[xgen.SegmentFieldName]bool)
+	for _, f := range df.Fields() {
+		fields[f] = true
+	}
+
+	stats := &ConvertStats{}
+	batch := make([]*xgen.UserRecord, 0, batchSize)
+
+	for {
+		user, err := dr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		if opts.Transform != nil {
+			opts.Transform(user)
+		}
+
+		user, err = opts.Stages.Process(user)
+		if err != nil {
+			return stats, fmt.Errorf("line %d: %w", dr.Line(), err)
+		}
+		if user == nil {
+			stats.Dropped++
+			continue
+		}
+
+		for i := range user.Segments {
+			seg := &user.Segments[i]
+
+			// expiration which no format can represent is dropped according to the policy
+			if seg.Expiration < xgen.Expired || seg.Expiration > xgen.MaxExpiration {
+				msg := fmt.Sprintf("uid %s: seg[%d].%s %d is not in the range [-1, %d]", user.UID, i, xgen.ExpirationField, seg.Expiration, xgen.MaxExpiration)
+				if err := stats.lose(opts, dr.Line(), xgen.ExpirationField, msg); err != nil {
+					return stats, err
+				}
+				seg.Expiration = xgen.DefaultExpiration
+			}
+
+			for _, f := range lostFields(seg, fields) {
+				msg := fmt.Sprintf("uid %s: seg[%d].%s cannot be represented in the target format", user.UID, i, f)
+				if err := stats.lose(opts, dr.Line(), f, msg); err != nil {
+					return stats, err
+				}
+			}
+		}
+
+		batch = append(batch, user)
+		stats.Users++
+
+		if len(batch) == batchSize {
+			if err := df.Append(batch); err != nil {
+				return stats, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := df.Append(batch); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// lose handles a lost field according to its policy. It returns error for LossFail.
+func (stats *ConvertStats) lose(opts *ConvertOptions, line int, f xgen.SegmentFieldName, msg string) error {
+	switch opts.Policies[f] {
+	case LossIgnore:
+		return nil
+	case LossFail:
+		return fmt.Errorf("line %d: %s", line, msg)
+	}
+
+	stats.Warnings++
+	if opts.Warn != nil {
+		opts.Warn(line, msg)
+	}
+
+	return nil
+}
+
+// lostFields returns non-empty segment fields missing in the target fields.
+// Removals are represented by the removal block, so Expired is never lost.
+func lostFields(seg *xgen.Segment, fields map[xgen.SegmentFieldName]bool) []xgen.SegmentFieldName {
+	var lost []xgen.SegmentFieldName
+
+	if seg.ID != 0 && !fields[xgen.SegIdField] {
+		lost = append(lost, xgen.SegIdField)
+	}
+	if seg.Code != "" && !fields[xgen.SegCodeField] {
+		lost = append(lost, xgen.SegCodeField)
+	}
+	if seg.MemberID != 0 && !fields[xgen.MemberIdField] {
+		lost = append(lost, xgen.MemberIdField)
+	}
+	if seg.Expiration != xgen.DefaultExpiration && seg.Expiration != xgen.Expired && !fields[xgen.ExpirationField] {
+		lost = append(lost, xgen.ExpirationField)
+	}
+	if seg.Value != 0 && !fields[xgen.ValueField] {
+		lost = append(lost, xgen.ValueField)
+	}
+	if seg.Timestamp != 0 && !fields[xgen.TimestampField] {
+		lost = append(lost, xgen.TimestampField)
+	}
+
+	return lost
+}
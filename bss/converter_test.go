@@ -0,0 +1,283 @@
+package bss
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/metrics"
+)
+
+func TestConvertTextToAvro(t *testing.T) {
+	const input = `12345:100:1440:5:1700000000;101:1440:0:0#102:-1:0:0
+6D92078A-8246-4BA4-AE5B-76104861E7DC:100:0:0:0^3
+`
+
+	dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	df, err := NewSegmentDataFormatter(&out, FormatAvro, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Convert(dr, df, &ConvertOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Users != 2 || stats.Warnings != 0 {
+		t.Fatalf("invalid stats: %+v", stats)
+	}
+
+	// convert back to text
+
+	dr, err = NewSegmentDataReader(&out, FormatAvro, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var text bytes.Buffer
+
+	df, err = NewSegmentDataFormatter(&text, FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Convert(dr, df, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if text.String() != input {
+		t.Fatal("\nexpected:", input, "\nactual  :", text.String())
+	}
+}
+
+func TestConvertLoss(t *testing.T) {
+	const input = "12345:100:1440:5:1700000000\n"
+
+	newReader := func() *SegmentDataReader {
+		dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.FullFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dr
+	}
+
+	var out bytes.Buffer
+
+	df, err := NewSegmentDataFormatter(&out, FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var warnings []string
+
+	opts := &ConvertOptions{
+		Policies: map[xgen.SegmentFieldName]LossPolicy{xgen.ValueField: LossIgnore},
+		Warn: func(line int, msg string) {
+			warnings = append(warnings, msg)
+		},
+	}
+
+	stats, err := Convert(newReader(), df, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Warnings != 2 || len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings: %v", warnings)
+	}
+
+	if warnings[1] != "uid 12345: seg[0].TIMESTAMP cannot be represented in the target format" {
+		t.Fatal("invalid warning:", warnings[1])
+	}
+
+	opts.Policies[xgen.TimestampField] = LossFail
+
+	_, err = Convert(newReader(), df, opts)
+	if err == nil {
+		t.Fatal("should return error")
+	}
+
+	if err.Error() != "line 1: uid 12345: seg[0].TIMESTAMP cannot be represented in the target format" {
+		t.Fatal("invalid error message:", err.Error())
+	}
+}
+
+func TestConvertInstrumented(t *testing.T) {
+	const input = "1:100\n2:101\n3:100;101\n"
+
+	dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := metrics.NewRegistry()
+
+	var out bytes.Buffer
+	df, err := NewSegmentDataFormatter(&out, FormatText, &xgen.MinimalFormat, WithInstrument(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drop101 := StageFunc(func(ur *xgen.UserRecord) (*xgen.UserRecord, error) {
+		if ur.Segments[0].ID == 101 {
+			return nil, nil
+		}
+		return ur, nil
+	})
+
+	opts := &ConvertOptions{Stages: Pipeline{Instrumented("drop", drop101, reg)}}
+	if _, err := Convert(dr, df, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	label := metrics.L("stage", "drop")
+
+	expected := []int64{3, 1, 2, 3, int64(out.Len())}
+	actual := []int64{
+		reg.Value(metrics.StageUsers, label),
+		reg.Value(metrics.Rejects, label),
+		reg.Value(metrics.Users),
+		reg.Value(metrics.Segments),
+		reg.Value(metrics.Bytes),
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, actual)
+	}
+}
+
+func TestConvertExpiration(t *testing.T) {
+	// text default expiration is written as avro -2
+
+	dr, err := NewSegmentDataReader(strings.NewReader("1:100:0:0:0;101:1440:0:0\n"), FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	df, err := NewSegmentDataFormatter(&out, FormatAvro, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Convert(dr, df, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ocfr, err := goavro.NewOCFReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []int32
+	for ocfr.Scan() {
+		datum, err := ocfr.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, seg := range datum.(map[string]interface{})["segments"].([]interface{}) {
+			actual = append(actual, seg.(map[string]interface{})["expiration"].(int32))
+		}
+	}
+
+	if expected := []int32{-2, 1440}; !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, actual)
+	}
+
+	// avro -2 is written as text default expiration, avro 0 as max expiration
+
+	schema, err := os.ReadFile("avro/xandr_schema.avsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var in bytes.Buffer
+
+	ocfw, err := goavro.NewOCFWriter(goavro.OCFConfig{W: &in, Schema: string(schema)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ocfw.Append([]interface{}{map[string]interface{}{
+		"uid": map[string]interface{}{"long": int64(1)},
+		"segments": []interface{}{
+			map[string]interface{}{"id": int32(100), "expiration": int32(-2)},
+			map[string]interface{}{"id": int32(101), "expiration": int32(0)},
+			map[string]interface{}{"id": int32(102), "expiration": int32(-5)},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	convert := func(opts *ConvertOptions) (string, error) {
+		dr, err := NewSegmentDataReader(bytes.NewReader(in.Bytes()), FormatAvro, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var text bytes.Buffer
+
+		df, err := NewSegmentDataFormatter(&text, FormatText, &xgen.FullFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Convert(dr, df, opts); err != nil {
+			return "", err
+		}
+
+		err = df.Close()
+		return text.String(), err
+	}
+
+	var warnings []string
+
+	text, err := convert(&ConvertOptions{Warn: func(line int, msg string) { warnings = append(warnings, msg) }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "1:100:0:0:0;101:259200:0:0;102:0:0:0\n"
+	if text != expected {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, text)
+	}
+
+	expectedWarnings := []string{"uid 1: seg[2].EXPIRATION -5 is not in the range [-1, 259200]"}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expectedWarnings, warnings)
+	}
+
+	_, err = convert(&ConvertOptions{Policies: map[xgen.SegmentFieldName]LossPolicy{xgen.ExpirationField: LossFail}})
+	if err == nil || err.Error() != "line 1: uid 1: seg[2].EXPIRATION -5 is not in the range [-1, 259200]" {
+		t.Fatal("unexpected error:", err)
+	}
+}
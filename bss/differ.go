@@ -0,0 +1,193 @@
+package bss
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// DiffStats contains number of changes written by Diff.
+type DiffStats struct {
+	Users     int // users in the delta
+	Additions int // new memberships
+	Removals  int // memberships which disappeared
+	Updates   int // memberships with changed value or expiration
+}
+
+// Diff compares previous and current snapshots and writes delta to df.
+// Both readers should return records sorted by UID (see SortRecords).
+// Removals found in the snapshots are ignored, snapshots are expected to contain memberships only.
+// Diff does not close df.
+func Diff(prev, cur RecordReader, df RecordWriter) (*DiffStats, error) {
+	stats := &DiffStats{}
+
+	pr := &sortedStream{r: prev, name: "previous"}
+	cr := &sortedStream{r: cur, name: "current"}
+
+	p, err := pr.next()
+	if err != nil {
+		return stats, err
+	}
+
+	c, err := cr.next()
+	if err != nil {
+		return stats, err
+	}
+
+	batch := make([]*xgen.UserRecord, 0, defaultBatchSize)
+
+	for p != nil || c != nil {
+		var delta *xgen.UserRecord
+
+		switch {
+		case c == nil || p != nil && compareUsers(p, c) < 0:
+			delta = diffUser(p, nil, stats)
+			if p, err = pr.next(); err != nil {
+				return stats, err
+			}
+		case p == nil || compareUsers(p, c) > 0:
+			delta = diffUser(nil, c, stats)
+			if c, err = cr.next(); err != nil {
+				return stats, err
+			}
+		default:
+			delta = diffUser(p, c, stats)
+			if p, err = pr.next(); err != nil {
+				return stats, err
+			}
+			if c, err = cr.next(); err != nil {
+				return stats, err
+			}
+		}
+
+		if delta == nil {
+			continue
+		}
+
+		stats.Users++
+		batch = append(batch, delta)
+
+		if len(batch) == cap(batch) {
+			if err := df.Append(batch); err != nil {
+				return stats, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := df.Append(batch); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// dedupSegments returns segments with unique keys in the order of the first occurrence.
+// The last occurrence of a key wins.
+func dedupSegments(segs []xgen.Segment) []xgen.Segment {
+	index := make(map[xgen.SegmentKey]int, len(segs))
+	list := make([]xgen.Segment, 0, len(segs))
+
+	for _, seg := range segs {
+		if i, ok := index[seg.Key()]; ok {
+			list[i] = seg
+			continue
+		}
+		index[seg.Key()] = len(list)
+		list = append(list, seg)
+	}
+
+	return list
+}
+
+// diffUser returns delta record for a user or nil if memberships are the same.
+// Either prev or cur can be nil.
+func diffUser(prev, cur *xgen.UserRecord, stats *DiffStats) *xgen.UserRecord {
+	delta := &xgen.UserRecord{}
+	old := make(map[xgen.SegmentKey]xgen.Segment)
+
+	if prev != nil {
+		delta.UID, delta.Domain = prev.UID, prev.Domain
+		for _, seg := range prev.Segments {
+			if seg.Expiration != xgen.Expired {
+				old[seg.Key()] = seg
+			}
+		}
+	}
+
+	if cur != nil {
+		delta.UID, delta.Domain = cur.UID, cur.Domain
+		for _, seg := range dedupSegments(cur.Segments) {
+			if seg.Expiration == xgen.Expired {
+				continue
+			}
+
+			key := seg.Key()
+			o, ok := old[key]
+			delete(old, key)
+
+			switch {
+			case !ok:
+				stats.Additions++
+			case o.Value != seg.Value || o.Expiration != seg.Expiration:
+				stats.Updates++
+			default:
+				continue
+			}
+
+			delta.Segments = append(delta.Segments, seg)
+		}
+	}
+
+	if prev != nil {
+		for _, seg := range prev.Segments {
+			if _, ok := old[seg.Key()]; !ok {
+				continue
+			}
+			delete(old, seg.Key())
+
+			stats.Removals++
+			delta.Segments = append(delta.Segments, xgen.Segment{
+				ID:         seg.ID,
+				Code:       seg.Code,
+				MemberID:   seg.MemberID,
+				Expiration: xgen.Expired,
+			})
+		}
+	}
+
+	if len(delta.Segments) == 0 {
+		return nil
+	}
+
+	return delta
+}
+
+// sortedStream checks that records are sorted by UID.
+type sortedStream struct {
+	r    RecordReader
+	name string
+	last *xgen.UserRecord
+}
+
+// next returns next record or nil at the end of the stream.
+func (s *sortedStream) next() (*xgen.UserRecord, error) {
+	user, err := s.r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.last != nil && compareUsers(s.last, user) >= 0 {
+		return nil, fmt.Errorf("%s snapshot is not sorted by UID or has duplicates: %s after %s", s.name, user.UID, s.last.UID)
+	}
+
+	s.last = user
+
+	return user, nil
+}
@@ -0,0 +1,119 @@
+package bss
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestDiff(t *testing.T) {
+	const prevInput = `12345:100:0:1:0;101:0:1:0
+12346:100:0:1:0
+12347:100:0:1:0
+`
+	const curInput = `12348:100:0:1:0
+12345:100:0:2:0;102:0:1:0
+12347:100:0:1:0
+`
+	const expected = `12345:100:0:2:0;102:0:1:0#101:-1:0:0
+12346:#100:-1:0:0
+12348:100:0:1:0
+`
+
+	newSorted := func(input string) *SortedReader {
+		dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.FullFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sr, err := SortRecords(dr, &SortOptions{ChunkSize: 2, TempDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sr
+	}
+
+	prev := newSorted(prevInput)
+	defer prev.Close()
+
+	cur := newSorted(curInput)
+	defer cur.Close()
+
+	var out bytes.Buffer
+
+	df, err := NewSegmentDataFormatter(&out, FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Diff(prev, cur, df)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != expected {
+		t.Fatal("\nexpected:", expected, "\nactual  :", out.String())
+	}
+
+	if *stats != (DiffStats{Users: 3, Additions: 2, Removals: 2, Updates: 1}) {
+		t.Fatalf("invalid stats: %+v", stats)
+	}
+}
+
+func TestDiffUnsorted(t *testing.T) {
+	dr, err := NewSegmentDataReader(strings.NewReader("12346:100\n12345:100\n"), FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	empty, err := NewSegmentDataReader(strings.NewReader(""), FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	df, err := NewSegmentDataFormatter(&out, FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Diff(empty, dr, df)
+	if err == nil {
+		t.Fatal("should return error")
+	}
+
+	if err.Error() != "current snapshot is not sorted by UID or has duplicates: 12345 after 12346" {
+		t.Fatal("invalid error message:", err.Error())
+	}
+}
+
+func TestDiffRepeatedSegment(t *testing.T) {
+	prev := &xgen.UserRecord{UID: "12345", Segments: []xgen.Segment{{ID: 100, Value: 1}}}
+	cur := &xgen.UserRecord{UID: "12345", Segments: []xgen.Segment{
+		{ID: 101, Value: 1},
+		{ID: 100, Value: 1},
+		{ID: 101, Value: 2},
+		{ID: 100, Value: 3},
+	}}
+
+	var stats DiffStats
+
+	delta := diffUser(prev, cur, &stats)
+
+	expected := []xgen.Segment{{ID: 101, Value: 2}, {ID: 100, Value: 3}}
+	if delta == nil || !reflect.DeepEqual(delta.Segments, expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, delta)
+	}
+
+	if stats != (DiffStats{Additions: 1, Updates: 1}) {
+		t.Fatalf("invalid stats: %+v", stats)
+	}
+}
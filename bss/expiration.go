@@ -0,0 +1,166 @@
+package bss
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// ExpirationPolicy defines expiration of added memberships.
+type ExpirationPolicy struct {
+	TTL       time.Duration // zero sets member's default expiration
+	FromEvent bool          // TTL starts at the segment timestamp, so older events expire earlier
+}
+
+// Remaining returns time left until the membership expires. The time elapsed since the event
+// shortens TTL of FromEvent policies, events without timestamp or in the future do not.
+func (p ExpirationPolicy) Remaining(event, now time.Time) time.Duration {
+	if !p.FromEvent || event.IsZero() || event.After(now) {
+		return p.TTL
+	}
+	return p.TTL - now.Sub(event)
+}
+
+// ExpirationStats contains ExpirationStage counters.
+type ExpirationStats struct {
+	Segments int // memberships with expiration set by a policy
+	Expired  int // memberships which expired before the upload and were converted to removals
+	Clamped  int // memberships with TTL above MaxExpiration
+	Warnings int
+}
+
+// ExpirationStage is a Stage setting expiration of added memberships. The segment policy is used first,
+// then the policy of the segment category and then Default. Removals and memberships without
+// a policy are not changed.
+//
+// TTL above xgen.MaxExpiration is clamped with a warning. Memberships of FromEvent policies
+// whose TTL elapsed before now are converted to removals.
+type ExpirationStage struct {
+	Segments   map[xgen.SegmentKey]ExpirationPolicy
+	Categories map[string]ExpirationPolicy
+	Category   map[xgen.SegmentKey]string   // segment categories, e.g. from segment.Segment.Category
+	Default    *ExpirationPolicy            // nil keeps expiration of other segments
+	Now        func() time.Time             // time.Now if nil
+	Warn       func(uid string, msg string) // called for clamped values and events in the future
+
+	stats ExpirationStats
+}
+
+// Policy returns policy of the segment.
+func (es *ExpirationStage) Policy(key xgen.SegmentKey) (ExpirationPolicy, bool) {
+	if p, ok := es.Segments[key]; ok {
+		return p, true
+	}
+
+	if c, ok := es.Category[key]; ok {
+		if p, ok := es.Categories[c]; ok {
+			return p, true
+		}
+	}
+
+	if es.Default != nil {
+		return *es.Default, true
+	}
+
+	return ExpirationPolicy{}, false
+}
+
+// Process implements Stage.
+func (es *ExpirationStage) Process(ur *xgen.UserRecord) (*xgen.UserRecord, error) {
+	now := time.Now()
+	if es.Now != nil {
+		now = es.Now()
+	}
+
+	for i := range ur.Segments {
+		seg := &ur.Segments[i]
+		if seg.Expiration == xgen.Expired {
+			continue
+		}
+
+		p, ok := es.Policy(seg.Key())
+		if !ok {
+			continue
+		}
+
+		es.stats.Segments++
+
+		if p.TTL == 0 {
+			seg.Expiration = xgen.DefaultExpiration
+			continue
+		}
+
+		var event time.Time
+		if seg.Timestamp != 0 {
+			event = time.Unix(seg.Timestamp, 0)
+			if p.FromEvent && event.After(now) {
+				es.warn(ur.UID, "seg %s: timestamp %d is in the future, ttl is not shortened", seg.Key(), seg.Timestamp)
+			}
+		}
+
+		ttl := p.Remaining(event, now)
+
+		switch {
+		case ttl <= 0:
+			es.stats.Expired++
+			seg.Expiration = xgen.Expired
+		case ttl > xgen.MaxExpirationDuration:
+			es.stats.Clamped++
+			es.warn(ur.UID, "seg %s: ttl %s is clamped to %s", seg.Key(), ttl, xgen.MaxExpirationDuration)
+			seg.Expiration = xgen.MaxExpiration
+		default:
+			seg.Expiration = int32(xgen.ExpirationMinutes(ttl))
+		}
+	}
+
+	return ur, nil
+}
+
+func (es *ExpirationStage) warn(uid string, format string, args ...interface{}) {
+	es.stats.Warnings++
+	if es.Warn != nil {
+		es.Warn(uid, fmt.Sprintf(format, args...))
+	}
+}
+
+// Stats returns counters of processed memberships.
+func (es *ExpirationStage) Stats() ExpirationStats {
+	return es.stats
+}
+
+// WriteStats writes counters in the audit log format.
+func (es *ExpirationStage) WriteStats(w io.Writer) error {
+	s := es.stats
+	_, err := fmt.Fprintf(w, "expiration: segments %d, expired %d, clamped %d, warnings %d\n",
+		s.Segments, s.Expired, s.Clamped, s.Warnings)
+	return err
+}
+
+// ParseTTL parses duration in time.ParseDuration format which also accepts days, e.g. "7d" or "1d12h".
+func ParseTTL(s string) (time.Duration, error) {
+	var days time.Duration
+
+	ttl := s
+	if d, rest, ok := strings.Cut(s, "d"); ok {
+		n, err := strconv.ParseUint(d, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ttl: %s", s)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		if rest == "" {
+			return days, nil
+		}
+		ttl = rest
+	}
+
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid ttl: %s", s)
+	}
+
+	return days + d, nil
+}
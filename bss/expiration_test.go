@@ -0,0 +1,114 @@
+package bss
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestExpirationStage(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	hourAgo := now.Add(-time.Hour).Unix()
+
+	var warnings []string
+
+	es := &ExpirationStage{
+		Segments: map[xgen.SegmentKey]ExpirationPolicy{
+			{ID: 100}: {TTL: 24 * time.Hour},
+			{ID: 101}: {TTL: 365 * 24 * time.Hour},
+		},
+		Categories: map[string]ExpirationPolicy{
+			"intent": {TTL: 7 * 24 * time.Hour, FromEvent: true},
+		},
+		Category: map[xgen.SegmentKey]string{
+			{ID: 200}:                      "intent",
+			{ID: 201}:                      "intent",
+			{Code: "cart", MemberID: 1234}: "intent",
+		},
+		Now:  func() time.Time { return now },
+		Warn: func(uid string, msg string) { warnings = append(warnings, uid+": "+msg) },
+	}
+
+	ur := &xgen.UserRecord{
+		UID: "1234567890",
+		Segments: []xgen.Segment{
+			{ID: 100, Expiration: 60},
+			{ID: 101},
+			{ID: 200, Timestamp: hourAgo},
+			{ID: 201, Timestamp: now.Add(-8 * 24 * time.Hour).Unix()},
+			{Code: "cart", MemberID: 1234, Timestamp: now.Add(time.Hour).Unix()},
+			{ID: 300, Expiration: 90},
+			{ID: 100, Expiration: xgen.Expired},
+		},
+	}
+
+	ur, err := es.Process(ur)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []xgen.Segment{
+		{ID: 100, Expiration: 1440},
+		{ID: 101, Expiration: xgen.MaxExpiration},
+		{ID: 200, Expiration: 7*24*60 - 60, Timestamp: hourAgo},
+		{ID: 201, Expiration: xgen.Expired, Timestamp: now.Add(-8 * 24 * time.Hour).Unix()},
+		{Code: "cart", MemberID: 1234, Expiration: 7 * 24 * 60, Timestamp: now.Add(time.Hour).Unix()},
+		{ID: 300, Expiration: 90},
+		{ID: 100, Expiration: xgen.Expired},
+	}
+
+	if !reflect.DeepEqual(ur.Segments, expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, ur.Segments)
+	}
+
+	expectedStats := ExpirationStats{Segments: 5, Expired: 1, Clamped: 1, Warnings: 2}
+	if !reflect.DeepEqual(es.Stats(), expectedStats) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expectedStats, es.Stats())
+	}
+
+	expectedWarnings := []string{
+		"1234567890: seg 101: ttl 8760h0m0s is clamped to 4320h0m0s",
+		fmt.Sprintf("1234567890: seg cart/1234: timestamp %d is in the future, ttl is not shortened", now.Add(time.Hour).Unix()),
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Fatalf("\nexpected: %q\nactual  : %q", expectedWarnings, warnings)
+	}
+
+	es.Default = &ExpirationPolicy{}
+
+	ur = &xgen.UserRecord{UID: "1", Segments: []xgen.Segment{{ID: 300, Expiration: 90}}}
+	if ur, _ = es.Process(ur); ur.Segments[0].Expiration != xgen.DefaultExpiration {
+		t.Fatal("default policy is not applied:", ur.Segments)
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	for s, expected := range map[string]time.Duration{
+		"7d":     7 * 24 * time.Hour,
+		"1d12h":  36 * time.Hour,
+		"90m":    90 * time.Minute,
+		"0":      0,
+		"4320h":  xgen.MaxExpirationDuration,
+		"2d30m0": -1,
+		"-1h":    -1,
+		"xd":     -1,
+	} {
+		d, err := ParseTTL(s)
+		if expected < 0 {
+			if err == nil {
+				t.Fatalf("%s: expected error", s)
+			}
+			continue
+		}
+		if err != nil || d != expected {
+			t.Fatalf("%s: expected %s, actual %s, %v", s, expected, d, err)
+		}
+	}
+
+	if m := xgen.ExpirationMinutes(90*time.Second + 1); m != 2 {
+		t.Fatal("unexpected minutes:", m)
+	}
+}
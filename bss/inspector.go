@@ -0,0 +1,227 @@
+package bss
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// expirationBuckets are upper limits in minutes of the expiration distribution.
+var expirationBuckets = []struct {
+	name  string
+	limit int32
+}{
+	{"1d", 24 * 60},
+	{"7d", 7 * 24 * 60},
+	{"30d", 30 * 24 * 60},
+	{"90d", 90 * 24 * 60},
+	{"180d", xgen.MaxExpiration},
+}
+
+// SegmentCount contains number of users added to and removed from a segment.
+type SegmentCount struct {
+	Segment   string `json:"segment"`
+	Users     int    `json:"users"`
+	Additions int    `json:"additions"`
+	Removals  int    `json:"removals"`
+}
+
+// ExpirationBucket contains number of segment additions with expiration up to the bucket limit.
+type ExpirationBucket struct {
+	Bucket string `json:"bucket"`
+	Count  int    `json:"count"`
+}
+
+// InspectReport describes contents of a BSS file.
+type InspectReport struct {
+	Users          int                `json:"users"`
+	UsersByDomain  map[string]int     `json:"users_by_domain"`
+	UniqueSegments int                `json:"unique_segments"`
+	TopSegments    []SegmentCount     `json:"top_segments"`
+	Additions      int                `json:"additions"`
+	Removals       int                `json:"removals"`
+	RemovalRatio   float64            `json:"removal_ratio"` // removals / (additions + removals)
+	Expirations    []ExpirationBucket `json:"expirations"`
+	MinTimestamp   int64              `json:"min_timestamp"` // zero timestamps are not counted
+	MaxTimestamp   int64              `json:"max_timestamp"`
+}
+
+// Inspector collects statistics over a stream of user records.
+type Inspector struct {
+	users       int
+	domains     map[xgen.Domain]int
+	segments    map[xgen.SegmentKey]*SegmentCount
+	additions   int
+	removals    int
+	expirations map[string]int
+	minTS       int64
+	maxTS       int64
+}
+
+// NewInspector creates empty inspector.
+func NewInspector() *Inspector {
+	return &Inspector{
+		domains:     make(map[xgen.Domain]int),
+		segments:    make(map[xgen.SegmentKey]*SegmentCount),
+		expirations: make(map[string]int),
+	}
+}
+
+// Inspect reads all records from r and returns report with topN segments.
+func Inspect(r RecordReader, topN int) (*InspectReport, error) {
+	in := NewInspector()
+
+	for {
+		user, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		in.Add(user)
+	}
+
+	return in.Report(topN), nil
+}
+
+// Add accounts user record.
+func (in *Inspector) Add(ur *xgen.UserRecord) {
+	in.users++
+	in.domains[ur.Domain]++
+
+	for i := range ur.Segments {
+		seg := &ur.Segments[i]
+		key := seg.Key()
+
+		sc := in.segments[key]
+		if sc == nil {
+			sc = &SegmentCount{Segment: key.String()}
+			in.segments[key] = sc
+		}
+		sc.Users++
+
+		if seg.Expiration == xgen.Expired {
+			sc.Removals++
+			in.removals++
+		} else {
+			sc.Additions++
+			in.additions++
+			in.expirations[expirationBucket(seg.Expiration)]++
+		}
+
+		if seg.Timestamp != 0 {
+			if in.minTS == 0 || seg.Timestamp < in.minTS {
+				in.minTS = seg.Timestamp
+			}
+			if seg.Timestamp > in.maxTS {
+				in.maxTS = seg.Timestamp
+			}
+		}
+	}
+}
+
+func expirationBucket(exp int32) string {
+	if exp == xgen.DefaultExpiration {
+		return "default"
+	}
+	for _, b := range expirationBuckets {
+		if exp <= b.limit {
+			return b.name
+		}
+	}
+	return "invalid"
+}
+
+// Report returns collected statistics with topN segments by number of users.
+func (in *Inspector) Report(topN int) *InspectReport {
+	rep := &InspectReport{
+		Users:          in.users,
+		UsersByDomain:  make(map[string]int),
+		UniqueSegments: len(in.segments),
+		Additions:      in.additions,
+		Removals:       in.removals,
+		MinTimestamp:   in.minTS,
+		MaxTimestamp:   in.maxTS,
+	}
+
+	for d, n := range in.domains {
+		rep.UsersByDomain[d.Name()] = n
+	}
+
+	if total := in.additions + in.removals; total > 0 {
+		rep.RemovalRatio = float64(in.removals) / float64(total)
+	}
+
+	for _, name := range []string{"default", "1d", "7d", "30d", "90d", "180d", "invalid"} {
+		if n := in.expirations[name]; n > 0 {
+			rep.Expirations = append(rep.Expirations, ExpirationBucket{Bucket: name, Count: n})
+		}
+	}
+
+	top := make([]SegmentCount, 0, len(in.segments))
+	for _, sc := range in.segments {
+		top = append(top, *sc)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Users != top[j].Users {
+			return top[i].Users > top[j].Users
+		}
+		return top[i].Segment < top[j].Segment
+	})
+
+	if topN > 0 && len(top) > topN {
+		top = top[:topN]
+	}
+	rep.TopSegments = top
+
+	return rep
+}
+
+// WriteJSON writes the report as indented JSON.
+func (rep *InspectReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
+
+// WriteTable writes the report as text tables.
+func (rep *InspectReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "users\t%d\n", rep.Users)
+
+	domains := make([]string, 0, len(rep.UsersByDomain))
+	for d := range rep.UsersByDomain {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+
+	for _, d := range domains {
+		fmt.Fprintf(tw, "  %s\t%d\n", d, rep.UsersByDomain[d])
+	}
+
+	fmt.Fprintf(tw, "unique segments\t%d\n", rep.UniqueSegments)
+	fmt.Fprintf(tw, "additions\t%d\n", rep.Additions)
+	fmt.Fprintf(tw, "removals\t%d\n", rep.Removals)
+	fmt.Fprintf(tw, "removal ratio\t%.4f\n", rep.RemovalRatio)
+	fmt.Fprintf(tw, "min timestamp\t%d\n", rep.MinTimestamp)
+	fmt.Fprintf(tw, "max timestamp\t%d\n", rep.MaxTimestamp)
+
+	fmt.Fprintf(tw, "\nexpiration\tcount\n")
+	for _, b := range rep.Expirations {
+		fmt.Fprintf(tw, "%s\t%d\n", b.Bucket, b.Count)
+	}
+
+	fmt.Fprintf(tw, "\nsegment\tusers\tadditions\tremovals\n")
+	for _, sc := range rep.TopSegments {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", sc.Segment, sc.Users, sc.Additions, sc.Removals)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,58 @@
+package bss
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestInspect(t *testing.T) {
+	const input = `12345:100:1440:0:1700000100;101:0:0:1700000000
+12346:100:20160:0:0#101:-1:0:0
+6D92078A-8246-4BA4-AE5B-76104861E7DC:100:1440:0:0^3
+`
+
+	dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := Inspect(dr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Users != 3 || rep.UsersByDomain["xandr"] != 2 || rep.UsersByDomain["idfa"] != 1 {
+		t.Fatalf("invalid users: %+v", rep)
+	}
+
+	if rep.UniqueSegments != 2 || rep.Additions != 4 || rep.Removals != 1 || rep.RemovalRatio != 0.2 {
+		t.Fatalf("invalid segments: %+v", rep)
+	}
+
+	if len(rep.TopSegments) != 1 || rep.TopSegments[0] != (SegmentCount{Segment: "100", Users: 3, Additions: 3}) {
+		t.Fatalf("invalid top segments: %+v", rep.TopSegments)
+	}
+
+	expected := []ExpirationBucket{{"default", 1}, {"1d", 2}, {"30d", 1}}
+	if len(rep.Expirations) != len(expected) {
+		t.Fatalf("invalid expirations: %+v", rep.Expirations)
+	}
+	for i := range expected {
+		if rep.Expirations[i] != expected[i] {
+			t.Fatalf("invalid expirations: %+v", rep.Expirations)
+		}
+	}
+
+	if rep.MinTimestamp != 1700000000 || rep.MaxTimestamp != 1700000100 {
+		t.Fatalf("invalid timestamps: %d %d", rep.MinTimestamp, rep.MaxTimestamp)
+	}
+
+	var out bytes.Buffer
+	if err := rep.WriteTable(&out); err != nil {
+		t.Fatal(err)
+	}
+	t.Log("\n" + out.String())
+}
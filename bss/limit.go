@@ -0,0 +1,146 @@
+package bss
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// Priority defines which memberships SegmentLimit keeps.
+type Priority int
+
+const (
+	PriorityRank      Priority = iota // lowest SegmentLimit.Rank first, segments without rank last
+	PriorityValue                     // highest Segment.Value first
+	PriorityTimestamp                 // most recent Segment.Timestamp first
+)
+
+// LimitStats contains SegmentLimit counters.
+type LimitStats struct {
+	Users           int // processed users
+	TrimmedUsers    int // users with trimmed memberships
+	TrimmedSegments int
+	Segments        map[xgen.SegmentKey]int // trimmed memberships per segment
+}
+
+// SegmentLimit is a Stage keeping at most Max added memberships per user. Memberships with the highest
+// priority are kept, ties keep the input order. Removals are always kept and are not counted.
+type SegmentLimit struct {
+	Max      int
+	Priority Priority
+	Rank     map[xgen.SegmentKey]int                  // segment ranks for PriorityRank, 1 is the highest
+	OnTrim   func(uid string, trimmed []xgen.Segment) // called for every user with trimmed memberships
+
+	stats LimitStats
+}
+
+// Process implements Stage.
+func (sl *SegmentLimit) Process(ur *xgen.UserRecord) (*xgen.UserRecord, error) {
+	sl.stats.Users++
+
+	added := 0
+	for i := range ur.Segments {
+		if ur.Segments[i].Expiration != xgen.Expired {
+			added++
+		}
+	}
+
+	if sl.Max <= 0 || added <= sl.Max {
+		return ur, nil
+	}
+
+	// indexes of added memberships in priority order
+	order := make([]int, 0, added)
+	for i := range ur.Segments {
+		if ur.Segments[i].Expiration != xgen.Expired {
+			order = append(order, i)
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return sl.less(&ur.Segments[order[i]], &ur.Segments[order[j]])
+	})
+
+	trim := make([]bool, len(ur.Segments))
+	for _, i := range order[sl.Max:] {
+		trim[i] = true
+	}
+
+	var trimmed []xgen.Segment
+	kept := make([]xgen.Segment, 0, len(ur.Segments)-len(order)+sl.Max)
+
+	for i, seg := range ur.Segments {
+		if trim[i] {
+			trimmed = append(trimmed, seg)
+			continue
+		}
+		kept = append(kept, seg)
+	}
+
+	ur.Segments = kept
+
+	if sl.stats.Segments == nil {
+		sl.stats.Segments = make(map[xgen.SegmentKey]int)
+	}
+
+	sl.stats.TrimmedUsers++
+	sl.stats.TrimmedSegments += len(trimmed)
+	for i := range trimmed {
+		sl.stats.Segments[trimmed[i].Key()]++
+	}
+
+	if sl.OnTrim != nil {
+		sl.OnTrim(ur.UID, trimmed)
+	}
+
+	return ur, nil
+}
+
+// less reports whether membership a has higher priority than b.
+func (sl *SegmentLimit) less(a, b *xgen.Segment) bool {
+	switch sl.Priority {
+	case PriorityValue:
+		return a.Value > b.Value
+	case PriorityTimestamp:
+		return a.Timestamp > b.Timestamp
+	}
+
+	ra, oka := sl.Rank[a.Key()]
+	rb, okb := sl.Rank[b.Key()]
+
+	if oka != okb {
+		return oka
+	}
+
+	return ra < rb
+}
+
+// Stats returns counters of processed users.
+func (sl *SegmentLimit) Stats() LimitStats {
+	return sl.stats
+}
+
+// WriteStats writes counters in the audit log format followed by trimmed memberships per segment.
+func (sl *SegmentLimit) WriteStats(w io.Writer) error {
+	s := sl.stats
+	if _, err := fmt.Fprintf(w, "limit: users %d, trimmed users %d, trimmed segments %d\n",
+		s.Users, s.TrimmedUsers, s.TrimmedSegments); err != nil {
+		return err
+	}
+
+	keys := make([]xgen.SegmentKey, 0, len(s.Segments))
+	for k := range s.Segments {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "limit: segment %s trimmed %d\n", k, s.Segments[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package bss
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestSegmentLimit(t *testing.T) {
+	segments := []xgen.Segment{
+		{ID: 100, Value: 5, Timestamp: 300},
+		{ID: 101, Expiration: xgen.Expired},
+		{ID: 102, Value: 7, Timestamp: 100},
+		{ID: 103, Value: 1, Timestamp: 400},
+		{ID: 104, Value: 7, Timestamp: 200},
+	}
+
+	tests := []struct {
+		name     string
+		limit    SegmentLimit
+		expected []int32
+		trimmed  []int32
+	}{
+		{"rank", SegmentLimit{Max: 2, Rank: map[xgen.SegmentKey]int{{ID: 104}: 1, {ID: 100}: 2, {ID: 102}: 3}}, []int32{100, 101, 104}, []int32{102, 103}},
+		{"value", SegmentLimit{Max: 2, Priority: PriorityValue}, []int32{101, 102, 104}, []int32{100, 103}},
+		{"timestamp", SegmentLimit{Max: 3, Priority: PriorityTimestamp}, []int32{100, 101, 103, 104}, []int32{102}},
+		{"not reached", SegmentLimit{Max: 4}, []int32{100, 101, 102, 103, 104}, nil},
+		{"ties", SegmentLimit{Max: 1}, []int32{100, 101}, []int32{102, 103, 104}},
+	}
+
+	for _, tt := range tests {
+		var trimmed []int32
+
+		tt.limit.OnTrim = func(uid string, segs []xgen.Segment) {
+			for _, seg := range segs {
+				trimmed = append(trimmed, seg.ID)
+			}
+		}
+
+		ur := &xgen.UserRecord{UID: "1234567890", Segments: append([]xgen.Segment(nil), segments...)}
+
+		ur, err := tt.limit.Process(ur)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []int32
+		for _, seg := range ur.Segments {
+			ids = append(ids, seg.ID)
+		}
+
+		if !reflect.DeepEqual(ids, tt.expected) || !reflect.DeepEqual(trimmed, tt.trimmed) {
+			t.Fatalf("%s:\nexpected: %v %v\nactual  : %v %v", tt.name, tt.expected, tt.trimmed, ids, trimmed)
+		}
+	}
+}
+
+func TestSegmentLimitStats(t *testing.T) {
+	const input = `1:100;101;102
+2:102;100#101
+3:102;100;101
+`
+
+	sl := &SegmentLimit{Max: 1, Rank: map[xgen.SegmentKey]int{{ID: 100}: 1}}
+
+	dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+
+	df, err := NewSegmentDataFormatter(&out, FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Convert(dr, df, &ConvertOptions{Stages: Pipeline{sl}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = "1:100\n2:100#101\n3:100\n"
+	if out.String() != expected {
+		t.Fatalf("\nexpected:\n%s\nactual:\n%s", expected, out.String())
+	}
+
+	var stats strings.Builder
+	sl.WriteStats(&stats)
+
+	const expectedStats = `limit: users 3, trimmed users 3, trimmed segments 5
+limit: segment 101 trimmed 2
+limit: segment 102 trimmed 3
+`
+	if stats.String() != expectedStats {
+		t.Fatalf("\nexpected:\n%s\nactual:\n%s", expectedStats, stats.String())
+	}
+}
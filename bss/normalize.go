@@ -0,0 +1,178 @@
+package bss
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// MergeRule defines which of duplicate added memberships Normalizer keeps.
+type MergeRule int
+
+const (
+	MergeMaxExpiration   MergeRule = iota // the longest expiration, DefaultExpiration is the shortest
+	MergeLatestTimestamp                  // the most recent timestamp
+	MergeMaxValue                         // the highest value
+)
+
+// NormalizeStats contains Normalizer counters.
+type NormalizeStats struct {
+	Users     int // processed users
+	Merged    int // duplicate memberships merged into another one
+	Conflicts int // segments both added and removed
+}
+
+// Normalizer merges duplicate memberships of a user so every segment appears once in the output.
+// Duplicate additions are merged according to Merge, ties keep the first one. If a segment is both
+// added and removed, the membership with the later timestamp is kept, the removal on equal timestamps.
+//
+// Normalizer can be used as a Stage or as a formatter option, see WithNormalizer.
+type Normalizer struct {
+	Merge MergeRule
+	Sort  bool // sort memberships by segment ID, then by member ID and code, for deterministic output
+
+	stats NormalizeStats
+}
+
+// Process implements Stage.
+func (n *Normalizer) Process(ur *xgen.UserRecord) (*xgen.UserRecord, error) {
+	n.Normalize(ur)
+	return ur, nil
+}
+
+// Normalize merges duplicate memberships of the record in place. Memberships keep the order
+// of the first occurrence of the segment unless Sort is set.
+func (n *Normalizer) Normalize(ur *xgen.UserRecord) {
+	n.stats.Users++
+
+	if hasDuplicates(ur.Segments) {
+		ur.Segments = n.merge(ur.Segments)
+	}
+
+	if n.Sort {
+		sort.SliceStable(ur.Segments, func(i, j int) bool {
+			return compareSegments(&ur.Segments[i], &ur.Segments[j]) < 0
+		})
+	}
+}
+
+// hasDuplicates reports whether memberships may contain duplicate segments.
+// Short lists are checked without allocations.
+func hasDuplicates(segs []xgen.Segment) bool {
+	if len(segs) > 16 {
+		return true
+	}
+
+	for i := 1; i < len(segs); i++ {
+		for j := 0; j < i; j++ {
+			if segs[i].ID == segs[j].ID && segs[i].Code == segs[j].Code && segs[i].MemberID == segs[j].MemberID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (n *Normalizer) merge(segs []xgen.Segment) []xgen.Segment {
+	type group struct {
+		add, rem *xgen.Segment
+	}
+
+	groups := make(map[xgen.SegmentKey]*group, len(segs))
+	order := make([]*group, 0, len(segs))
+
+	for i := range segs {
+		seg := &segs[i]
+		key := seg.Key()
+
+		g := groups[key]
+		if g == nil {
+			g = &group{}
+			groups[key] = g
+			order = append(order, g)
+		}
+
+		switch {
+		case seg.Expiration == xgen.Expired && g.rem == nil:
+			g.rem = seg
+		case seg.Expiration == xgen.Expired:
+			n.stats.Merged++
+			if seg.Timestamp > g.rem.Timestamp {
+				g.rem = seg
+			}
+		case g.add == nil:
+			g.add = seg
+		default:
+			n.stats.Merged++
+			if n.better(seg, g.add) {
+				g.add = seg
+			}
+		}
+	}
+
+	out := make([]xgen.Segment, 0, len(order))
+
+	for _, g := range order {
+		switch {
+		case g.add != nil && g.rem != nil:
+			n.stats.Conflicts++
+			if g.add.Timestamp > g.rem.Timestamp {
+				out = append(out, *g.add)
+			} else {
+				out = append(out, *g.rem)
+			}
+		case g.add != nil:
+			out = append(out, *g.add)
+		default:
+			out = append(out, *g.rem)
+		}
+	}
+
+	return out
+}
+
+// better reports whether added membership a should replace b.
+func (n *Normalizer) better(a, b *xgen.Segment) bool {
+	switch n.Merge {
+	case MergeLatestTimestamp:
+		return a.Timestamp > b.Timestamp
+	case MergeMaxValue:
+		return a.Value > b.Value
+	}
+	return a.Expiration > b.Expiration
+}
+
+func compareSegments(a, b *xgen.Segment) int {
+	switch {
+	case a.ID != b.ID:
+		if a.ID < b.ID {
+			return -1
+		}
+		return 1
+	case a.MemberID != b.MemberID:
+		if a.MemberID < b.MemberID {
+			return -1
+		}
+		return 1
+	case a.Code < b.Code:
+		return -1
+	case a.Code > b.Code:
+		return 1
+	}
+	return 0
+}
+
+// Stats returns counters of processed users.
+func (n *Normalizer) Stats() NormalizeStats {
+	return n.stats
+}
+
+// WriteStats writes counters in the audit log format.
+func (n *Normalizer) WriteStats(w io.Writer) error {
+	s := n.stats
+	_, err := fmt.Fprintf(w, "normalize: users %d, merged segments %d, conflicts %d\n", s.Users, s.Merged, s.Conflicts)
+	return err
+}
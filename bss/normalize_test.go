@@ -0,0 +1,124 @@
+package bss
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestNormalizer(t *testing.T) {
+	segments := []xgen.Segment{
+		{ID: 102, Expiration: 60, Value: 1, Timestamp: 100},
+		{ID: 100, Expiration: 1440, Value: 2, Timestamp: 200},
+		{ID: 102, Expiration: 1440, Value: 0, Timestamp: 300},
+		{ID: 102, Expiration: 0, Value: 5, Timestamp: 50},
+		{ID: 101, Timestamp: 500},
+		{ID: 101, Expiration: xgen.Expired, Timestamp: 400},
+		{ID: 100, Expiration: xgen.Expired, Timestamp: 200},
+		{Code: "cart", MemberID: 1234},
+		{Code: "cart", MemberID: 1234, Expiration: xgen.Expired, Timestamp: 1},
+	}
+
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		expected   []xgen.Segment
+	}{
+		{"max expiration", Normalizer{Merge: MergeMaxExpiration}, []xgen.Segment{
+			{ID: 102, Expiration: 1440, Value: 0, Timestamp: 300},
+			{ID: 100, Expiration: xgen.Expired, Timestamp: 200},
+			{ID: 101, Timestamp: 500},
+			{Code: "cart", MemberID: 1234, Expiration: xgen.Expired, Timestamp: 1},
+		}},
+		{"latest timestamp", Normalizer{Merge: MergeLatestTimestamp}, []xgen.Segment{
+			{ID: 102, Expiration: 1440, Value: 0, Timestamp: 300},
+			{ID: 100, Expiration: xgen.Expired, Timestamp: 200},
+			{ID: 101, Timestamp: 500},
+			{Code: "cart", MemberID: 1234, Expiration: xgen.Expired, Timestamp: 1},
+		}},
+		{"max value sorted", Normalizer{Merge: MergeMaxValue, Sort: true}, []xgen.Segment{
+			{Code: "cart", MemberID: 1234, Expiration: xgen.Expired, Timestamp: 1},
+			{ID: 100, Expiration: xgen.Expired, Timestamp: 200},
+			{ID: 101, Timestamp: 500},
+			{ID: 102, Expiration: 0, Value: 5, Timestamp: 50},
+		}},
+	}
+
+	for _, tt := range tests {
+		ur := &xgen.UserRecord{UID: "1234567890", Segments: append([]xgen.Segment(nil), segments...)}
+
+		tt.normalizer.Normalize(ur)
+
+		if !reflect.DeepEqual(ur.Segments, tt.expected) {
+			t.Fatalf("%s:\nexpected: %+v\nactual  : %+v", tt.name, tt.expected, ur.Segments)
+		}
+
+		expectedStats := NormalizeStats{Users: 1, Merged: 2, Conflicts: 3}
+		if tt.normalizer.Stats() != expectedStats {
+			t.Fatalf("%s:\nexpected: %+v\nactual  : %+v", tt.name, expectedStats, tt.normalizer.Stats())
+		}
+	}
+}
+
+func TestFormatterWithNormalizer(t *testing.T) {
+	users := func() []*xgen.UserRecord {
+		return []*xgen.UserRecord{
+			{UID: "1", Segments: []xgen.Segment{{ID: 101}, {ID: 100, Expiration: 60}, {ID: 100, Expiration: 120}}},
+			{UID: "2", Segments: []xgen.Segment{{ID: 100, Timestamp: 10}, {ID: 100, Expiration: xgen.Expired, Timestamp: 20}}},
+		}
+	}
+
+	expected := []*xgen.UserRecord{
+		{UID: "1", Segments: []xgen.Segment{{ID: 100, Expiration: 120}, {ID: 101}}},
+		{UID: "2", Segments: []xgen.Segment{{ID: 100, Expiration: xgen.Expired, Timestamp: 20}}},
+	}
+
+	for _, format := range []DataFormat{FormatText, FormatAvro} {
+		var buf bytes.Buffer
+
+		n := &Normalizer{Sort: true}
+
+		df, err := NewSegmentDataFormatter(&buf, format, &xgen.FullFormat, WithNormalizer(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := df.Append(users()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := df.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		dr, err := NewSegmentDataReader(&buf, format, &xgen.FullFormat)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var actual []*xgen.UserRecord
+		for {
+			ur, err := dr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual = append(actual, ur)
+		}
+
+		if len(actual) != len(expected) {
+			t.Fatalf("%s: unexpected number of users: %d", format, len(actual))
+		}
+
+		for i := range expected {
+			if !reflect.DeepEqual(actual[i].Segments, expected[i].Segments) {
+				t.Fatalf("%s:\nexpected: %+v\nactual  : %+v", format, expected[i].Segments, actual[i].Segments)
+			}
+		}
+	}
+}
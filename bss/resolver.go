@@ -0,0 +1,198 @@
+package bss
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// SegmentMapping maps segment code of a member to segment ID.
+type SegmentMapping struct {
+	ID       int32  `json:"id"`
+	Code     string `json:"code"`
+	MemberID int32  `json:"member_id"`
+}
+
+// MappingSource loads segment mappings, e.g. from a file or the Segment API.
+type MappingSource interface {
+	LoadMappings() ([]SegmentMapping, error)
+}
+
+// CSVMappings reads mappings from CSV with header containing id, code and member_id columns.
+type CSVMappings struct {
+	R io.Reader
+}
+
+// LoadMappings implements MappingSource.
+func (cm *CSVMappings) LoadMappings() ([]SegmentMapping, error) {
+	cr := csv.NewReader(cm.R)
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read csv header: %w", err)
+	}
+
+	columns := map[string]int{"id": -1, "code": -1, "member_id": -1}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for name, i := range columns {
+		if i < 0 {
+			return nil, fmt.Errorf("csv column %s not found", name)
+		}
+	}
+
+	var list []SegmentMapping
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		id, err := strconv.ParseInt(rec[columns["id"]], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid id: %w", line, err)
+		}
+
+		memberID, err := strconv.ParseInt(rec[columns["member_id"]], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid member_id: %w", line, err)
+		}
+
+		list = append(list, SegmentMapping{
+			ID:       int32(id),
+			Code:     rec[columns["code"]],
+			MemberID: int32(memberID),
+		})
+	}
+
+	return list, nil
+}
+
+// JSONMappings reads mappings from JSON array of objects with id, code and member_id fields.
+type JSONMappings struct {
+	R io.Reader
+}
+
+// LoadMappings implements MappingSource.
+func (jm *JSONMappings) LoadMappings() ([]SegmentMapping, error) {
+	var list []SegmentMapping
+	if err := json.NewDecoder(jm.R).Decode(&list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// SegmentResolver rewrites segment code and member ID into segment ID or the other way round.
+// Segments which are not found in the mapping are kept as is, or dropped if DropUnknown is set,
+// and counted in Unknown.
+type SegmentResolver struct {
+	DropUnknown bool
+
+	ids     map[xgen.SegmentKey]int32
+	codes   map[int32]SegmentMapping
+	unknown map[xgen.SegmentKey]int
+}
+
+// NewSegmentResolver loads mappings from src. Conflicting mappings are reported as error.
+func NewSegmentResolver(src MappingSource) (*SegmentResolver, error) {
+	list, err := src.LoadMappings()
+	if err != nil {
+		return nil, err
+	}
+
+	sr := &SegmentResolver{
+		ids:     make(map[xgen.SegmentKey]int32),
+		codes:   make(map[int32]SegmentMapping),
+		unknown: make(map[xgen.SegmentKey]int),
+	}
+
+	for _, m := range list {
+		if m.ID == 0 || m.Code == "" || m.MemberID == 0 {
+			return nil, fmt.Errorf("incomplete mapping: %+v", m)
+		}
+
+		key := xgen.SegmentKey{Code: m.Code, MemberID: m.MemberID}
+
+		if id, ok := sr.ids[key]; ok && id != m.ID {
+			return nil, fmt.Errorf("segment %s is mapped to %d and %d", key, id, m.ID)
+		}
+		if prev, ok := sr.codes[m.ID]; ok && prev != m {
+			return nil, fmt.Errorf("segment %d is mapped to %s/%d and %s/%d", m.ID, prev.Code, prev.MemberID, m.Code, m.MemberID)
+		}
+
+		sr.ids[key] = m.ID
+		sr.codes[m.ID] = m
+	}
+
+	return sr, nil
+}
+
+// ToID sets Segment.ID from code and member ID and clears them.
+// Segments which already have ID are not changed.
+func (sr *SegmentResolver) ToID(ur *xgen.UserRecord) {
+	sr.rewrite(ur, func(seg *xgen.Segment) bool {
+		if seg.ID != 0 {
+			return true
+		}
+
+		id, ok := sr.ids[seg.Key()]
+		if !ok {
+			return false
+		}
+
+		seg.ID, seg.Code, seg.MemberID = id, "", 0
+		return true
+	})
+}
+
+// ToCode sets Segment.Code and Segment.MemberID from ID and clears ID.
+// Segments which already have code are not changed.
+func (sr *SegmentResolver) ToCode(ur *xgen.UserRecord) {
+	sr.rewrite(ur, func(seg *xgen.Segment) bool {
+		if seg.Code != "" {
+			return true
+		}
+
+		m, ok := sr.codes[seg.ID]
+		if !ok {
+			return false
+		}
+
+		seg.ID, seg.Code, seg.MemberID = 0, m.Code, m.MemberID
+		return true
+	})
+}
+
+func (sr *SegmentResolver) rewrite(ur *xgen.UserRecord, resolve func(seg *xgen.Segment) bool) {
+	list := ur.Segments[:0]
+
+	for _, seg := range ur.Segments {
+		if !resolve(&seg) {
+			sr.unknown[seg.Key()]++
+			if sr.DropUnknown {
+				continue
+			}
+		}
+		list = append(list, seg)
+	}
+
+	ur.Segments = list
+}
+
+// Unknown returns number of occurrences of segments not found in the mapping.
+func (sr *SegmentResolver) Unknown() map[xgen.SegmentKey]int {
+	return sr.unknown
+}
@@ -0,0 +1,81 @@
+package bss
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestSegmentResolver(t *testing.T) {
+	const mapping = `code,member_id,id
+auto,7,100
+travel,7,101
+`
+
+	sr, err := NewSegmentResolver(&CSVMappings{R: strings.NewReader(mapping)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ur := &xgen.UserRecord{
+		UID: "12345",
+		Segments: []xgen.Segment{
+			{Code: "auto", MemberID: 7, Expiration: 1440},
+			{Code: "sport", MemberID: 7},
+			{ID: 101, Expiration: xgen.Expired},
+		},
+	}
+
+	sr.ToID(ur)
+
+	expected := []xgen.Segment{
+		{ID: 100, Expiration: 1440},
+		{Code: "sport", MemberID: 7},
+		{ID: 101, Expiration: xgen.Expired},
+	}
+
+	if !reflect.DeepEqual(ur.Segments, expected) {
+		t.Fatalf("invalid segments: %+v", ur.Segments)
+	}
+
+	ur.Segments = append(ur.Segments, xgen.Segment{ID: 102})
+
+	sr.DropUnknown = true
+	sr.ToCode(ur)
+
+	expected = []xgen.Segment{
+		{Code: "auto", MemberID: 7, Expiration: 1440},
+		{Code: "sport", MemberID: 7},
+		{Code: "travel", MemberID: 7, Expiration: xgen.Expired},
+	}
+
+	if !reflect.DeepEqual(ur.Segments, expected) {
+		t.Fatalf("invalid segments: %+v", ur.Segments)
+	}
+
+	if n := sr.Unknown()[xgen.SegmentKey{Code: "sport", MemberID: 7}]; n != 1 {
+		t.Fatal("unknown segment should be counted once, got", n)
+	}
+
+	if n := sr.Unknown()[xgen.SegmentKey{ID: 102}]; n != 1 {
+		t.Fatal("dropped segment should be counted once, got", n)
+	}
+}
+
+func TestSegmentResolverConflict(t *testing.T) {
+	const mapping = `[
+		{"id": 100, "code": "auto", "member_id": 7},
+		{"id": 101, "code": "auto", "member_id": 7}
+	]`
+
+	_, err := NewSegmentResolver(&JSONMappings{R: strings.NewReader(mapping)})
+	if err == nil {
+		t.Fatal("should return error")
+	}
+
+	if err.Error() != "segment auto/7 is mapped to 100 and 101" {
+		t.Fatal("invalid error message:", err.Error())
+	}
+}
@@ -0,0 +1,225 @@
+// Package s3sink stores BSS files in S3 compatible object storage using multipart uploads.
+package s3sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/milla-v/xandr/bss"
+)
+
+const (
+	// MinPartSize is the minimum size of multipart upload parts except the last one.
+	MinPartSize = 5 << 20
+
+	defaultPartSize = 8 << 20
+)
+
+// Error is returned when storage responds with error status.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("s3 error %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Config describes bucket location and credentials. Buckets are addressed in path style.
+type Config struct {
+	Endpoint     string // e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Region       string // us-east-1 if empty
+	Bucket       string
+	Prefix       string // key prefix of created objects, e.g. "bss/"
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // token of temporary credentials, optional
+	PartSize     int    // multipart part size, 8MB if zero
+	Transport    http.RoundTripper
+}
+
+// Sink creates objects in the bucket. Objects smaller than PartSize are uploaded with a single request,
+// larger objects are uploaded in parts while written and completed on Commit.
+// Requests are signed and sent by minio-go.
+type Sink struct {
+	cfg Config
+	c   minio.Core
+}
+
+// New creates S3 sink.
+func New(cfg Config) (*Sink, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("endpoint and bucket are required")
+	}
+
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("access key and secret key are required")
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	if cfg.PartSize == 0 {
+		cfg.PartSize = defaultPartSize
+	}
+
+	if cfg.PartSize < MinPartSize {
+		return nil, fmt.Errorf("part size %d is less than %d", cfg.PartSize, MinPartSize)
+	}
+
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+		return nil, fmt.Errorf("invalid endpoint %q, expected scheme://host[:port]", cfg.Endpoint)
+	}
+
+	c, err := minio.NewCore(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, cfg.SessionToken),
+		Secure:       u.Scheme == "https",
+		Transport:    cfg.Transport,
+		Region:       cfg.Region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sink{cfg: cfg, c: *c}, nil
+}
+
+// Create starts writing object Prefix + name.
+func (s *Sink) Create(ctx context.Context, name string) (bss.SinkFile, error) {
+	if name == "" {
+		return nil, errors.New("empty object name")
+	}
+
+	return &object{s: s, ctx: ctx, key: s.cfg.Prefix + name}, nil
+}
+
+type object struct {
+	s        *Sink
+	ctx      context.Context
+	key      string
+	buf      bytes.Buffer
+	uploadID string
+	parts    []minio.CompletePart
+	err      error
+}
+
+func (o *object) Write(p []byte) (int, error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+
+	n, _ := o.buf.Write(p)
+
+	for o.buf.Len() >= o.s.cfg.PartSize {
+		if err := o.uploadPart(o.buf.Next(o.s.cfg.PartSize)); err != nil {
+			o.err = err
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (o *object) Commit() error {
+	if o.err != nil {
+		o.Abort()
+		return o.err
+	}
+
+	if o.uploadID == "" {
+		data := o.buf.Bytes()
+		md5sum, sha := checksums(data)
+		_, err := o.s.c.PutObject(o.ctx, o.s.cfg.Bucket, o.key, bytes.NewReader(data), int64(len(data)), md5sum, sha, minio.PutObjectOptions{})
+		return convertError(err)
+	}
+
+	if o.buf.Len() > 0 {
+		if err := o.uploadPart(o.buf.Bytes()); err != nil {
+			o.Abort()
+			return err
+		}
+	}
+
+	if _, err := o.s.c.CompleteMultipartUpload(o.ctx, o.s.cfg.Bucket, o.key, o.uploadID, o.parts, minio.PutObjectOptions{}); err != nil {
+		o.Abort()
+		return convertError(err)
+	}
+
+	return nil
+}
+
+func (o *object) Abort() error {
+	if o.uploadID == "" {
+		return nil
+	}
+
+	err := o.s.c.AbortMultipartUpload(context.Background(), o.s.cfg.Bucket, o.key, o.uploadID)
+	o.uploadID = ""
+
+	return convertError(err)
+}
+
+func (o *object) uploadPart(data []byte) error {
+	if o.uploadID == "" {
+		id, err := o.s.c.NewMultipartUpload(o.ctx, o.s.cfg.Bucket, o.key, minio.PutObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("create multipart upload of %s: %w", o.key, convertError(err))
+		}
+		o.uploadID = id
+	}
+
+	number := len(o.parts) + 1
+	md5sum, sha := checksums(data)
+
+	part, err := o.s.c.PutObjectPart(o.ctx, o.s.cfg.Bucket, o.key, o.uploadID, number, bytes.NewReader(data), int64(len(data)),
+		minio.PutObjectPartOptions{Md5Base64: md5sum, Sha256Hex: sha})
+	if err != nil {
+		return fmt.Errorf("upload part %d of %s: %w", number, o.key, convertError(err))
+	}
+
+	o.parts = append(o.parts, minio.CompletePart{PartNumber: number, ETag: part.ETag})
+
+	return nil
+}
+
+// checksums returns base64 MD5 and hex SHA-256 of data, so the payload is signed and verified
+// by the storage without streaming signatures.
+func checksums(data []byte) (string, string) {
+	m := md5.Sum(data)
+	s := sha256.Sum256(data)
+	return base64.StdEncoding.EncodeToString(m[:]), hex.EncodeToString(s[:])
+}
+
+// convertError returns Error for error responses of the storage.
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// errors in the body of 200 OK responses have no status code
+	if er := minio.ToErrorResponse(err); er.Code != "" {
+		return &Error{StatusCode: er.StatusCode, Code: er.Code, Message: er.Message}
+	}
+
+	return err
+}
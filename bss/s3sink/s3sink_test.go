@@ -0,0 +1,159 @@
+package s3sink_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/s3sink"
+	"github.com/milla-v/xandr/bss/s3sink/s3test"
+)
+
+func TestSink(t *testing.T) {
+	srv := s3test.NewServer("AKID")
+	defer srv.Close()
+
+	sink, err := s3sink.New(s3sink.Config{
+		Endpoint:  srv.URL,
+		Bucket:    "segments",
+		Prefix:    "bss/",
+		AccessKey: "AKID",
+		SecretKey: "secret",
+		PartSize:  s3sink.MinPartSize,
+		Transport: srv.Client().Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	f, err := sink.Create(ctx, "small.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Write([]byte("1234567890:100\n"))
+
+	if _, ok := srv.Object("segments", "bss/small.txt"); ok {
+		t.Fatal("object is visible before commit")
+	}
+
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := srv.Object("segments", "bss/small.txt"); string(data) != "1234567890:100\n" {
+		t.Fatalf("unexpected object: %q", data)
+	}
+
+	large := bytes.Repeat([]byte("1234567890:100;101\n"), 600000) // 11.4MB, 3 parts
+
+	f, err = sink.Create(ctx, "large part.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for chunk := large; len(chunk) > 0; {
+		n := min(len(chunk), 100000)
+		if _, err := f.Write(chunk[:n]); err != nil {
+			t.Fatal(err)
+		}
+		chunk = chunk[n:]
+	}
+
+	if srv.Uploads() != 1 {
+		t.Fatal("multipart upload is not started")
+	}
+
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, _ := srv.Object("segments", "bss/large part.txt"); !bytes.Equal(data, large) {
+		t.Fatalf("unexpected object size %d", len(data))
+	}
+
+	f, err = sink.Create(ctx, "aborted.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Write(large)
+
+	if err := f.Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	if srv.Uploads() != 0 {
+		t.Fatal("multipart upload is not aborted")
+	}
+
+	if keys := srv.Keys("segments"); len(keys) != 2 {
+		t.Fatal("unexpected keys:", keys)
+	}
+}
+
+func TestSinkError(t *testing.T) {
+	srv := s3test.NewServer("AKID")
+	defer srv.Close()
+
+	sink, err := s3sink.New(s3sink.Config{
+		Endpoint:  srv.URL,
+		Bucket:    "segments",
+		AccessKey: "OTHER",
+		SecretKey: "secret",
+		Transport: srv.Client().Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := sink.Create(context.Background(), "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s3err *s3sink.Error
+	if err := f.Commit(); !errors.As(err, &s3err) || s3err.Code != "InvalidAccessKeyId" {
+		t.Fatal("expected access key error, got", err)
+	}
+}
+
+func TestSinkSessionToken(t *testing.T) {
+	srv := s3test.NewServer("AKID")
+	srv.SessionToken = "token"
+	defer srv.Close()
+
+	for _, token := range []string{"", "token"} {
+		sink, err := s3sink.New(s3sink.Config{
+			Endpoint:     srv.URL,
+			Bucket:       "segments",
+			AccessKey:    "AKID",
+			SecretKey:    "secret",
+			SessionToken: token,
+			Transport:    srv.Client().Transport,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := sink.Create(context.Background(), "a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.Write([]byte("1234567890:100\n"))
+
+		var s3err *s3sink.Error
+		err = f.Commit()
+
+		switch {
+		case token == "" && (!errors.As(err, &s3err) || s3err.Code != "InvalidToken"):
+			t.Fatal("expected token error, got", err)
+		case token != "" && err != nil:
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Package s3test provides a fake S3 compatible storage for testing.
+package s3test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type upload struct {
+	key   string
+	parts map[int][]byte
+}
+
+// Server is a fake S3 storage keeping objects in memory. It checks the access key and payload hashes
+// of signed requests, unless the payload is unsigned, but not signatures. The server uses TLS, clients should use the transport of Client().
+type Server struct {
+	*httptest.Server
+
+	SessionToken string // token required in requests if not empty
+
+	accessKey string
+
+	mu      sync.Mutex
+	objects map[string][]byte // bucket/key -> data
+	uploads map[string]*upload
+	next    int
+}
+
+// NewServer starts fake storage which accepts requests signed with the access key.
+func NewServer(accessKey string) *Server {
+	s := &Server{
+		accessKey: accessKey,
+		objects:   make(map[string][]byte),
+		uploads:   make(map[string]*upload),
+	}
+
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// Object returns data of the object.
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.objects[bucket+"/"+key]
+
+	return data, ok
+}
+
+// Keys returns sorted keys of objects in the bucket.
+func (s *Server) Keys(bucket string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for name := range s.objects {
+		if key, ok := strings.CutPrefix(name, bucket+"/"); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Uploads returns number of multipart uploads neither completed nor aborted.
+func (s *Server) Uploads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.uploads)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/") {
+		writeError(w, http.StatusForbidden, "InvalidAccessKeyId", "invalid access key")
+		return
+	}
+
+	if s.SessionToken != "" && r.Header.Get("X-Amz-Security-Token") != s.SessionToken {
+		writeError(w, http.StatusForbidden, "InvalidToken", "invalid session token")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	if h := r.Header.Get("X-Amz-Content-Sha256"); h != "UNSIGNED-PAYLOAD" && h != hex.EncodeToString(sum[:]) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash mismatch")
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(name, "/")
+	if bucket == "" || key == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "bucket and key are required")
+		return
+	}
+
+	q := r.URL.Query()
+	uploadID := q.Get("uploadId")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey", "object not found")
+			return
+		}
+		w.Write(data)
+
+	case r.Method == http.MethodPut && uploadID == "":
+		s.objects[name] = body
+
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		s.next++
+		id := fmt.Sprintf("upload-%d", s.next)
+		s.uploads[id] = &upload{key: name, parts: make(map[int][]byte)}
+		writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadID string `xml:"UploadId"`
+		}{Bucket: bucket, Key: key, UploadID: id})
+
+	case r.Method == http.MethodPut:
+		u, ok := s.uploads[uploadID]
+		number, err := strconv.Atoi(q.Get("partNumber"))
+		if !ok || u.key != name || err != nil || number < 1 {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "upload not found")
+			return
+		}
+		u.parts[number] = body
+		w.Header().Set("ETag", etag(body))
+
+	case r.Method == http.MethodPost:
+		s.complete(w, name, uploadID, body)
+
+	case r.Method == http.MethodDelete && uploadID != "":
+		if _, ok := s.uploads[uploadID]; !ok {
+			writeError(w, http.StatusNotFound, "NoSuchUpload", "upload not found")
+			return
+		}
+		delete(s.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+	}
+}
+
+func (s *Server) complete(w http.ResponseWriter, name, uploadID string, body []byte) {
+	u, ok := s.uploads[uploadID]
+	if !ok || u.key != name {
+		writeError(w, http.StatusNotFound, "NoSuchUpload", "upload not found")
+		return
+	}
+
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+
+	if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		writeError(w, http.StatusBadRequest, "MalformedXML", "invalid part list")
+		return
+	}
+
+	var data bytes.Buffer
+
+	for i, p := range req.Parts {
+		part, ok := u.parts[p.PartNumber]
+		// like S3, ETags are accepted with or without quotes
+		if !ok || strings.Trim(etag(part), `"`) != strings.Trim(p.ETag, `"`) {
+			writeError(w, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %d not found", p.PartNumber))
+			return
+		}
+		if i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			writeError(w, http.StatusBadRequest, "InvalidPartOrder", "parts are not in ascending order")
+			return
+		}
+		data.Write(part)
+	}
+
+	s.objects[name] = data.Bytes()
+	delete(s.uploads, uploadID)
+
+	bucket, key, _ := strings.Cut(name, "/")
+
+	writeXML(w, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Bucket: bucket, Key: key, ETag: etag(data.Bytes())})
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, errCode, msg string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: errCode, Message: msg})
+}
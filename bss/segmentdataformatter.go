@@ -2,11 +2,22 @@ package bss
 
 import (
 	"bufio"
+	"bytes"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
 	"errors"
+	"hash"
 	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/milla-v/xandr/bss/avro"
 	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/metrics"
 )
 
 type DataFormat string
@@ -16,28 +27,135 @@ const (
 	FormatAvro DataFormat = "avro"
 )
 
+// RecordWriter writes batches of user records, e.g. SegmentDataFormatter or SplitWriter.
+type RecordWriter interface {
+	Append(users []*xgen.UserRecord) error
+	Fields() []xgen.SegmentFieldName // segment fields the writer can represent
+}
+
 // SegmentDataFormatter provides writing user-segments data to a writer stream according Legacy BSS file format
 // or Avro format described on https://learn.microsoft.com/en-us/xandr/bidders/uploading-segment-data-using-bss
 type SegmentDataFormatter struct {
 	format      DataFormat
 	w           *bufio.Writer
+	hash        hash.Hash
 	textEncoder *xgen.TextEncoder
 	avroEncoder *avro.AvroWriter
+	normalizer  *Normalizer
+	instrument  metrics.Instrument
+
+	canonical     bool
+	canonicalAvro *avro.CanonicalWriter
+	sortOpts      SortOptions
+	pending       []encodedUser // sorted run in memory
+	runs          []*os.File    // sorted runs written to temporary files
+	pendingBytes  int64         // encoded users in memory and in runs
+}
+
+// encodedUser is a user encoded in canonical mode waiting to be sorted.
+type encodedUser struct {
+	UID    string
+	Domain xgen.Domain
+	Data   []byte
+}
+
+// compareEncoded orders encoded users by UID and domain. Duplicate users are ordered by their encoding.
+func compareEncoded(a, b *encodedUser) int {
+	switch {
+	case a.UID < b.UID:
+		return -1
+	case a.UID > b.UID:
+		return 1
+	case a.Domain < b.Domain:
+		return -1
+	case a.Domain > b.Domain:
+		return 1
+	}
+	return bytes.Compare(a.Data, b.Data)
+}
+
+// FormatterOption configures SegmentDataFormatter.
+type FormatterOption func(df *SegmentDataFormatter)
+
+// WithNormalizer normalizes copies of user records before they are encoded.
+func WithNormalizer(n *Normalizer) FormatterOption {
+	return func(df *SegmentDataFormatter) {
+		df.normalizer = n
+	}
+}
+
+// WithInstrument reports written users, segments and bytes and users which failed to encode.
+func WithInstrument(in metrics.Instrument) FormatterOption {
+	return func(df *SegmentDataFormatter) {
+		df.instrument = in
+	}
+}
+
+// WithCanonical enables canonical mode producing byte-identical output for the same set of users.
+// Users are encoded on Append and written by Close sorted by UID and domain. Encoded users are sorted
+// in memory in chunks of SortOptions.ChunkSize users, see WithSortOptions, and full chunks are written
+// to temporary files merged by Close.
+// Segments are sorted by ID, then by member ID and code, domain names are replaced with domains,
+// device IDs are lowercased and Xandr IDs are written without leading zeros.
+// Avro files are written by avro.CanonicalWriter. Canonical mode enables WithHash.
+func WithCanonical() FormatterOption {
+	return func(df *SegmentDataFormatter) {
+		df.canonical = true
+	}
+}
+
+// WithSortOptions sets the number of users sorted in memory and the directory for temporary files in canonical mode.
+func WithSortOptions(opts *SortOptions) FormatterOption {
+	return func(df *SegmentDataFormatter) {
+		df.sortOpts = *opts
+	}
+}
+
+// WithHash computes SHA-256 of the output returned by Hash.
+func WithHash() FormatterOption {
+	return func(df *SegmentDataFormatter) {
+		df.hash = sha256.New()
+	}
 }
 
 // NewSegmentDataFormatter creates new BSS text SegmentDataFormatter.
-func NewSegmentDataFormatter(w io.Writer, format DataFormat, params *xgen.TextEncoderParameters) (*SegmentDataFormatter, error) {
+func NewSegmentDataFormatter(w io.Writer, format DataFormat, params *xgen.TextEncoderParameters, opts ...FormatterOption) (*SegmentDataFormatter, error) {
 	var err error
 
 	df := &SegmentDataFormatter{
 		format: format,
-		w:      bufio.NewWriter(w),
 	}
 
+	for _, opt := range opts {
+		opt(df)
+	}
+
+	if df.canonical && df.hash == nil {
+		df.hash = sha256.New()
+	}
+
+	if df.hash != nil {
+		w = io.MultiWriter(w, df.hash)
+	}
+
+	if df.instrument != nil {
+		w = io.MultiWriter(w, &meteredWriter{in: df.instrument})
+	}
+
+	df.w = bufio.NewWriter(w)
+
 	if format == FormatText && params == nil {
 		return nil, errors.New("text encoder parameters are not specified")
 	}
 
+	if format == FormatAvro && df.canonical {
+		df.canonicalAvro, err = avro.NewCanonicalWriter(df.w)
+		if err != nil {
+			return nil, err
+		}
+		return df, nil
+	}
+
 	if format == FormatAvro {
 		df.avroEncoder, err = avro.NewAvroWriter(df.w)
 		if err != nil {
@@ -54,31 +172,366 @@ func NewSegmentDataFormatter(w io.Writer, format DataFormat, params *xgen.TextEn
 	return df, nil
 }
 
-// Close flushes buffered text to the writer.
+// Fields returns segment fields the formatter can represent. Avro format represents all of them.
+func (df *SegmentDataFormatter) Fields() []xgen.SegmentFieldName {
+	if df.format == FormatAvro {
+		return []xgen.SegmentFieldName{
+			xgen.SegIdField,
+			xgen.SegCodeField,
+			xgen.MemberIdField,
+			xgen.ExpirationField,
+			xgen.ValueField,
+			xgen.TimestampField,
+		}
+	}
+	return df.textEncoder.Parameters().SegmentFields
+}
+
+// Close writes users kept in canonical mode and flushes buffered data to the writer.
+// Temporary files of canonical mode are removed.
 func (df *SegmentDataFormatter) Close() error {
+	if df.canonical {
+		err := df.writePending()
+		if rerr := df.removeRuns(); err == nil {
+			err = rerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
 	if err := df.w.Flush(); err != nil {
 		return err
 	}
 	return nil
 }
 
-// Append outputs users records to a writer.
+// Hash returns SHA-256 of the output in hex, the same as upload.ContentHash of the written file.
+// It covers data flushed by Close. Hash is empty unless WithHash or WithCanonical is used.
+func (df *SegmentDataFormatter) Hash() string {
+	if df.hash == nil {
+		return ""
+	}
+	return hex.EncodeToString(df.hash.Sum(nil))
+}
+
+// Append outputs users records to a writer. Records are not modified, normalization
+// and canonical mode work on copies.
 func (df *SegmentDataFormatter) Append(users []*xgen.UserRecord) error {
-	if df.format == FormatAvro {
-		return df.avroEncoder.Append(users)
+	if df.normalizer != nil || df.canonical {
+		copies := make([]*xgen.UserRecord, len(users))
+		for i, user := range users {
+			copies[i] = cloneUser(user)
+			if df.normalizer != nil {
+				df.normalizer.Normalize(copies[i])
+			}
+		}
+		users = copies
+	}
+
+	switch {
+	case df.canonical:
+		return df.appendCanonical(users)
+	case df.format == FormatAvro:
+		return df.appendAvro(users)
+	default:
+		return df.appendText(users)
+	}
+}
+
+// written reports users written to the output.
+func (df *SegmentDataFormatter) written(users []*xgen.UserRecord) {
+	if df.instrument == nil || len(users) == 0 {
+		return
 	}
 
+	var segments int
 	for _, user := range users {
+		segments += len(user.Segments)
+	}
+
+	df.instrument.Count(metrics.Users, int64(len(users)))
+	df.instrument.Count(metrics.Segments, int64(segments))
+}
+
+// rejected reports a user which failed to encode.
+func (df *SegmentDataFormatter) rejected() {
+	if df.instrument != nil {
+		df.instrument.Count(metrics.Rejects, 1, metrics.L("stage", "encode"))
+	}
+}
+
+// appendAvro writes users as a block, so users are written all or none.
+func (df *SegmentDataFormatter) appendAvro(users []*xgen.UserRecord) error {
+	if err := df.avroEncoder.Append(users); err != nil {
+		var re *avro.RecordError
+		if errors.As(err, &re) {
+			df.rejected()
+		}
+		return err
+	}
+
+	df.written(users)
+
+	return nil
+}
+
+// appendText writes users until an error, users written before it are reported.
+func (df *SegmentDataFormatter) appendText(users []*xgen.UserRecord) error {
+	for i, user := range users {
 		line, err := df.textEncoder.FormatLine(user)
 		if err != nil {
+			df.written(users[:i])
+			df.rejected()
 			return err
 		}
 
 		_, err = df.w.WriteString(line + "\n")
 		if err != nil {
+			df.written(users[:i])
 			return err
 		}
 	}
 
+	df.written(users)
+
 	return nil
 }
+
+// appendCanonical encodes users to be written by Close. Encoded users are reported as written.
+func (df *SegmentDataFormatter) appendCanonical(users []*xgen.UserRecord) error {
+	for i, user := range users {
+		canonicalize(user)
+
+		var data []byte
+
+		if df.format == FormatAvro {
+			var err error
+			if data, err = df.canonicalAvro.Encode(user); err != nil {
+				df.written(users[:i])
+				df.rejected()
+				return err
+			}
+		} else {
+			line, err := df.textEncoder.FormatLine(user)
+			if err != nil {
+				df.written(users[:i])
+				df.rejected()
+				return err
+			}
+			data = []byte(line + "\n")
+		}
+
+		df.pending = append(df.pending, encodedUser{UID: user.UID, Domain: user.Domain, Data: data})
+		df.pendingBytes += int64(len(data))
+
+		chunkSize := df.sortOpts.ChunkSize
+		if chunkSize <= 0 {
+			chunkSize = defaultChunkSize
+		}
+
+		if len(df.pending) >= chunkSize {
+			if err := df.writeRun(); err != nil {
+				df.written(users[:i+1])
+				return err
+			}
+		}
+	}
+
+	df.written(users)
+
+	return nil
+}
+
+// cloneUser returns a copy of the record with its own segments.
+func cloneUser(ur *xgen.UserRecord) *xgen.UserRecord {
+	c := *ur
+	c.Segments = append([]xgen.Segment(nil), ur.Segments...)
+	return &c
+}
+
+// canonicalize normalizes domain, user ID and segment order of the record.
+func canonicalize(ur *xgen.UserRecord) {
+	ur.Domain = xgen.NormalizeDomain(ur.Domain)
+
+	if ur.Domain == xgen.XandrID {
+		if n, err := strconv.ParseUint(ur.UID, 10, 64); err == nil {
+			ur.UID = strconv.FormatUint(n, 10)
+		}
+	} else {
+		ur.UID = strings.ToLower(ur.UID)
+	}
+
+	sort.SliceStable(ur.Segments, func(i, j int) bool {
+		return compareSegments(&ur.Segments[i], &ur.Segments[j]) < 0
+	})
+}
+
+func (df *SegmentDataFormatter) sortPending() {
+	sort.Slice(df.pending, func(i, j int) bool {
+		return compareEncoded(&df.pending[i], &df.pending[j]) < 0
+	})
+}
+
+// writeRun writes sorted users kept in memory to a temporary file.
+func (df *SegmentDataFormatter) writeRun() error {
+	df.sortPending()
+
+	f, err := os.CreateTemp(df.sortOpts.TempDir, "bss-canonical-*")
+	if err != nil {
+		return err
+	}
+
+	df.runs = append(df.runs, f)
+
+	bw := bufio.NewWriter(f)
+	enc := gob.NewEncoder(bw)
+
+	for i := range df.pending {
+		if err := enc.Encode(&df.pending[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	df.pending = nil
+
+	return nil
+}
+
+// writePending writes users sorted by UID and domain. Runs written to temporary files are merged.
+func (df *SegmentDataFormatter) writePending() error {
+	write := func(u *encodedUser) error {
+		if df.format == FormatAvro {
+			return df.canonicalAvro.WriteEncoded(u.Data)
+		}
+		_, err := df.w.Write(u.Data)
+		return err
+	}
+
+	if len(df.runs) == 0 {
+		df.sortPending()
+
+		for i := range df.pending {
+			if err := write(&df.pending[i]); err != nil {
+				return err
+			}
+		}
+	} else {
+		if len(df.pending) > 0 {
+			if err := df.writeRun(); err != nil {
+				return err
+			}
+		}
+
+		if err := df.mergeRuns(write); err != nil {
+			return err
+		}
+	}
+
+	df.pending = nil
+	df.pendingBytes = 0
+
+	if df.format == FormatAvro {
+		return df.canonicalAvro.Close()
+	}
+
+	return nil
+}
+
+// mergeRuns calls write for users of the runs in order.
+func (df *SegmentDataFormatter) mergeRuns(write func(u *encodedUser) error) error {
+	var h encodedHeap
+
+	for _, f := range df.runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		it := &encodedIterator{dec: gob.NewDecoder(bufio.NewReader(f))}
+		if err := it.next(); err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		h = append(h, it)
+	}
+
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		it := h[0]
+
+		if err := write(&it.user); err != nil {
+			return err
+		}
+
+		if err := it.next(); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			heap.Pop(&h)
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+
+	return nil
+}
+
+// removeRuns removes temporary files of canonical mode.
+func (df *SegmentDataFormatter) removeRuns() error {
+	var errs []error
+
+	for _, f := range df.runs {
+		errs = append(errs, f.Close(), os.Remove(f.Name()))
+	}
+
+	df.runs = nil
+
+	return errors.Join(errs...)
+}
+
+type encodedIterator struct {
+	dec  *gob.Decoder
+	user encodedUser
+}
+
+func (it *encodedIterator) next() error {
+	it.user = encodedUser{}
+	return it.dec.Decode(&it.user)
+}
+
+type encodedHeap []*encodedIterator
+
+func (h encodedHeap) Len() int           { return len(h) }
+func (h encodedHeap) Less(i, j int) bool { return compareEncoded(&h[i].user, &h[j].user) < 0 }
+func (h encodedHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *encodedHeap) Push(x any)        { *h = append(*h, x.(*encodedIterator)) }
+
+func (h *encodedHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// unwritten returns number of bytes buffered or kept in canonical mode, in memory or in temporary files.
+func (df *SegmentDataFormatter) unwritten() int64 {
+	return int64(df.w.Buffered()) + df.pendingBytes
+}
+
+// meteredWriter reports number of written bytes and discards the data.
+type meteredWriter struct {
+	in metrics.Instrument
+}
+
+func (mw *meteredWriter) Write(p []byte) (int, error) {
+	mw.in.Count(metrics.Bytes, int64(len(p)))
+	return len(p), nil
+}
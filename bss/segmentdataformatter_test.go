@@ -3,13 +3,15 @@ package bss
 import (
 	"bytes"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/linkedin/goavro"
 	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/metrics"
 )
 
 var FullFormat = xgen.FullFormat
@@ -30,9 +32,21 @@ UID,SegID
 		SegmentFields: []xgen.SegmentFieldName{xgen.SegIdField},
 	}
 
-	var out bytes.Buffer
+	var out, events bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&events, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
 
-	w, err := NewSegmentDataFormatter(&out, FormatText, &p)
+	reg := metrics.NewRegistry()
+
+	w, err := NewSegmentDataFormatter(&out, FormatText, &p, WithInstrument(metrics.Multi(reg, metrics.NewLogger(logger))))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -40,11 +54,10 @@ UID,SegID
 	var users []*xgen.UserRecord
 
 	lines := strings.Split(strings.TrimSpace(input), "\n")
-	for _, line := range lines[1:] {
+	for i, line := range lines[1:] {
 		columns := strings.Split(line, ",")
-		t.Logf("cols: %+v", columns)
+		logger.Debug("input", "line", i+2, "columns", columns)
 		segID, err := strconv.ParseInt(columns[1], 10, 32)
-		log.Println(line)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -68,7 +81,40 @@ UID,SegID
 		t.Fatal(err)
 	}
 
-	t.Log("generated file:", out.String())
+	t.Log("events:\n" + events.String())
+
+	expected := "12345:55;100\n12346:55;102\n"
+	if out.String() != expected {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, out.String())
+	}
+
+	for _, event := range []string{
+		"level=DEBUG msg=input line=2 columns=\"[12345 100]\"\n",
+		"level=DEBUG msg=xandr_bss_users_total n=2\n",
+		"level=DEBUG msg=xandr_bss_segments_total n=4\n",
+		fmt.Sprintf("level=DEBUG msg=xandr_bss_bytes_total n=%d\n", len(expected)),
+	} {
+		if !strings.Contains(events.String(), event) {
+			t.Fatalf("event %q is not logged", event)
+		}
+	}
+
+	if n := reg.Value(metrics.Users); n != 2 {
+		t.Fatal("unexpected users:", n)
+	}
+
+	// a user failing to encode is counted as a reject, users written before it are counted
+	if err := w.Append([]*xgen.UserRecord{{UID: "1"}, {UID: "a:b"}}); err == nil {
+		t.Fatal("expected collision error")
+	}
+
+	if n := reg.Value(metrics.Rejects, metrics.L("stage", "encode")); n != 1 {
+		t.Fatal("unexpected rejects:", n)
+	}
+
+	if n := reg.Value(metrics.Users); n != 3 {
+		t.Fatal("unexpected users:", n)
+	}
 }
 
 func TestSegmentDataFormatter(t *testing.T) {
@@ -211,3 +257,25 @@ map[segments:[map[code: expiration:1440 id:101 member_id:0 timestamp:0 value:123
 		t.Fatal("\nexpected:", expectedResult, "\nactual  :", result)
 	}
 }
+
+func TestSegmentDataFormatterAvroReject(t *testing.T) {
+	reg := metrics.NewRegistry()
+
+	w, err := NewSegmentDataFormatter(io.Discard, FormatAvro, &xgen.FullFormat, WithInstrument(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// avro users are written as a block, so none of them are counted
+	if err := w.Append([]*xgen.UserRecord{{UID: "1"}, {UID: "a"}}); err == nil {
+		t.Fatal("expected invalid xandr id error")
+	}
+
+	if n := reg.Value(metrics.Rejects, metrics.L("stage", "encode")); n != 1 {
+		t.Fatal("unexpected rejects:", n)
+	}
+
+	if n := reg.Value(metrics.Users); n != 0 {
+		t.Fatal("unexpected users:", n)
+	}
+}
@@ -0,0 +1,134 @@
+package bss
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/milla-v/xandr/bss/avro"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+const maxLineSize = 16 * 1024 * 1024
+
+// LineError is returned by SegmentDataReader.Read when a line cannot be parsed.
+// Reading can continue after LineError.
+type LineError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// RecordReader is implemented by sources of user records. Read returns io.EOF at the end of the stream.
+type RecordReader interface {
+	Read() (*xgen.UserRecord, error)
+}
+
+// SegmentDataReader reads user-segments data written in Legacy BSS text format or Avro format.
+type SegmentDataReader struct {
+	format      DataFormat
+	scanner     *bufio.Scanner
+	textDecoder *xgen.TextDecoder
+	avroReader  *avro.AvroReader
+	line        int
+	offset      int64
+}
+
+// NewSegmentDataReader creates new reader. Text format requires encoder parameters the file was generated with.
+func NewSegmentDataReader(r io.Reader, format DataFormat, params *xgen.TextEncoderParameters) (*SegmentDataReader, error) {
+	var err error
+
+	dr := &SegmentDataReader{
+		format: format,
+	}
+
+	if format == FormatAvro {
+		dr.avroReader, err = avro.NewAvroReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return dr, nil
+	}
+
+	if format != FormatText {
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+
+	if params == nil {
+		return nil, errors.New("text encoder parameters are not specified")
+	}
+
+	dr.textDecoder, err = xgen.NewTextDecoder(*params)
+	if err != nil {
+		return nil, err
+	}
+
+	dr.scanner = bufio.NewScanner(r)
+	dr.scanner.Buffer(nil, maxLineSize)
+	dr.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		dr.offset += int64(advance)
+		return advance, token, err
+	})
+
+	return dr, nil
+}
+
+// Line returns the number of the last read line. For avro format it is the record number.
+func (dr *SegmentDataReader) Line() int {
+	return dr.line
+}
+
+// SetLine sets the number of the last read line, e.g. lines before the offset a reader is resumed at.
+func (dr *SegmentDataReader) SetLine(line int) {
+	dr.line = line
+}
+
+// Offset returns the number of input bytes of the lines read so far. The reader can be resumed
+// by creating a new reader at the offset of the input. For avro format it is always zero.
+func (dr *SegmentDataReader) Offset() int64 {
+	return dr.offset
+}
+
+// Read returns next user record or io.EOF at the end of the stream. Empty lines are skipped.
+func (dr *SegmentDataReader) Read() (*xgen.UserRecord, error) {
+	if dr.format == FormatAvro {
+		user, err := dr.avroReader.Read()
+		if err != nil {
+			return nil, err
+		}
+		dr.line++
+		return user, nil
+	}
+
+	for dr.scanner.Scan() {
+		dr.line++
+
+		text := dr.scanner.Text()
+		if text == "" {
+			continue
+		}
+
+		user, err := dr.textDecoder.ParseLine(text)
+		if err != nil {
+			return nil, &LineError{Line: dr.line, Text: text, Err: err}
+		}
+
+		return user, nil
+	}
+
+	if err := dr.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
@@ -0,0 +1,108 @@
+// Package sftpsink stores BSS files on an SFTP server.
+package sftpsink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/milla-v/xandr/bss"
+)
+
+// Sink writes files to a remote directory. Files are written to temporary files in the same directory
+// and renamed on Commit.
+type Sink struct {
+	c    *sftp.Client
+	dir  string
+	conn *ssh.Client // owned connection, nil if the client is provided by the caller
+}
+
+// New creates sink writing to dir using the client. Close does not close the client.
+func New(c *sftp.Client, dir string) *Sink {
+	return &Sink{c: c, dir: dir}
+}
+
+// Dial connects to the SSH server at addr and creates sink writing to dir.
+func Dial(addr string, config *ssh.ClientConfig, dir string) (*Sink, error) {
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Sink{c: c, dir: dir, conn: conn}, nil
+}
+
+// Close closes connection created by Dial.
+func (s *Sink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	s.c.Close()
+	return s.conn.Close()
+}
+
+// Create creates temporary file for the name.
+func (s *Sink) Create(ctx context.Context, name string) (bss.SinkFile, error) {
+	if name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid sink file name: %q", name)
+	}
+
+	tmp := path.Join(s.dir, fmt.Sprintf(".%s.tmp-%d", name, os.Getpid()))
+
+	f, err := s.c.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", tmp, err)
+	}
+
+	return &file{s: s, f: f, tmp: tmp, name: path.Join(s.dir, name)}, nil
+}
+
+type file struct {
+	s    *Sink
+	f    *sftp.File
+	tmp  string
+	name string
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return f.f.Write(p)
+}
+
+func (f *file) Commit() error {
+	if err := f.f.Close(); err != nil {
+		f.s.c.Remove(f.tmp)
+		return err
+	}
+
+	var err error
+	if _, ok := f.s.c.HasExtension("posix-rename@openssh.com"); ok {
+		err = f.s.c.PosixRename(f.tmp, f.name)
+	} else {
+		// plain rename does not replace existing files
+		f.s.c.Remove(f.name)
+		err = f.s.c.Rename(f.tmp, f.name)
+	}
+
+	if err != nil {
+		f.s.c.Remove(f.tmp)
+		return fmt.Errorf("rename %s: %w", f.tmp, err)
+	}
+
+	return nil
+}
+
+func (f *file) Abort() error {
+	f.f.Close()
+	return f.s.c.Remove(f.tmp)
+}
@@ -0,0 +1,108 @@
+package sftpsink
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/pkg/sftp"
+)
+
+// newPipeClient connects client to in-process server keeping files in memory.
+func newPipeClient(t *testing.T) *sftp.Client {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+
+	srv := sftp.NewRequestServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{sr, sw}, sftp.InMemHandler())
+
+	go srv.Serve()
+
+	c, err := sftp.NewClientPipe(cr, cw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		srv.Close()
+		c.Close()
+	})
+
+	return c
+}
+
+func TestSink(t *testing.T) {
+	c := newPipeClient(t)
+
+	if err := c.Mkdir("/upload"); err != nil {
+		t.Fatal(err)
+	}
+
+	sink := New(c, "/upload")
+	ctx := context.Background()
+
+	for _, data := range []string{"1234567890:100\n", "1234567890:101\n"} {
+		f, err := sink.Create(ctx, "part-00001.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.Stat("/upload/part-00001.txt"); err == nil && data == "1234567890:100\n" {
+			t.Fatal("file is visible before commit")
+		}
+
+		if err := f.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rf, err := c.Open("/upload/part-00001.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	buf, err := io.ReadAll(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "1234567890:101\n" {
+		t.Fatalf("unexpected content: %q", buf)
+	}
+
+	f, err := sink.Create(ctx, "part-00002.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f.Write([]byte("x"))
+
+	if err := f.Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := c.ReadDir("/upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		t.Fatal("unexpected files:", names)
+	}
+
+	if _, err := sink.Create(ctx, "../x"); err == nil || os.IsNotExist(err) {
+		t.Fatal("expected invalid name error")
+	}
+}
@@ -0,0 +1,88 @@
+package bss
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sink stores finished BSS files. Written data becomes visible under the file name only after Commit.
+type Sink interface {
+	Create(ctx context.Context, name string) (SinkFile, error)
+}
+
+// SinkFile is a file being written to a Sink. Either Commit or Abort should be called.
+type SinkFile interface {
+	io.Writer
+	Commit() error // makes the file visible under its name replacing existing one
+	Abort() error  // discards written data
+}
+
+// DirSink writes files to a local directory. Files are written to temporary files in the same directory
+// and renamed on Commit, so readers of the directory never see partially written files.
+type DirSink struct {
+	Dir string
+}
+
+// Create creates temporary file for the name.
+func (ds *DirSink) Create(ctx context.Context, name string) (SinkFile, error) {
+	if name == "" || strings.ContainsRune(name, filepath.Separator) {
+		return nil, fmt.Errorf("invalid sink file name: %q", name)
+	}
+
+	f, err := os.CreateTemp(ds.Dir, "."+name+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &dirFile{f: f, name: filepath.Join(ds.Dir, name)}, nil
+}
+
+type dirFile struct {
+	f    *os.File
+	name string
+}
+
+func (df *dirFile) Write(p []byte) (int, error) {
+	return df.f.Write(p)
+}
+
+func (df *dirFile) Commit() error {
+	if err := df.f.Sync(); err != nil {
+		df.Abort()
+		return err
+	}
+
+	if err := df.f.Close(); err != nil {
+		os.Remove(df.f.Name())
+		return err
+	}
+
+	if err := os.Rename(df.f.Name(), df.name); err != nil {
+		os.Remove(df.f.Name())
+		return err
+	}
+
+	return syncDir(filepath.Dir(df.name))
+}
+
+func (df *dirFile) Abort() error {
+	df.f.Close()
+	return os.Remove(df.f.Name())
+}
+
+// syncDir makes rename durable. Errors of directories which cannot be synced are ignored.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	d.Sync()
+
+	return nil
+}
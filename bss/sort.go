@@ -0,0 +1,207 @@
+package bss
+
+import (
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+const defaultChunkSize = 1000000
+
+// SortOptions configures SortRecords.
+type SortOptions struct {
+	ChunkSize int    // number of records sorted in memory, default 1000000
+	TempDir   string // directory for sorted chunks, default os.TempDir()
+}
+
+// SortedReader returns records in UID order. Close removes temporary files.
+type SortedReader struct {
+	records []*xgen.UserRecord // single chunk sorted in memory
+	files   []*os.File
+	merge   mergeHeap
+}
+
+// compareUsers orders user records by UID and then by domain.
+func compareUsers(a, b *xgen.UserRecord) int {
+	switch {
+	case a.UID < b.UID:
+		return -1
+	case a.UID > b.UID:
+		return 1
+	case a.Domain < b.Domain:
+		return -1
+	case a.Domain > b.Domain:
+		return 1
+	}
+	return 0
+}
+
+// SortRecords sorts records by UID using external merge sort.
+// Records are sorted in chunks of opts.ChunkSize, written to temporary files and merged on reading.
+func SortRecords(r RecordReader, opts *SortOptions) (*SortedReader, error) {
+	if opts == nil {
+		opts = &SortOptions{}
+	}
+
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	sr := &SortedReader{}
+	chunk := make([]*xgen.UserRecord, 0, chunkSize)
+
+	for {
+		user, err := r.Read()
+		if err != nil && err != io.EOF {
+			sr.Close()
+			return nil, err
+		}
+
+		if user != nil {
+			chunk = append(chunk, user)
+		}
+
+		if len(chunk) < chunkSize && err == nil {
+			continue
+		}
+
+		sort.SliceStable(chunk, func(i, j int) bool {
+			return compareUsers(chunk[i], chunk[j]) < 0
+		})
+
+		if err == io.EOF && len(sr.files) == 0 {
+			sr.records = chunk
+			return sr, nil
+		}
+
+		if len(chunk) > 0 {
+			if err := sr.writeChunk(chunk, opts.TempDir); err != nil {
+				sr.Close()
+				return nil, err
+			}
+			chunk = chunk[:0]
+		}
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	for _, f := range sr.files {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			sr.Close()
+			return nil, err
+		}
+
+		it := &chunkIterator{dec: gob.NewDecoder(f)}
+		if err := it.next(); err != nil {
+			if err == io.EOF {
+				continue
+			}
+			sr.Close()
+			return nil, err
+		}
+		sr.merge = append(sr.merge, it)
+	}
+
+	heap.Init(&sr.merge)
+
+	return sr, nil
+}
+
+func (sr *SortedReader) writeChunk(chunk []*xgen.UserRecord, dir string) error {
+	f, err := os.CreateTemp(dir, "bss-sort-*")
+	if err != nil {
+		return err
+	}
+
+	sr.files = append(sr.files, f)
+
+	enc := gob.NewEncoder(f)
+	for _, user := range chunk {
+		if err := enc.Encode(user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read returns next record in UID order or io.EOF.
+func (sr *SortedReader) Read() (*xgen.UserRecord, error) {
+	if sr.files == nil {
+		if len(sr.records) == 0 {
+			return nil, io.EOF
+		}
+		user := sr.records[0]
+		sr.records = sr.records[1:]
+		return user, nil
+	}
+
+	if sr.merge.Len() == 0 {
+		return nil, io.EOF
+	}
+
+	it := sr.merge[0]
+	user := it.user
+
+	if err := it.next(); err != nil {
+		if err != io.EOF {
+			return nil, err
+		}
+		heap.Pop(&sr.merge)
+	} else {
+		heap.Fix(&sr.merge, 0)
+	}
+
+	return user, nil
+}
+
+// Close removes temporary files.
+func (sr *SortedReader) Close() error {
+	var errs []error
+
+	for _, f := range sr.files {
+		errs = append(errs, f.Close(), os.Remove(f.Name()))
+	}
+
+	sr.files = nil
+	sr.records = nil
+
+	return errors.Join(errs...)
+}
+
+type chunkIterator struct {
+	dec  *gob.Decoder
+	user *xgen.UserRecord
+}
+
+func (it *chunkIterator) next() error {
+	user := &xgen.UserRecord{}
+	if err := it.dec.Decode(user); err != nil {
+		return err
+	}
+	it.user = user
+	return nil
+}
+
+type mergeHeap []*chunkIterator
+
+func (h mergeHeap) Len() int           { return len(h) }
+func (h mergeHeap) Less(i, j int) bool { return compareUsers(h[i].user, h[j].user) < 0 }
+func (h mergeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)        { *h = append(*h, x.(*chunkIterator)) }
+
+func (h *mergeHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,212 @@
+package bss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// PartInfo describes a committed part of SplitWriter output.
+type PartInfo struct {
+	Index int
+	Name  string
+	Users int
+	Bytes int64
+	Hash  string // SHA-256 of the part in hex
+}
+
+// SplitOptions configures SplitWriter. Zero limits mean a single part.
+type SplitOptions struct {
+	Format   DataFormat
+	Params   *xgen.TextEncoderParameters // required for FormatText
+	MaxUsers int                         // maximum number of users per part
+	MaxBytes int64                       // part is rotated after an Append call reaching the size
+	Options  []FormatterOption           // options of part formatters
+
+	// Name returns file name of the part, "part-00001.txt" or "part-00001.avro" by default.
+	Name func(index int) string
+
+	// OnPart is called after a part is committed, e.g. to upload or record it.
+	// Error stops writing.
+	OnPart func(ctx context.Context, p PartInfo) error
+}
+
+// SplitWriter writes user records into parts stored in a Sink.
+type SplitWriter struct {
+	ctx   context.Context
+	sink  Sink
+	opts  SplitOptions
+	parts []PartInfo
+
+	file   SinkFile
+	df     *SegmentDataFormatter
+	cw     *countingWriter
+	part   PartInfo
+	fields []xgen.SegmentFieldName
+}
+
+// NewSplitWriter creates split writer. Parts are created on the first Append.
+func NewSplitWriter(ctx context.Context, sink Sink, opts *SplitOptions) (*SplitWriter, error) {
+	sw := &SplitWriter{ctx: ctx, sink: sink, opts: *opts}
+
+	if sw.opts.Format == FormatText && sw.opts.Params == nil {
+		return nil, errors.New("text encoder parameters are not specified")
+	}
+
+	if sw.opts.Name == nil {
+		ext := "txt"
+		if sw.opts.Format == FormatAvro {
+			ext = "avro"
+		}
+		sw.opts.Name = func(index int) string { return fmt.Sprintf("part-%05d.%s", index, ext) }
+	}
+
+	// validates parameters and provides fields before the first part is created
+	df, err := NewSegmentDataFormatter(&countingWriter{}, sw.opts.Format, sw.opts.Params)
+	if err != nil {
+		return nil, err
+	}
+	sw.fields = df.Fields()
+
+	return sw, nil
+}
+
+// Fields returns segment fields the parts can represent.
+func (sw *SplitWriter) Fields() []xgen.SegmentFieldName {
+	return sw.fields
+}
+
+// Append writes users splitting them between parts.
+func (sw *SplitWriter) Append(users []*xgen.UserRecord) error {
+	for len(users) > 0 {
+		if sw.file == nil {
+			if err := sw.open(); err != nil {
+				return err
+			}
+		}
+
+		n := len(users)
+		if sw.opts.MaxUsers > 0 && n > sw.opts.MaxUsers-sw.part.Users {
+			n = sw.opts.MaxUsers - sw.part.Users
+		}
+
+		if err := sw.df.Append(users[:n]); err != nil {
+			return err
+		}
+
+		sw.part.Users += n
+		users = users[n:]
+
+		full := sw.opts.MaxUsers > 0 && sw.part.Users >= sw.opts.MaxUsers
+		if sw.opts.MaxBytes > 0 && sw.size() >= sw.opts.MaxBytes {
+			full = true
+		}
+
+		if full {
+			if err := sw.commit(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close commits the last part.
+func (sw *SplitWriter) Close() error {
+	if sw.file == nil {
+		return nil
+	}
+	return sw.commit()
+}
+
+// Abort discards the current part. Committed parts are kept.
+func (sw *SplitWriter) Abort() error {
+	if sw.file == nil {
+		return nil
+	}
+
+	err := sw.file.Abort()
+	sw.file = nil
+
+	return errors.Join(err, sw.df.removeRuns())
+}
+
+// Parts returns committed parts.
+func (sw *SplitWriter) Parts() []PartInfo {
+	return sw.parts
+}
+
+func (sw *SplitWriter) open() error {
+	index := len(sw.parts) + 1
+	name := sw.opts.Name(index)
+
+	f, err := sw.sink.Create(sw.ctx, name)
+	if err != nil {
+		return err
+	}
+
+	sw.cw = &countingWriter{w: f}
+
+	opts := append([]FormatterOption{WithHash()}, sw.opts.Options...)
+
+	sw.df, err = NewSegmentDataFormatter(sw.cw, sw.opts.Format, sw.opts.Params, opts...)
+	if err != nil {
+		f.Abort()
+		return err
+	}
+
+	sw.file = f
+	sw.part = PartInfo{Index: index, Name: name}
+
+	return nil
+}
+
+func (sw *SplitWriter) size() int64 {
+	return sw.cw.n + sw.df.unwritten()
+}
+
+func (sw *SplitWriter) commit() error {
+	f := sw.file
+	sw.file = nil
+
+	if err := sw.df.Close(); err != nil {
+		f.Abort()
+		return err
+	}
+
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("commit %s: %w", sw.part.Name, err)
+	}
+
+	sw.part.Bytes = sw.cw.n
+	sw.part.Hash = sw.df.Hash()
+	sw.parts = append(sw.parts, sw.part)
+
+	if sw.opts.OnPart != nil {
+		return sw.opts.OnPart(sw.ctx, sw.part)
+	}
+
+	return nil
+}
+
+// countingWriter counts bytes written to w. Nil w discards data.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	if cw.w == nil {
+		cw.n += int64(len(p))
+		return len(p), nil
+	}
+
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+
+	return n, err
+}
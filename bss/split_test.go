@@ -0,0 +1,125 @@
+package bss
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestSplitWriter(t *testing.T) {
+	dir := t.TempDir()
+
+	var users []*xgen.UserRecord
+	for i := 0; i < 7; i++ {
+		users = append(users, &xgen.UserRecord{UID: fmt.Sprint(1000 + i), Segments: []xgen.Segment{{ID: 100}}})
+	}
+
+	var committed []string
+
+	sw, err := NewSplitWriter(context.Background(), &DirSink{Dir: dir}, &SplitOptions{
+		Format:   FormatText,
+		Params:   &xgen.MinimalFormat,
+		MaxUsers: 3,
+		OnPart: func(ctx context.Context, p PartInfo) error {
+			committed = append(committed, p.Name)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.Append(users[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sw.Append(users[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last part is not committed yet and is hidden in a temporary file
+	if len(entries) != 3 || !strings.HasPrefix(entries[0].Name(), ".part-00003.txt.tmp-") {
+		t.Fatalf("unexpected files before close: %v", entries)
+	}
+
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []PartInfo{
+		{Index: 1, Name: "part-00001.txt", Users: 3, Bytes: 27},
+		{Index: 2, Name: "part-00002.txt", Users: 3, Bytes: 27},
+		{Index: 3, Name: "part-00003.txt", Users: 1, Bytes: 9},
+	}
+
+	for i := range expected {
+		buf, err := os.ReadFile(filepath.Join(dir, expected[i].Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(buf)
+		expected[i].Hash = hex.EncodeToString(sum[:])
+	}
+
+	if !reflect.DeepEqual(sw.Parts(), expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, sw.Parts())
+	}
+
+	if !reflect.DeepEqual(committed, []string{"part-00001.txt", "part-00002.txt", "part-00003.txt"}) {
+		t.Fatal("unexpected committed parts:", committed)
+	}
+
+	buf, err := os.ReadFile(filepath.Join(dir, "part-00003.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "1006:100\n" {
+		t.Fatalf("unexpected part content: %q", buf)
+	}
+
+	entries, _ = os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Fatal("temporary files are left:", entries)
+	}
+}
+
+func TestSplitWriterMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+
+	sw, err := NewSplitWriter(context.Background(), &DirSink{Dir: dir}, &SplitOptions{
+		Format:   FormatText,
+		Params:   &xgen.MinimalFormat,
+		MaxBytes: 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := sw.Append([]*xgen.UserRecord{{UID: fmt.Sprint(1000 + i), Segments: []xgen.Segment{{ID: 100}}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sw.Parts()) != 2 || sw.Parts()[0].Users != 3 {
+		t.Fatalf("unexpected parts: %+v", sw.Parts())
+	}
+}
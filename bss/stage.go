@@ -0,0 +1,54 @@
+package bss
+
+import (
+	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/metrics"
+)
+
+// Stage processes user records before encoding. Process returns nil record to drop the user.
+type Stage interface {
+	Process(ur *xgen.UserRecord) (*xgen.UserRecord, error)
+}
+
+// StageFunc is a function implementing Stage.
+type StageFunc func(ur *xgen.UserRecord) (*xgen.UserRecord, error)
+
+// Process calls f.
+func (f StageFunc) Process(ur *xgen.UserRecord) (*xgen.UserRecord, error) {
+	return f(ur)
+}
+
+// Pipeline runs stages in order until a stage drops the user.
+type Pipeline []Stage
+
+// Process implements Stage.
+func (p Pipeline) Process(ur *xgen.UserRecord) (*xgen.UserRecord, error) {
+	for _, s := range p {
+		var err error
+		if ur, err = s.Process(ur); err != nil || ur == nil {
+			return nil, err
+		}
+	}
+	return ur, nil
+}
+
+// Instrumented reports users processed and dropped by the stage labeled with name.
+// It returns s if in is nil.
+func Instrumented(name string, s Stage, in metrics.Instrument) Stage {
+	if in == nil {
+		return s
+	}
+
+	label := metrics.L("stage", name)
+
+	return StageFunc(func(ur *xgen.UserRecord) (*xgen.UserRecord, error) {
+		in.Count(metrics.StageUsers, 1, label)
+
+		ur, err := s.Process(ur)
+		if err == nil && ur == nil {
+			in.Count(metrics.Rejects, 1, label)
+		}
+
+		return ur, err
+	})
+}
@@ -0,0 +1,236 @@
+package suppress
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+)
+
+const bloomMagic = "XBSSBLM1"
+
+// Bloom is a bloom filter of user IDs. Contains can report false positives at the rate the filter
+// was created with, but never false negatives.
+type Bloom struct {
+	k    uint32   // number of hash functions
+	bits []uint64 // bit array
+}
+
+// NewBloom creates filter for n user IDs with the false positive rate, e.g. 0.001.
+func NewBloom(n int, fpRate float64) *Bloom {
+	if n < 1 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+
+	m := math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+
+	return &Bloom{
+		k:    uint32(k),
+		bits: make([]uint64, (uint64(m)+63)/64),
+	}
+}
+
+// hashes returns two hashes of the user ID key for double hashing.
+func hashes(k uint64) (uint64, uint64) {
+	return k, mix(k^0x5bd1e9955bd1e995) | 1
+}
+
+// Add adds user ID to the filter.
+func (b *Bloom) Add(uid string) {
+	b.AddKey(Key(uid))
+}
+
+// AddKey adds the user ID key returned by Key.
+func (b *Bloom) AddKey(k uint64) {
+	h1, h2 := hashes(k)
+	m := uint64(len(b.bits)) * 64
+
+	for i := uint64(0); i < uint64(b.k); i++ {
+		bit := (h1 + i*h2) % m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Contains implements List.
+func (b *Bloom) Contains(uid string) bool {
+	return b.ContainsKey(Key(uid))
+}
+
+// ContainsKey reports whether the filter may contain the key returned by Key.
+func (b *Bloom) ContainsKey(k uint64) bool {
+	h1, h2 := hashes(k)
+	m := uint64(len(b.bits)) * 64
+
+	for i := uint64(0); i < uint64(b.k); i++ {
+		bit := (h1 + i*h2) % m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WriteTo writes the filter in the format read by ReadBloom.
+func (b *Bloom) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(bloomMagic)+12)
+	copy(header, bloomMagic)
+	binary.LittleEndian.PutUint32(header[8:], b.k)
+	binary.LittleEndian.PutUint64(header[12:], uint64(len(b.bits)))
+
+	n, err := w.Write(header)
+	if err != nil {
+		return int64(n), err
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, b.bits); err != nil {
+		return int64(n), err
+	}
+
+	return int64(n + 8*len(b.bits)), nil
+}
+
+// ReadBloom reads filter written by WriteTo.
+func ReadBloom(r io.Reader) (*Bloom, error) {
+	header := make([]byte, len(bloomMagic)+12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if string(header[:8]) != bloomMagic {
+		return nil, errors.New("not a bloom filter file")
+	}
+
+	b := &Bloom{k: binary.LittleEndian.Uint32(header[8:])}
+	words := binary.LittleEndian.Uint64(header[12:])
+
+	if b.k == 0 || words == 0 || words > 1<<34 {
+		return nil, errors.New("invalid bloom filter header")
+	}
+
+	b.bits = make([]uint64, words)
+	if err := binary.Read(r, binary.LittleEndian, b.bits); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+const shardedBloomMagic = "XBSSSBF1"
+
+var errNotPowerOfTwo = errors.New("number of shards should be a power of two up to 65536")
+
+// ShardedBloom is a bloom filter split into shards selected by the top bits of the key. Every shard
+// is a separate bit array, so lists of billions of IDs do not need a single huge allocation.
+type ShardedBloom struct {
+	shift  uint // 64 - log2(number of shards)
+	shards []*Bloom
+}
+
+// NewShardedBloom creates filter for n user IDs with the false positive rate. The number of shards
+// should be a power of two.
+func NewShardedBloom(n int, fpRate float64, shards int) (*ShardedBloom, error) {
+	if shards < 1 || shards&(shards-1) != 0 || shards > 1<<16 {
+		return nil, errNotPowerOfTwo
+	}
+
+	sb := &ShardedBloom{
+		shift:  uint(64 - bits.TrailingZeros(uint(shards))),
+		shards: make([]*Bloom, shards),
+	}
+
+	for i := range sb.shards {
+		sb.shards[i] = NewBloom((n+shards-1)/shards, fpRate)
+	}
+
+	return sb, nil
+}
+
+func (sb *ShardedBloom) shard(k uint64) *Bloom {
+	return sb.shards[k>>sb.shift] // shift by 64 of a single shard filter gives 0
+}
+
+// Add adds user ID to the filter.
+func (sb *ShardedBloom) Add(uid string) {
+	sb.AddKey(Key(uid))
+}
+
+// AddKey adds the user ID key returned by Key.
+func (sb *ShardedBloom) AddKey(k uint64) {
+	sb.shard(k).AddKey(k)
+}
+
+// Contains implements List.
+func (sb *ShardedBloom) Contains(uid string) bool {
+	return sb.ContainsKey(Key(uid))
+}
+
+// ContainsKey reports whether the filter may contain the key returned by Key.
+func (sb *ShardedBloom) ContainsKey(k uint64) bool {
+	return sb.shard(k).ContainsKey(k)
+}
+
+// WriteTo writes the filter in the format read by ReadShardedBloom: the header followed by the shards
+// in the format of Bloom.WriteTo.
+func (sb *ShardedBloom) WriteTo(w io.Writer) (int64, error) {
+	header := make([]byte, len(shardedBloomMagic)+4)
+	copy(header, shardedBloomMagic)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(sb.shards)))
+
+	bw := bufio.NewWriter(w)
+
+	n, _ := bw.Write(header)
+	total := int64(n)
+
+	for _, b := range sb.shards {
+		n, err := b.WriteTo(bw)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, bw.Flush()
+}
+
+// ReadShardedBloom reads filter written by ShardedBloom.WriteTo.
+func ReadShardedBloom(r io.Reader) (*ShardedBloom, error) {
+	header := make([]byte, len(shardedBloomMagic)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	if string(header[:8]) != shardedBloomMagic {
+		return nil, errors.New("not a sharded bloom filter file")
+	}
+
+	shards := binary.LittleEndian.Uint32(header[8:])
+	if shards == 0 || shards&(shards-1) != 0 || shards > 1<<16 {
+		return nil, errors.New("invalid sharded bloom filter header")
+	}
+
+	sb := &ShardedBloom{
+		shift:  uint(64 - bits.TrailingZeros32(shards)),
+		shards: make([]*Bloom, shards),
+	}
+
+	for i := range sb.shards {
+		b, err := ReadBloom(r)
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		sb.shards[i] = b
+	}
+
+	return sb, nil
+}
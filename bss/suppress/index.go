@@ -0,0 +1,452 @@
+package suppress
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+const (
+	indexMagic  = "XBSSIDX1"
+	prefixBits  = 16
+	tableSize   = 1<<prefixBits + 1
+	indexHeader = 16 + 8*tableSize // magic, count and prefix table
+)
+
+// Index is a sorted file of user ID keys. The file is memory mapped where supported, so lookups
+// use the page cache instead of the heap. The prefix table stored in the file limits the binary search
+// to keys with the same top 16 bits.
+//
+// File layout, little endian: magic, count uint64, 65537 uint64 start positions of key prefixes, sorted keys.
+type Index struct {
+	data   []byte // mapped file
+	table  []byte
+	keys   []byte
+	count  uint64
+	unmap  func() error
+	closed bool
+}
+
+// OpenIndex opens index file written by Builder.WriteIndex.
+func OpenIndex(name string) (*Index, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.Size() < indexHeader {
+		return nil, fmt.Errorf("%s: not an index file", name)
+	}
+
+	data, unmap, err := mapFile(f, int(fi.Size()))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	idx := &Index{data: data, unmap: unmap}
+
+	if string(data[:8]) != indexMagic {
+		idx.Close()
+		return nil, fmt.Errorf("%s: not an index file", name)
+	}
+
+	idx.count = binary.LittleEndian.Uint64(data[8:])
+	idx.table = data[16:indexHeader]
+	idx.keys = data[indexHeader:]
+
+	if uint64(len(idx.keys)) != 8*idx.count || !idx.validTable() {
+		idx.Close()
+		return nil, fmt.Errorf("%s: index file is truncated or corrupted", name)
+	}
+
+	return idx, nil
+}
+
+// validTable reports whether the prefix table starts at 0, is non-decreasing and ends at the count,
+// so lookups never read outside of the keys.
+func (idx *Index) validTable() bool {
+	if idx.position(0) != 0 || idx.position(tableSize-1) != idx.count {
+		return false
+	}
+
+	for prefix := uint64(1); prefix < tableSize; prefix++ {
+		if idx.position(prefix) < idx.position(prefix-1) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (idx *Index) position(prefix uint64) uint64 {
+	return binary.LittleEndian.Uint64(idx.table[8*prefix:])
+}
+
+func (idx *Index) key(i uint64) uint64 {
+	return binary.LittleEndian.Uint64(idx.keys[8*i:])
+}
+
+// Len returns number of keys in the index.
+func (idx *Index) Len() int {
+	return int(idx.count)
+}
+
+// Contains implements List.
+func (idx *Index) Contains(uid string) bool {
+	return idx.ContainsKey(Key(uid))
+}
+
+// ContainsKey reports whether the index has the key returned by Key.
+func (idx *Index) ContainsKey(k uint64) bool {
+	prefix := k >> (64 - prefixBits)
+	lo, hi := idx.position(prefix), idx.position(prefix+1)
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		switch v := idx.key(mid); {
+		case v == k:
+			return true
+		case v < k:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+
+	return false
+}
+
+// Close unmaps the file. The index cannot be used after Close.
+func (idx *Index) Close() error {
+	if idx.closed {
+		return nil
+	}
+	idx.closed = true
+	return idx.unmap()
+}
+
+// defaultRunSize is the number of keys sorted in memory by Builder, 64 MB.
+const defaultRunSize = 8 * 1024 * 1024
+
+// Builder collects user ID keys to write an index or a bloom filter. Keys are sorted in runs of RunSize keys,
+// full runs are written to temporary files and merged by WriteIndex and Bloom, so memory does not grow
+// with the number of IDs. Close removes the temporary files.
+type Builder struct {
+	RunSize int    // number of keys sorted in memory, default 8388608
+	TempDir string // directory for sorted runs, default os.TempDir()
+
+	keys   []uint64   // current run
+	sorted bool       // keys are sorted and unique
+	runs   []*os.File // sorted runs of unique keys
+	err    error      // first error of writing a run
+}
+
+// Add adds user ID.
+func (b *Builder) Add(uid string) {
+	if b.err != nil {
+		return
+	}
+
+	b.keys = append(b.keys, Key(uid))
+	b.sorted = false
+
+	runSize := b.RunSize
+	if runSize <= 0 {
+		runSize = defaultRunSize
+	}
+
+	if len(b.keys) >= runSize {
+		b.err = b.writeRun()
+	}
+}
+
+// Len returns number of unique keys. Errors of writing and merging runs are returned by WriteIndex and Bloom.
+func (b *Builder) Len() int {
+	n, _ := b.merge()
+	return n
+}
+
+func (b *Builder) sort() {
+	if !b.sorted {
+		slices.Sort(b.keys)
+		b.keys = slices.Compact(b.keys)
+		b.sorted = true
+	}
+}
+
+// writeRun writes the keys in memory to a temporary file.
+func (b *Builder) writeRun() error {
+	b.sort()
+
+	f, err := os.CreateTemp(b.TempDir, "xbss-keys-*")
+	if err != nil {
+		return err
+	}
+
+	b.runs = append(b.runs, f)
+
+	bw := bufio.NewWriter(f)
+	buf := make([]byte, 8)
+
+	for _, k := range b.keys {
+		binary.LittleEndian.PutUint64(buf, k)
+		bw.Write(buf)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	b.keys = b.keys[:0]
+
+	return nil
+}
+
+// merge merges the runs and the keys in memory into a single run and returns number of unique keys.
+// Keys which fit in memory are not written.
+func (b *Builder) merge() (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	b.sort()
+
+	if len(b.runs) == 0 {
+		return len(b.keys), nil
+	}
+
+	if len(b.runs) == 1 && len(b.keys) == 0 {
+		fi, err := b.runs[0].Stat()
+		if err != nil {
+			return 0, err
+		}
+		return int(fi.Size() / 8), nil
+	}
+
+	var h keyHeap
+
+	if len(b.keys) > 0 {
+		h = append(h, &keyIterator{keys: b.keys[1:], key: b.keys[0]})
+	}
+
+	for _, f := range b.runs {
+		it, err := newKeyIterator(f)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		h = append(h, it)
+	}
+
+	heap.Init(&h)
+
+	merged, err := os.CreateTemp(b.TempDir, "xbss-keys-*")
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(merged)
+	buf := make([]byte, 8)
+	n := 0
+
+	for h.Len() > 0 {
+		it := h[0]
+
+		if n == 0 || it.key != binary.LittleEndian.Uint64(buf) {
+			binary.LittleEndian.PutUint64(buf, it.key)
+			bw.Write(buf)
+			n++
+		}
+
+		if err := it.next(); err == io.EOF {
+			heap.Pop(&h)
+		} else if err != nil {
+			merged.Close()
+			os.Remove(merged.Name())
+			return 0, err
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		merged.Close()
+		os.Remove(merged.Name())
+		return 0, err
+	}
+
+	if err := b.removeRuns(); err != nil {
+		merged.Close()
+		os.Remove(merged.Name())
+		return 0, err
+	}
+
+	b.runs = []*os.File{merged}
+	b.keys = b.keys[:0]
+
+	return n, nil
+}
+
+// each calls fn for the unique keys in order. It is called after merge.
+func (b *Builder) each(fn func(k uint64)) error {
+	if len(b.runs) == 0 {
+		for _, k := range b.keys {
+			fn(k)
+		}
+		return nil
+	}
+
+	it, err := newKeyIterator(b.runs[0])
+	for err == nil {
+		fn(it.key)
+		err = it.next()
+	}
+
+	if err == io.EOF {
+		return nil
+	}
+
+	return err
+}
+
+// WriteIndex writes index file opened by OpenIndex.
+func (b *Builder) WriteIndex(w io.Writer) error {
+	count, err := b.merge()
+	if err != nil {
+		return err
+	}
+
+	// start position of every prefix, the last one is the count
+	table := make([]uint64, tableSize)
+	if err := b.each(func(k uint64) { table[k>>(64-prefixBits)+1]++ }); err != nil {
+		return err
+	}
+	for i := 1; i < tableSize; i++ {
+		table[i] += table[i-1]
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 8)
+
+	put := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf, v)
+		bw.Write(buf)
+	}
+
+	bw.WriteString(indexMagic)
+	put(uint64(count))
+
+	for _, pos := range table {
+		put(pos)
+	}
+
+	if err := b.each(put); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// Bloom returns sharded bloom filter of the collected keys with the false positive rate.
+func (b *Builder) Bloom(fpRate float64, shards int) (*ShardedBloom, error) {
+	count, err := b.merge()
+	if err != nil {
+		return nil, err
+	}
+
+	sb, err := NewShardedBloom(count, fpRate, shards)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.each(sb.AddKey); err != nil {
+		return nil, err
+	}
+
+	return sb, nil
+}
+
+// Close removes temporary files. The builder cannot be used after Close.
+func (b *Builder) Close() error {
+	b.keys = nil
+	return b.removeRuns()
+}
+
+func (b *Builder) removeRuns() error {
+	var errs []error
+
+	for _, f := range b.runs {
+		errs = append(errs, f.Close(), os.Remove(f.Name()))
+	}
+
+	b.runs = nil
+
+	return errors.Join(errs...)
+}
+
+// keyIterator reads keys of a sorted run or of the keys in memory.
+type keyIterator struct {
+	r    *bufio.Reader // nil for keys in memory
+	keys []uint64
+	key  uint64
+	buf  [8]byte
+}
+
+// newKeyIterator returns iterator at the first key of the run or io.EOF for an empty run.
+func newKeyIterator(f *os.File) (*keyIterator, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	it := &keyIterator{r: bufio.NewReader(f)}
+	if err := it.next(); err != nil {
+		return nil, err
+	}
+
+	return it, nil
+}
+
+func (it *keyIterator) next() error {
+	if it.r == nil {
+		if len(it.keys) == 0 {
+			return io.EOF
+		}
+		it.key, it.keys = it.keys[0], it.keys[1:]
+		return nil
+	}
+
+	if _, err := io.ReadFull(it.r, it.buf[:]); err != nil {
+		return err
+	}
+	it.key = binary.LittleEndian.Uint64(it.buf[:])
+
+	return nil
+}
+
+type keyHeap []*keyIterator
+
+func (h keyHeap) Len() int           { return len(h) }
+func (h keyHeap) Less(i, j int) bool { return h[i].key < h[j].key }
+func (h keyHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *keyHeap) Push(x any)        { *h = append(*h, x.(*keyIterator)) }
+
+func (h *keyHeap) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,117 @@
+// Package suppress provides opt-out suppression lists of user IDs.
+package suppress
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+)
+
+// List reports whether a user ID is suppressed.
+type List interface {
+	Contains(uid string) bool
+}
+
+// Normalize returns the form of user ID stored in lists. Device IDs are compared case-insensitively.
+func Normalize(uid string) string {
+	return strings.ToLower(strings.TrimSpace(uid))
+}
+
+// Key returns 64-bit hash of the normalized user ID stored in indexes and bloom filters.
+// Different IDs can have the same key with probability about n/2^64 for a list of n IDs.
+func Key(uid string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(Normalize(uid)))
+	return mix(h.Sum64())
+}
+
+// mix is the splitmix64 finalizer improving distribution of FNV hashes.
+func mix(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// Set is an exact set of normalized user IDs.
+type Set map[string]struct{}
+
+// Add adds user ID to the set.
+func (s Set) Add(uid string) {
+	s[Normalize(uid)] = struct{}{}
+}
+
+// Contains implements List.
+func (s Set) Contains(uid string) bool {
+	_, ok := s[Normalize(uid)]
+	return ok
+}
+
+// ReadUIDs calls add for user IDs of a list with one ID per line. Only the first column of CSV lines is used,
+// empty lines and a "uid" header are skipped.
+func ReadUIDs(r io.Reader, add func(uid string)) error {
+	sc := bufio.NewScanner(r)
+
+	for n := 1; sc.Scan(); n++ {
+		uid, _, _ := strings.Cut(sc.Text(), ",")
+		uid = strings.Trim(strings.TrimSpace(uid), `"`)
+
+		if uid == "" || (n == 1 && strings.EqualFold(uid, "uid")) {
+			continue
+		}
+
+		add(uid)
+	}
+
+	return sc.Err()
+}
+
+// LoadSet reads a list of user IDs into a set.
+func LoadSet(r io.Reader) (Set, error) {
+	s := make(Set)
+	if err := ReadUIDs(r, s.Add); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Open loads suppression list file. Index files and bloom filters are detected by the header,
+// other files are loaded as lists of user IDs. An Index is memory mapped and should be closed
+// when it is not needed, other lists do not implement io.Closer.
+func Open(name string) (List, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	magic, _ := br.Peek(len(bloomMagic))
+
+	switch {
+	case bytes.Equal(magic, []byte(indexMagic)):
+		return OpenIndex(name)
+	case bytes.Equal(magic, []byte(bloomMagic)):
+		b, err := ReadBloom(br)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return b, nil
+	case bytes.Equal(magic, []byte(shardedBloomMagic)):
+		sb, err := ReadShardedBloom(br)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return sb, nil
+	}
+
+	s, err := LoadSet(br)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,302 @@
+package suppress
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBloom(t *testing.T) {
+	const n = 100000
+
+	b := NewBloom(n, 0.01)
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprint(1000000000 + i))
+	}
+
+	name := filepath.Join(t.TempDir(), "optout.bloom")
+
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	list, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < n; i++ {
+		if !list.Contains(fmt.Sprint(1000000000 + i)) {
+			t.Fatal("false negative", i)
+		}
+	}
+
+	fp := 0
+	for i := 0; i < n; i++ {
+		if list.Contains(fmt.Sprint(2000000000 + i)) {
+			fp++
+		}
+	}
+
+	if rate := float64(fp) / n; rate > 0.015 {
+		t.Fatal("false positive rate is too high:", rate)
+	}
+}
+
+func TestOpenSet(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "optout.csv")
+	if err := os.WriteFile(name, []byte("uid,reason\n\"1234567890\",gdpr\n\nABC-DEF\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s, ok := list.(Set); !ok || len(s) != 2 || !s.Contains("abc-def") || !s.Contains(" 1234567890") {
+		t.Fatalf("unexpected set: %v", list)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	const n = 100000
+
+	var b Builder
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprintf("AAAA-%08d", i))
+	}
+	b.Add("aaaa-00000001") // duplicate after normalization
+
+	if b.Len() != n {
+		t.Fatal("unexpected number of keys:", b.Len())
+	}
+
+	name := filepath.Join(t.TempDir(), "optout.idx")
+
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.WriteIndex(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	list, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, ok := list.(*Index)
+	if !ok {
+		t.Fatalf("unexpected list type %T", list)
+	}
+	defer idx.Close()
+
+	if idx.Len() != n {
+		t.Fatal("unexpected index length:", idx.Len())
+	}
+
+	for i := 0; i < n; i++ {
+		if !idx.Contains(fmt.Sprintf("aaaa-%08d", i)) {
+			t.Fatal("not found", i)
+		}
+		if idx.Contains(fmt.Sprintf("bbbb-%08d", i)) {
+			t.Fatal("unexpected match", i)
+		}
+	}
+}
+
+func TestOpenIndexTruncated(t *testing.T) {
+	var b Builder
+	b.Add("1234567890")
+
+	var buf bytes.Buffer
+	if err := b.WriteIndex(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "optout.idx")
+	if err := os.WriteFile(name, buf.Bytes()[:buf.Len()-1], 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenIndex(name); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestOpenIndexCorruptedTable(t *testing.T) {
+	var b Builder
+	b.Add("1234567890")
+	b.Add("abc-def")
+
+	var buf bytes.Buffer
+	if err := b.WriteIndex(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		prefix int
+		pos    uint64
+	}{
+		{prefix: 0, pos: 1},     // keys before the first prefix
+		{prefix: 100, pos: 3},   // position after the count
+		{prefix: 65535, pos: 0}, // decreasing positions
+	} {
+		data := bytes.Clone(buf.Bytes())
+		binary.LittleEndian.PutUint64(data[16+8*tc.prefix:], tc.pos)
+
+		name := filepath.Join(t.TempDir(), "optout.idx")
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := OpenIndex(name)
+		if err == nil || !strings.HasSuffix(err.Error(), "index file is truncated or corrupted") {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+}
+
+func TestBuilderRuns(t *testing.T) {
+	const n = 10000
+
+	dir := t.TempDir()
+
+	var inMemory Builder
+	b := &Builder{RunSize: 1000, TempDir: dir}
+
+	for i := 0; i < n; i++ {
+		uid := fmt.Sprint(1000000000 + i*7%n) // every key is added once in a different order
+		inMemory.Add(uid)
+		b.Add(uid)
+		b.Add(fmt.Sprint(1000000000 + i/2)) // duplicates across runs
+	}
+
+	if b.Len() != n {
+		t.Fatal("unexpected number of keys:", b.Len())
+	}
+
+	var expected, actual bytes.Buffer
+	if err := inMemory.WriteIndex(&expected); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.WriteIndex(&actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+		t.Fatal("index of merged runs differs from the index built in memory")
+	}
+
+	// keys added after merging are merged again
+	b.Add("abc-def")
+
+	sb, err := b.Bloom(0.01, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != n+1 || !sb.Contains("abc-def") || !sb.Contains("1000000000") {
+		t.Fatal("unexpected bloom filter of", b.Len(), "keys")
+	}
+
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatal("temporary files are not removed:", len(files))
+	}
+}
+
+func TestShardedBloom(t *testing.T) {
+	const n = 100000
+
+	var b Builder
+	for i := 0; i < n; i++ {
+		b.Add(fmt.Sprint(1000000000 + i))
+	}
+
+	sb, err := b.Bloom(0.01, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := sb.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := ReadShardedBloom(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fp := 0
+	for i := 0; i < n; i++ {
+		if !list.Contains(fmt.Sprint(1000000000 + i)) {
+			t.Fatal("false negative", i)
+		}
+		if list.Contains(fmt.Sprint(2000000000 + i)) {
+			fp++
+		}
+	}
+
+	if rate := float64(fp) / n; rate > 0.015 {
+		t.Fatal("false positive rate is too high:", rate)
+	}
+
+	if _, err := NewShardedBloom(n, 0.01, 3); err == nil {
+		t.Fatal("expected error for 3 shards")
+	}
+}
+
+func BenchmarkIndexContains(b *testing.B) {
+	const n = 1000000
+
+	var bld Builder
+	for i := 0; i < n; i++ {
+		bld.Add(fmt.Sprint(1000000000 + i))
+	}
+
+	name := filepath.Join(b.TempDir(), "optout.idx")
+
+	f, err := os.Create(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := bld.WriteIndex(f); err != nil {
+		b.Fatal(err)
+	}
+	f.Close()
+
+	idx, err := OpenIndex(name)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer idx.Close()
+
+	keys := make([]uint64, 1024)
+	for i := range keys {
+		keys[i] = Key(fmt.Sprint(1000000000 + i*977))
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		idx.ContainsKey(keys[i%len(keys)])
+	}
+}
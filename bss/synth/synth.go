@@ -0,0 +1,265 @@
+// Package synth generates synthetic user records for benchmarks and load tests.
+package synth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// Config configures Generator. Zero fields use defaults described in the comments.
+type Config struct {
+	Seed  int64 // the same seed and config produce the same records
+	Users int   // number of users, Read returns io.EOF after them, 0 is unlimited
+
+	Domains map[xgen.Domain]float64 // relative proportions of domains, Xandr IDs only by default
+
+	Catalog        int     // number of distinct segments, 1000 by default
+	FirstID        int32   // ID of the first segment, 100 by default
+	MaxSegments    int     // maximum number of segments per user, 20 by default
+	CountZipf      float64 // Zipf exponent (> 1) of the number of segments per user, 1.5 by default
+	PopularityZipf float64 // Zipf exponent (> 1) of segment popularity, 0 is uniform
+
+	RemovalRatio float64 // share of memberships written as removals
+	CodeRatio    float64 // share of segments referenced by code and member ID
+	MemberID     int32   // member ID of segment codes, 1 by default
+
+	MinExpiration time.Duration // expiration spread, both zero set DefaultExpiration
+	MaxExpiration time.Duration
+
+	MaxValue        int32         // values are uniform in [0, MaxValue]
+	Now             time.Time     // timestamps are set if not zero
+	TimestampSpread time.Duration // timestamps are uniform in [Now-TimestampSpread, Now]
+}
+
+// Generator produces user records. It implements bss.RecordReader.
+type Generator struct {
+	cfg        Config
+	rnd        *rand.Rand
+	count      *rand.Zipf
+	popularity *rand.Zipf
+	domains    []xgen.Domain
+	weights    []float64 // cumulative domain weights
+	users      int
+}
+
+// New creates generator validating the config.
+func New(cfg Config) (*Generator, error) {
+	if cfg.Catalog == 0 {
+		cfg.Catalog = 1000
+	}
+	if cfg.FirstID == 0 {
+		cfg.FirstID = 100
+	}
+	if cfg.MaxSegments == 0 {
+		cfg.MaxSegments = 20
+	}
+	if cfg.CountZipf == 0 {
+		cfg.CountZipf = 1.5
+	}
+	if cfg.MemberID == 0 {
+		cfg.MemberID = 1
+	}
+	if len(cfg.Domains) == 0 {
+		cfg.Domains = map[xgen.Domain]float64{xgen.XandrID: 1}
+	}
+
+	switch {
+	case cfg.Users < 0 || cfg.Catalog < 0 || cfg.MaxSegments < 0:
+		return nil, errors.New("negative number of users or segments")
+	case cfg.MaxSegments > cfg.Catalog:
+		return nil, fmt.Errorf("max segments %d is greater than the catalog size %d", cfg.MaxSegments, cfg.Catalog)
+	case cfg.CountZipf <= 1 || (cfg.PopularityZipf != 0 && cfg.PopularityZipf <= 1):
+		return nil, errors.New("zipf exponents should be greater than 1")
+	case cfg.RemovalRatio < 0 || cfg.RemovalRatio > 1 || cfg.CodeRatio < 0 || cfg.CodeRatio > 1:
+		return nil, errors.New("ratios should be in the range [0, 1]")
+	case cfg.MinExpiration < 0 || cfg.MaxExpiration < cfg.MinExpiration || cfg.MaxExpiration > xgen.MaxExpirationDuration:
+		return nil, fmt.Errorf("expiration spread should be within [0, %s]", xgen.MaxExpirationDuration)
+	case cfg.MaxValue < 0 || cfg.TimestampSpread < 0:
+		return nil, errors.New("negative value or timestamp spread")
+	}
+
+	g := &Generator{
+		cfg: cfg,
+		rnd: rand.New(rand.NewSource(cfg.Seed)),
+	}
+
+	// sorted for reproducible map iteration
+	for d := range cfg.Domains {
+		g.domains = append(g.domains, d)
+	}
+	sort.Slice(g.domains, func(i, j int) bool { return g.domains[i] < g.domains[j] })
+
+	total := 0.0
+	for _, d := range g.domains {
+		w := cfg.Domains[d]
+		if w < 0 {
+			return nil, fmt.Errorf("negative proportion of domain %s", d.Name())
+		}
+		if d != xgen.XandrID && d != xgen.IDFA && d != xgen.AAID {
+			return nil, fmt.Errorf("unsupported domain: %s", d)
+		}
+		total += w
+		g.weights = append(g.weights, total)
+	}
+	if total == 0 {
+		return nil, errors.New("domain proportions are zero")
+	}
+
+	g.count = rand.NewZipf(g.rnd, cfg.CountZipf, 1, uint64(cfg.MaxSegments-1))
+	if cfg.PopularityZipf != 0 {
+		g.popularity = rand.NewZipf(g.rnd, cfg.PopularityZipf, 1, uint64(cfg.Catalog-1))
+	}
+
+	return g, nil
+}
+
+// Read implements bss.RecordReader.
+func (g *Generator) Read() (*xgen.UserRecord, error) {
+	if g.cfg.Users > 0 && g.users == g.cfg.Users {
+		return nil, io.EOF
+	}
+	g.users++
+
+	ur := &xgen.UserRecord{Domain: g.domain()}
+
+	if ur.Domain == xgen.XandrID {
+		ur.UID = strconv.FormatInt(g.rnd.Int63n(1<<62)+1, 10)
+	} else {
+		ur.UID = g.uuid()
+	}
+
+	n := 1
+	if g.cfg.MaxSegments > 1 {
+		n += int(g.count.Uint64())
+	}
+
+	seen := make(map[int]bool, n)
+	ur.Segments = make([]xgen.Segment, 0, n)
+
+	for len(ur.Segments) < n {
+		// the next segment on collisions keeps the loop bounded for skewed popularity
+		idx := g.segment()
+		for seen[idx] {
+			idx = (idx + 1) % g.cfg.Catalog
+		}
+		seen[idx] = true
+
+		ur.Segments = append(ur.Segments, g.membership(idx))
+	}
+
+	return ur, nil
+}
+
+func (g *Generator) domain() xgen.Domain {
+	x := g.rnd.Float64() * g.weights[len(g.weights)-1]
+	for i, w := range g.weights {
+		if x < w {
+			return g.domains[i]
+		}
+	}
+	return g.domains[len(g.domains)-1]
+}
+
+func (g *Generator) uuid() string {
+	var b [16]byte
+	g.rnd.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// segment returns index of a segment in the catalog.
+func (g *Generator) segment() int {
+	if g.popularity != nil {
+		return int(g.popularity.Uint64())
+	}
+	return g.rnd.Intn(g.cfg.Catalog)
+}
+
+func (g *Generator) membership(idx int) xgen.Segment {
+	var seg xgen.Segment
+
+	// the same segments are always referenced by code, the hash spreads them over popularity ranks
+	if float64(uint64(idx)*0x9e3779b97f4a7c15>>11)/(1<<53) < g.cfg.CodeRatio {
+		seg.Code = "seg-" + strconv.Itoa(idx)
+		seg.MemberID = g.cfg.MemberID
+	} else {
+		seg.ID = g.cfg.FirstID + int32(idx)
+	}
+
+	if g.cfg.RemovalRatio > 0 && g.rnd.Float64() < g.cfg.RemovalRatio {
+		seg.Expiration = xgen.Expired
+	} else if g.cfg.MaxExpiration > 0 {
+		spread := int64(g.cfg.MaxExpiration - g.cfg.MinExpiration)
+		d := g.cfg.MinExpiration + time.Duration(g.rnd.Int63n(spread+1))
+		seg.Expiration = int32(xgen.ExpirationMinutes(d))
+		if seg.Expiration > xgen.MaxExpiration {
+			seg.Expiration = xgen.MaxExpiration
+		}
+	}
+
+	if g.cfg.MaxValue > 0 {
+		seg.Value = g.rnd.Int31n(g.cfg.MaxValue + 1)
+	}
+
+	if !g.cfg.Now.IsZero() {
+		ts := g.cfg.Now
+		if g.cfg.TimestampSpread > 0 {
+			ts = ts.Add(-time.Duration(g.rnd.Int63n(int64(g.cfg.TimestampSpread) + 1)))
+		}
+		seg.Timestamp = ts.Unix()
+	}
+
+	return seg
+}
+
+// Write writes Config.Users generated users to w in batches and returns the number of written users.
+func (g *Generator) Write(w bss.RecordWriter, batchSize int) (int, error) {
+	if g.cfg.Users == 0 {
+		return 0, errors.New("number of users is unlimited")
+	}
+
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	batch := make([]*xgen.UserRecord, 0, batchSize)
+	written := 0
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := w.Append(batch)
+		written += len(batch)
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		ur, err := g.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+
+		batch = append(batch, ur)
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, flush()
+}
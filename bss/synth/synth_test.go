@@ -0,0 +1,214 @@
+package synth
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func testConfig() Config {
+	return Config{
+		Seed:            42,
+		Users:           2000,
+		Domains:         map[xgen.Domain]float64{xgen.XandrID: 0.6, xgen.IDFA: 0.2, xgen.AAID: 0.2},
+		Catalog:         50,
+		MaxSegments:     10,
+		PopularityZipf:  1.2,
+		RemovalRatio:    0.1,
+		CodeRatio:       0.2,
+		MemberID:        1234,
+		MinExpiration:   time.Hour,
+		MaxExpiration:   7 * 24 * time.Hour,
+		MaxValue:        100,
+		Now:             time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		TimestampSpread: 24 * time.Hour,
+	}
+}
+
+func generate(t *testing.T, cfg Config, format bss.DataFormat) []byte {
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	df, err := bss.NewSegmentDataFormatter(&buf, format, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := g.Write(df, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != cfg.Users {
+		t.Fatal("unexpected number of users:", n)
+	}
+
+	if err := df.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestGenerator(t *testing.T) {
+	cfg := testConfig()
+
+	// text formats cannot mix segment IDs and codes
+	textConfig := cfg
+	textConfig.CodeRatio = 0
+
+	text := generate(t, textConfig, bss.FormatText)
+	if !bytes.Equal(text, generate(t, textConfig, bss.FormatText)) {
+		t.Fatal("the same seed generated different output")
+	}
+
+	dr, err := bss.NewSegmentDataReader(bytes.NewReader(text), bss.FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := bss.Validate(dr, &bss.ValidationOptions{StrictUUID: true, Duplicates: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rep.Issues) != 0 {
+		t.Fatal("validation issues:", rep.Issues)
+	}
+
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	domains := make(map[xgen.Domain]int)
+	removals, codes, memberships := 0, 0, 0
+
+	for {
+		ur, err := g.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		domains[ur.Domain]++
+
+		if len(ur.Segments) < 1 || len(ur.Segments) > cfg.MaxSegments {
+			t.Fatal("unexpected number of segments:", len(ur.Segments))
+		}
+
+		seen := make(map[xgen.SegmentKey]bool)
+
+		for _, seg := range ur.Segments {
+			memberships++
+			if seen[seg.Key()] {
+				t.Fatal("duplicate segment", seg.Key())
+			}
+			seen[seg.Key()] = true
+
+			if seg.Expiration == xgen.Expired {
+				removals++
+			} else if seg.ExpirationDuration() < cfg.MinExpiration || seg.ExpirationDuration() > cfg.MaxExpiration {
+				t.Fatal("expiration is out of spread:", seg.Expiration)
+			}
+			if seg.Code != "" {
+				codes++
+			}
+			if seg.Timestamp > cfg.Now.Unix() || seg.Timestamp < cfg.Now.Add(-cfg.TimestampSpread).Unix() {
+				t.Fatal("timestamp is out of spread:", seg.Timestamp)
+			}
+		}
+	}
+
+	if x := domains[xgen.XandrID]; x < 1000 || x > 1400 {
+		t.Fatal("unexpected domain mix:", domains)
+	}
+
+	if r := float64(removals) / float64(memberships); r < 0.05 || r > 0.15 {
+		t.Fatal("unexpected removal ratio:", r)
+	}
+
+	if codes == 0 || codes == memberships {
+		t.Fatal("unexpected number of codes:", codes)
+	}
+
+	avro := generate(t, cfg, bss.FormatAvro)
+
+	dr, err = bss.NewSegmentDataReader(bytes.NewReader(avro), bss.FormatAvro, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for {
+		if _, err := dr.Read(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if dr.Line() != cfg.Users {
+		t.Fatal("unexpected number of avro records:", dr.Line())
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for _, modify := range []func(c *Config){
+		func(c *Config) { c.CountZipf = 1 },
+		func(c *Config) { c.MaxSegments = 100 },
+		func(c *Config) { c.RemovalRatio = 2 },
+		func(c *Config) { c.MaxExpiration = 200 * 24 * time.Hour },
+		func(c *Config) { c.Domains = map[xgen.Domain]float64{"4": 1} },
+	} {
+		cfg := testConfig()
+		modify(&cfg)
+		if _, err := New(cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func BenchmarkFormatter(b *testing.B) {
+	cfg := testConfig()
+	cfg.CodeRatio = 0
+
+	g, err := New(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	users := make([]*xgen.UserRecord, cfg.Users)
+	for i := range users {
+		if users[i], err = g.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, format := range []bss.DataFormat{bss.FormatText, bss.FormatAvro} {
+		b.Run(string(format), func(b *testing.B) {
+			df, err := bss.NewSegmentDataFormatter(io.Discard, format, &xgen.FullFormat)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := df.Append(users[i%len(users) : i%len(users)+1]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package bss
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// ValidationIssue describes a problem found in a line (or avro record) of a BSS file.
+type ValidationIssue struct {
+	Line    int
+	UID     string
+	Message string
+}
+
+func (vi ValidationIssue) String() string {
+	if vi.UID == "" {
+		return fmt.Sprintf("line %d: %s", vi.Line, vi.Message)
+	}
+	return fmt.Sprintf("line %d: uid %s: %s", vi.Line, vi.UID, vi.Message)
+}
+
+// ValidationOptions configures Validate.
+type ValidationOptions struct {
+	StrictUUID bool // report device IDs which are not 8-4-4-4-12 UUIDs as issues instead of warnings
+	Duplicates bool // report duplicate users, memory grows with the number of users
+}
+
+// ValidationReport contains problems and summary of a validated BSS file.
+type ValidationReport struct {
+	Users    int                     // number of valid user records
+	Removals int                     // number of segment removals
+	Segments map[xgen.SegmentKey]int // number of users per segment
+	Issues   []ValidationIssue
+	Warnings []ValidationIssue // problems which do not make a record invalid
+}
+
+// Validate reads all records from dr and checks them without uploading anything.
+// Parse errors are reported as issues, read errors are returned. Nil opts checks
+// records without duplicates and reports device IDs which are not UUIDs as warnings.
+func Validate(dr *SegmentDataReader, opts *ValidationOptions) (*ValidationReport, error) {
+	if opts == nil {
+		opts = &ValidationOptions{}
+	}
+
+	vr := &ValidationReport{
+		Segments: make(map[xgen.SegmentKey]int),
+	}
+
+	var seen map[string]int // domain/uid -> line
+	if opts.Duplicates {
+		seen = make(map[string]int)
+	}
+
+	for {
+		user, err := dr.Read()
+		if err == io.EOF {
+			break
+		}
+
+		var le *LineError
+		if errors.As(err, &le) {
+			vr.addIssue(le.Line, "", le.Err.Error())
+			continue
+		}
+		if err != nil {
+			return vr, err
+		}
+
+		line := dr.Line()
+		issues := len(vr.Issues)
+
+		if err := xgen.ValidateUID(user.UID, user.Domain); err != nil {
+			vr.addIssue(line, user.UID, err.Error())
+		} else if user.Domain != xgen.XandrID {
+			if err := xgen.ValidateUUID(user.UID); err != nil {
+				issue := ValidationIssue{Line: line, UID: user.UID, Message: err.Error()}
+				if opts.StrictUUID {
+					vr.Issues = append(vr.Issues, issue)
+				} else {
+					vr.Warnings = append(vr.Warnings, issue)
+				}
+			}
+		}
+
+		if seen != nil {
+			key := string(user.Domain) + "/" + user.UID
+			if prev, ok := seen[key]; ok {
+				vr.addIssue(line, user.UID, fmt.Sprintf("duplicate user, first seen on line %d", prev))
+			} else {
+				seen[key] = line
+			}
+		}
+
+		for i := range user.Segments {
+			seg := &user.Segments[i]
+			if err := xgen.ValidateSegment(seg); err != nil {
+				vr.addIssue(line, user.UID, fmt.Sprintf("seg[%d]: %v", i, err))
+			}
+		}
+
+		if len(vr.Issues) > issues {
+			continue
+		}
+
+		vr.Users++
+		for i := range user.Segments {
+			seg := &user.Segments[i]
+			if seg.Expiration == xgen.Expired {
+				vr.Removals++
+			}
+			vr.Segments[seg.Key()]++
+		}
+	}
+
+	return vr, nil
+}
+
+func (vr *ValidationReport) addIssue(line int, uid string, msg string) {
+	vr.Issues = append(vr.Issues, ValidationIssue{Line: line, UID: uid, Message: msg})
+}
+
+// WriteSummary writes user count, removal count and segment histogram sorted by number of users.
+func (vr *ValidationReport) WriteSummary(w io.Writer) error {
+	keys := make([]xgen.SegmentKey, 0, len(vr.Segments))
+	for k := range vr.Segments {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := vr.Segments[keys[i]], vr.Segments[keys[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return keys[i].String() < keys[j].String()
+	})
+
+	fmt.Fprintf(w, "users:    %d\n", vr.Users)
+	fmt.Fprintf(w, "removals: %d\n", vr.Removals)
+	fmt.Fprintf(w, "issues:   %d\n", len(vr.Issues))
+	fmt.Fprintf(w, "warnings: %d\n", len(vr.Warnings))
+	fmt.Fprintf(w, "segments: %d\n", len(keys))
+
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "  %-20s %d\n", k, vr.Segments[k]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package bss
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestValidate(t *testing.T) {
+	const input = `12345:100:1440:0:0;101:1440:0:0
+12346:100:1440:0:0#101:-1:0:0
+
+12345:102:1440:0:0
+abc:100:1440:0:0
+12347:100;101
+12348:100:999999:0:0
+6D92078A-8246-4BA4-AE5B-76104861E7DC:100:1440:0:0^3
+0000-123123-132123123-3212312:100:1440:0:0^8
+`
+
+	dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vr, err := Validate(dr, &ValidationOptions{Duplicates: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"line 4: uid 12345: duplicate user, first seen on line 1",
+		`line 5: uid abc: uid "abc" is not a valid xandr id`,
+		"line 6: seg[0] has 1 fields, expected 4",
+		"line 7: uid 12348: seg[0]: expiration 999999 is not in the range [-1, 259200]",
+	}
+
+	if len(vr.Issues) != len(expected) {
+		t.Fatalf("expected %d issues, got %d: %v", len(expected), len(vr.Issues), vr.Issues)
+	}
+
+	for i, issue := range vr.Issues {
+		if issue.String() != expected[i] {
+			t.Fatal("invalid issue:", issue)
+		}
+	}
+
+	if len(vr.Warnings) != 1 || vr.Warnings[0].String() != `line 9: uid 0000-123123-132123123-3212312: uid "0000-123123-132123123-3212312" is not a valid uuid` {
+		t.Fatal("invalid warnings:", vr.Warnings)
+	}
+
+	if vr.Users != 4 || vr.Removals != 1 {
+		t.Fatalf("invalid summary: users %d, removals %d", vr.Users, vr.Removals)
+	}
+
+	if vr.Segments[xgen.SegmentKey{ID: 100}] != 4 || vr.Segments[xgen.SegmentKey{ID: 101}] != 2 {
+		t.Fatalf("invalid histogram: %v", vr.Segments)
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	const input = `12345:100:1440:0:0
+12345:101:1440:0:0
+0000-123123-132123123-3212312:100:1440:0:0^8
+`
+
+	dr, err := NewSegmentDataReader(strings.NewReader(input), FormatText, &xgen.FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vr, err := Validate(dr, &ValidationOptions{StrictUUID: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// duplicates are not checked without the option
+	if len(vr.Issues) != 1 || vr.Issues[0].Line != 3 || len(vr.Warnings) != 0 {
+		t.Fatalf("unexpected issues: %v, warnings: %v", vr.Issues, vr.Warnings)
+	}
+
+	if vr.Users != 2 {
+		t.Fatal("invalid users:", vr.Users)
+	}
+}
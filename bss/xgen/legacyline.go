@@ -6,6 +6,7 @@ import (
 	"io"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 const legacyLineTemplate = "{UID}{SEP_1}{SEGMENTS_TO_ADD}{SEP_4}{SEGMENTS_TO_REMOVE}{SEP_5}{DOMAIN}"
@@ -29,10 +30,39 @@ type TextEncoderParameters struct {
 	Sep4          string // Separator between segment additions block and segment removals block
 	Sep5          string // Separator before domain
 	SegmentFields []SegmentFieldName
+
+	// Collision selects what FormatLine does with a UID or segment code containing
+	// a separator or a line break. CollisionReject is used by default.
+	Collision CollisionPolicy `json:",omitempty"`
+
+	// Replacement substitutes each colliding character with CollisionReplace policy, "_" by default.
+	Replacement string `json:",omitempty"`
+}
+
+// CollisionPolicy is a policy for values colliding with separators.
+type CollisionPolicy string
+
+const (
+	CollisionReject  CollisionPolicy = "reject"  // the record fails with CollisionError
+	CollisionReplace CollisionPolicy = "replace" // colliding characters are replaced with Replacement
+)
+
+// DefaultReplacement is used with CollisionReplace policy if Replacement is empty.
+const DefaultReplacement = "_"
+
+// CollisionError is returned by FormatLine if a value contains a separator or a line break.
+type CollisionError struct {
+	Field string // "UID" or "seg[i].Code"
+	Value string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("%s %q contains a separator or a line break", e.Field, e.Value)
 }
 
 type TextEncoder struct {
 	parameters TextEncoderParameters
+	special    string // separators and line breaks which cannot appear in values
 }
 
 var MinimalFormat = TextEncoderParameters{
@@ -73,14 +103,28 @@ var FullExternalFormat = TextEncoderParameters{
 	},
 }
 
+// Parameters returns the parameters the encoder was created with.
+func (tf *TextEncoder) Parameters() TextEncoderParameters {
+	return tf.parameters
+}
+
 func (tf *TextEncoder) FormatLine(ur *UserRecord) (string, error) {
+	if ur.UID == "" {
+		return "", errors.New("UID is empty")
+	}
+
 	if _, ok := domains[ur.Domain]; !ok {
 		return "", fmt.Errorf("invalid domain: %s", ur.Domain)
 	}
 
+	uid, err := tf.escape("UID", ur.UID)
+	if err != nil {
+		return "", err
+	}
+
 	var b strings.Builder
 
-	b.WriteString(ur.UID)
+	b.WriteString(uid)
 	b.WriteString(tf.parameters.Sep1)
 
 	var adds []Segment
@@ -101,7 +145,7 @@ func (tf *TextEncoder) FormatLine(ur *UserRecord) (string, error) {
 	if len(rems) > 0 {
 		b.WriteString(tf.parameters.Sep4)
 		if err := genSegments(&b, tf, rems); err != nil {
-			return "", nil
+			return "", err
 		}
 	}
 
@@ -113,9 +157,31 @@ func (tf *TextEncoder) FormatLine(ur *UserRecord) (string, error) {
 	return b.String(), nil
 }
 
-func genSegments(w io.Writer, tf *TextEncoder, list []Segment) error {
-	const maxValue = 2147483647
+// escape returns s if it does not contain separators or line breaks. Otherwise it
+// fails or replaces colliding characters according to the collision policy.
+func (tf *TextEncoder) escape(field, s string) (string, error) {
+	if !strings.ContainsAny(s, tf.special) {
+		return s, nil
+	}
+
+	if tf.parameters.Collision != CollisionReplace {
+		return "", &CollisionError{Field: field, Value: s}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		// separators are ASCII so bytes of multibyte characters never match
+		if strings.IndexByte(tf.special, s[i]) >= 0 {
+			b.WriteString(tf.parameters.Replacement)
+		} else {
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), nil
+}
 
+func genSegments(w io.Writer, tf *TextEncoder, list []Segment) error {
 	for i, seg := range list {
 		for j, sf := range tf.parameters.SegmentFields {
 			switch sf {
@@ -128,7 +194,11 @@ func genSegments(w io.Writer, tf *TextEncoder, list []Segment) error {
 				if seg.Code == "" {
 					return fmt.Errorf("seg[%d].Code is empty", i)
 				}
-				io.WriteString(w, seg.Code)
+				code, err := tf.escape(fmt.Sprintf("seg[%d].Code", i), seg.Code)
+				if err != nil {
+					return err
+				}
+				io.WriteString(w, code)
 			case MemberIdField:
 				if seg.MemberID == 0 {
 					return fmt.Errorf("seg[%d].MemberID is zero", i)
@@ -140,8 +210,8 @@ func genSegments(w io.Writer, tf *TextEncoder, list []Segment) error {
 				}
 				fmt.Fprintf(w, "%d", seg.Expiration)
 			case ValueField:
-				if seg.Value < 0 || seg.Value > maxValue {
-					return fmt.Errorf("seg[%d].Value is not in the range [-1, %d]", i, maxValue)
+				if seg.Value < 0 || seg.Value > MaxValue {
+					return fmt.Errorf("seg[%d].Value is not in the range [-1, %d]", i, MaxValue)
 				}
 				fmt.Fprintf(w, "%d", seg.Value)
 			case TimestampField:
@@ -181,12 +251,33 @@ func NewTextEncoder(parameters TextEncoderParameters) (*TextEncoder, error) {
 		return nil, err
 	}
 
+	if err = checkAmbiguity(&parameters); err != nil {
+		return nil, err
+	}
+
+	tf.special = strings.Join(sp, "") + "\r\n"
+
+	switch parameters.Collision {
+	case "", CollisionReject:
+	case CollisionReplace:
+		if parameters.Replacement == "" {
+			parameters.Replacement = DefaultReplacement
+		}
+		if strings.ContainsAny(parameters.Replacement, tf.special) {
+			return nil, errors.New("replacement cannot contain separators or line breaks")
+		}
+	default:
+		return nil, fmt.Errorf("unknown collision policy: %s", parameters.Collision)
+	}
+
 	tf.parameters.Sep1 = parameters.Sep1
 	tf.parameters.Sep2 = parameters.Sep2
 	tf.parameters.Sep3 = parameters.Sep3
 	tf.parameters.Sep4 = parameters.Sep4
 	tf.parameters.Sep5 = parameters.Sep5
 	tf.parameters.SegmentFields = parameters.SegmentFields
+	tf.parameters.Collision = parameters.Collision
+	tf.parameters.Replacement = parameters.Replacement
 
 	return &tf, nil
 }
@@ -227,7 +318,8 @@ func checkSegments(sf []SegmentFieldName) error {
 
 func checkSeparators(sp []string) error {
 	for i, s := range sp {
-		if len(s) != 1 && s != "\t" && s != " " {
+		// a byte of a multibyte character would split UTF-8 values
+		if len(s) != 1 || s[0] >= utf8.RuneSelf {
 			return fmt.Errorf("sep%d should be a single character", i+1)
 		}
 		if strings.ContainsAny(s, NotAllowed) {
@@ -243,6 +335,34 @@ func checkSeparators(sp []string) error {
 	return nil
 }
 
+// checkAmbiguity checks that separators which can follow each other in a line are distinct.
+// Sep1 may repeat other separators because UID is cut at its first occurrence and values
+// cannot contain separators. Sep3 is not used if there is a single segment field.
+func checkAmbiguity(p *TextEncoderParameters) error {
+	multiField := len(p.SegmentFields) > 1
+
+	pairs := []struct {
+		i, j  int
+		a, b  string
+		check bool
+	}{
+		{2, 3, p.Sep2, p.Sep3, multiField},
+		{2, 4, p.Sep2, p.Sep4, true},
+		{3, 4, p.Sep3, p.Sep4, multiField},
+		{2, 5, p.Sep2, p.Sep5, true},
+		{3, 5, p.Sep3, p.Sep5, multiField},
+		{4, 5, p.Sep4, p.Sep5, true},
+	}
+
+	for _, pair := range pairs {
+		if pair.check && pair.a == pair.b {
+			return fmt.Errorf("sep%d and sep%d should be different", pair.i, pair.j)
+		}
+	}
+
+	return nil
+}
+
 func checkIfNum(s string) bool {
 	for _, r := range s {
 		if !unicode.IsNumber(r) {
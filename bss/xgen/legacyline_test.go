@@ -1,6 +1,7 @@
 package xgen
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -262,3 +263,136 @@ func TestCheckSeparators(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestCheckSeparatorsMultibyte(t *testing.T) {
+	err := checkSeparators([]string{";", "§"})
+	if err == nil || err.Error() != "sep2 should be a single character" {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestEmptyUID(t *testing.T) {
+	enc, err := NewTextEncoder(FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = enc.FormatLine(&UserRecord{Segments: []Segment{{ID: 100}}})
+	if err == nil || err.Error() != "UID is empty" {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestInvalidRemovedSegId(t *testing.T) {
+	ur := &UserRecord{
+		UID: "12345",
+		Segments: []Segment{
+			{ID: 100, Expiration: 1440},
+			{Expiration: Expired},
+		},
+	}
+
+	enc, err := NewTextEncoder(FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := enc.FormatLine(ur)
+	if err == nil || err.Error() != "seg[0].ID is zero" {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if line != "" {
+		t.Fatal("expected empty line, got", line)
+	}
+}
+
+func TestCollisionReject(t *testing.T) {
+	enc, err := NewTextEncoder(FullExternalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ur := range []*UserRecord{
+		{UID: "a:b", Segments: []Segment{{Code: "c", MemberID: 1}}},
+		{UID: "ab\n", Segments: []Segment{{Code: "c", MemberID: 1}}},
+		{UID: "ab", Segments: []Segment{{Code: "c", MemberID: 1}, {Code: "c;d", MemberID: 1}}},
+	} {
+		_, err := enc.FormatLine(ur)
+
+		var ce *CollisionError
+		if !errors.As(err, &ce) {
+			t.Fatalf("%+v: expected collision error, got %v", ur, err)
+		}
+	}
+
+	_, err = enc.FormatLine(&UserRecord{UID: "ab", Segments: []Segment{{Code: "c", MemberID: 1}, {Code: "c;d", MemberID: 1}}})
+	if err == nil || err.Error() != `seg[1].Code "c;d" contains a separator or a line break` {
+		t.Fatal("invalid error message:", err)
+	}
+}
+
+func TestCollisionReplace(t *testing.T) {
+	p := FullExternalFormat
+	p.Collision = CollisionReplace
+
+	enc, err := NewTextEncoder(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ur := &UserRecord{
+		UID:      "a:b^c",
+		Segments: []Segment{{Code: "c;d#e\r\n", MemberID: 1, Expiration: 1440}},
+	}
+
+	line, err := enc.FormatLine(ur)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "a_b_c:c_d_e__:1:1440:0:0"
+	if line != expected {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, line)
+	}
+
+	p.Replacement = "#"
+	if _, err := NewTextEncoder(p); err == nil {
+		t.Fatal("replacement with a separator should fail")
+	}
+}
+
+func TestAmbiguousSeparators(t *testing.T) {
+	for _, tc := range []struct {
+		seps   string
+		fields []SegmentFieldName
+		err    string
+	}{
+		{":;:#^", FullFormat.SegmentFields, ""},
+		{"^;,#^", FullFormat.SegmentFields, ""},
+		{":;;#^", MinimalFormat.SegmentFields, ""},
+		{":;;#^", FullFormat.SegmentFields, "sep2 and sep3 should be different"},
+		{":;,;^", MinimalFormat.SegmentFields, "sep2 and sep4 should be different"},
+		{":;,,^", FullFormat.SegmentFields, "sep3 and sep4 should be different"},
+		{":;,#;", MinimalFormat.SegmentFields, "sep2 and sep5 should be different"},
+		{":;,#,", FullFormat.SegmentFields, "sep3 and sep5 should be different"},
+		{":;,##", MinimalFormat.SegmentFields, "sep4 and sep5 should be different"},
+	} {
+		p := TextEncoderParameters{
+			Sep1:          tc.seps[0:1],
+			Sep2:          tc.seps[1:2],
+			Sep3:          tc.seps[2:3],
+			Sep4:          tc.seps[3:4],
+			Sep5:          tc.seps[4:5],
+			SegmentFields: tc.fields,
+		}
+
+		_, err := NewTextEncoder(p)
+		if tc.err == "" && err != nil {
+			t.Fatalf("%s: %v", tc.seps, err)
+		}
+		if tc.err != "" && (err == nil || err.Error() != tc.err) {
+			t.Fatalf("%s\nexpected: %+v\nactual  : %+v", tc.seps, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package xgen
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TextDecoder parses lines written in Legacy BSS text format back into user records.
+type TextDecoder struct {
+	parameters TextEncoderParameters
+}
+
+// NewTextDecoder creates a decoder for lines generated with the same parameters by TextEncoder.
+func NewTextDecoder(parameters TextEncoderParameters) (*TextDecoder, error) {
+	enc, err := NewTextEncoder(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TextDecoder{parameters: enc.parameters}, nil
+}
+
+// ParseLine parses a single line without trailing newline.
+// Segments from the removals block have Expiration set to Expired.
+func (td *TextDecoder) ParseLine(line string) (*UserRecord, error) {
+	p := &td.parameters
+
+	uid, rest, ok := strings.Cut(line, p.Sep1)
+	if !ok {
+		return nil, fmt.Errorf("sep1 %q not found after UID", p.Sep1)
+	}
+	if uid == "" {
+		return nil, fmt.Errorf("UID is empty")
+	}
+
+	ur := &UserRecord{UID: uid}
+
+	if i := strings.LastIndex(rest, p.Sep5); i >= 0 {
+		ur.Domain = Domain(rest[i+len(p.Sep5):])
+		rest = rest[:i]
+		if !ValidDomain(ur.Domain) {
+			return nil, fmt.Errorf("invalid domain: %s", ur.Domain)
+		}
+	}
+
+	adds, rems, hasRems := strings.Cut(rest, p.Sep4)
+
+	var err error
+
+	ur.Segments, err = td.parseSegments(ur.Segments, adds, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasRems {
+		ur.Segments, err = td.parseSegments(ur.Segments, rems, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ur, nil
+}
+
+func (td *TextDecoder) parseSegments(list []Segment, block string, removal bool) ([]Segment, error) {
+	if block == "" {
+		return list, nil
+	}
+
+	for _, item := range strings.Split(block, td.parameters.Sep2) {
+		i := len(list)
+
+		fields := strings.Split(item, td.parameters.Sep3)
+		if len(fields) != len(td.parameters.SegmentFields) {
+			return nil, fmt.Errorf("seg[%d] has %d fields, expected %d", i, len(fields), len(td.parameters.SegmentFields))
+		}
+
+		var seg Segment
+		for j, sf := range td.parameters.SegmentFields {
+			if err := parseSegmentField(&seg, sf, fields[j]); err != nil {
+				return nil, fmt.Errorf("seg[%d].%s: %w", i, sf, err)
+			}
+		}
+
+		if removal {
+			seg.Expiration = Expired
+		}
+
+		list = append(list, seg)
+	}
+
+	return list, nil
+}
+
+func parseSegmentField(seg *Segment, sf SegmentFieldName, s string) error {
+	switch sf {
+	case SegCodeField:
+		if s == "" {
+			return fmt.Errorf("empty code")
+		}
+		seg.Code = s
+		return nil
+	case TimestampField:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		seg.Timestamp = n
+		return nil
+	}
+
+	n, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+
+	switch sf {
+	case SegIdField:
+		seg.ID = int32(n)
+	case MemberIdField:
+		seg.MemberID = int32(n)
+	case ExpirationField:
+		seg.Expiration = int32(n)
+	case ValueField:
+		seg.Value = int32(n)
+	}
+
+	return nil
+}
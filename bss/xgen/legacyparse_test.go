@@ -0,0 +1,114 @@
+package xgen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLineFull(t *testing.T) {
+	ur := &UserRecord{
+		UID:    "0000-123123-132123123-3212312",
+		Domain: IDFA,
+		Segments: []Segment{
+			{ID: 100, Expiration: 1440, Value: 123, Timestamp: 123456},
+			{ID: 101, Expiration: Expired, Value: 123, Timestamp: 123456},
+		},
+	}
+
+	enc, err := NewTextEncoder(FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := enc.FormatLine(ur)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := NewTextDecoder(FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := dec.ParseLine(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ur, parsed) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", ur, parsed)
+	}
+}
+
+func TestParseLineRemovals(t *testing.T) {
+	dec, err := NewTextDecoder(MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ur, err := dec.ParseLine("12345:100#101;102")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Segment{
+		{ID: 100},
+		{ID: 101, Expiration: Expired},
+		{ID: 102, Expiration: Expired},
+	}
+
+	if ur.UID != "12345" || !reflect.DeepEqual(ur.Segments, expected) {
+		t.Fatalf("invalid record: %+v", ur)
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	dec, err := NewTextDecoder(FullFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := map[string]string{
+		"12345":               `sep1 ":" not found after UID`,
+		"12345:100:1440:123":  "seg[0] has 3 fields, expected 4",
+		"12345:100:x:123:0":   `seg[0].EXPIRATION: invalid number "x"`,
+		"12345:100:1:2:3^aaa": "invalid domain: aaa",
+	}
+
+	for line, msg := range lines {
+		_, err := dec.ParseLine(line)
+		if err == nil {
+			t.Fatal("should return error for", line)
+		}
+		if err.Error() != msg {
+			t.Fatal("invalid error message:", err.Error())
+		}
+	}
+}
+
+func TestValidateUID(t *testing.T) {
+	if err := ValidateUID("12345", XandrID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateUID("6D92078A-8246-4BA4-AE5B-76104861E7DC", IDFA); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateUID("abc", XandrID); err == nil {
+		t.Fatal("should return error")
+	}
+	if err := ValidateUID("0000-123123-132123123-3212312", AAID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateUID("", AAID); err == nil {
+		t.Fatal("should return error")
+	}
+}
+
+func TestValidateUUID(t *testing.T) {
+	if err := ValidateUUID("6D92078A-8246-4BA4-AE5B-76104861E7DC"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateUUID("0000-123123-132123123-3212312"); err == nil {
+		t.Fatal("should return error")
+	}
+}
@@ -1,11 +1,29 @@
 package xgen
 
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
 const (
 	Expired           = -1            // Set Segment.Expiration field to remove user from the segment
 	DefaultExpiration = 0             // Segment expiration will be set to member's default
 	MaxExpiration     = 180 * 24 * 60 // 180 days in minutes
+	MaxValue          = 2147483647    // Maximum value of Segment.Value
+
+	MaxExpirationDuration = MaxExpiration * time.Minute
 )
 
+// ExpirationMinutes converts duration to Segment.Expiration rounding up to whole minutes.
+// The result is not clamped to MaxExpiration.
+func ExpirationMinutes(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Minute - 1) / time.Minute)
+}
+
 type Segment struct {
 	ID         int32
 	Code       string
@@ -15,6 +33,35 @@ type Segment struct {
 	Timestamp  int64
 }
 
+// ExpirationDuration returns segment expiration as duration. It is zero for DefaultExpiration
+// and negative for Expired.
+func (s *Segment) ExpirationDuration() time.Duration {
+	return time.Duration(s.Expiration) * time.Minute
+}
+
+// SegmentKey identifies a segment either by ID or by code and member ID.
+type SegmentKey struct {
+	ID       int32
+	Code     string
+	MemberID int32
+}
+
+// Key returns the key identifying the segment.
+func (s *Segment) Key() SegmentKey {
+	if s.ID != 0 {
+		return SegmentKey{ID: s.ID}
+	}
+	return SegmentKey{Code: s.Code, MemberID: s.MemberID}
+}
+
+// String returns segment ID or code/member_id pair.
+func (k SegmentKey) String() string {
+	if k.ID != 0 {
+		return strconv.FormatInt(int64(k.ID), 10)
+	}
+	return k.Code + "/" + strconv.FormatInt(int64(k.MemberID), 10)
+}
+
 type Domain string
 
 const (
@@ -29,6 +76,37 @@ var domains = map[Domain]string{
 	AAID:    "8",
 }
 
+// domainNames maps lowercase domain names to domains.
+var domainNames = map[string]Domain{
+	"xandr":    XandrID,
+	"xandr_id": XandrID,
+	"idfa":     IDFA,
+	"aaid":     AAID,
+}
+
+// NormalizeDomain returns the domain for a name such as "xandr_id" or "IDFA" in any case.
+// Other values are returned without surrounding spaces, empty domain is XandrID.
+func NormalizeDomain(d Domain) Domain {
+	s := strings.TrimSpace(string(d))
+	if n, ok := domainNames[strings.ToLower(s)]; ok {
+		return n
+	}
+	return Domain(s)
+}
+
+// Name returns human readable domain name.
+func (d Domain) Name() string {
+	switch d {
+	case XandrID:
+		return "xandr"
+	case IDFA:
+		return "idfa"
+	case AAID:
+		return "aaid"
+	}
+	return string(d)
+}
+
 type UserRecord struct {
 	UID    string
 	Domain Domain
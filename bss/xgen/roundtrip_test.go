@@ -0,0 +1,409 @@
+package xgen
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var allFields = []SegmentFieldName{SegIdField, SegCodeField, MemberIdField, ExpirationField, ValueField, TimestampField}
+
+// separators which pass checkSeparators
+const sepPool = ":;,#^!@%&=~_<>'\"`. \t"
+
+// randomParameters returns valid parameters with distinct separators.
+func randomParameters(rnd *rand.Rand) TextEncoderParameters {
+	perm := rnd.Perm(len(sepPool))
+
+	p := TextEncoderParameters{
+		Sep1: string(sepPool[perm[0]]),
+		Sep2: string(sepPool[perm[1]]),
+		Sep3: string(sepPool[perm[2]]),
+		Sep4: string(sepPool[perm[3]]),
+		Sep5: string(sepPool[perm[4]]),
+	}
+
+	if rnd.Intn(2) == 0 {
+		p.SegmentFields = append(p.SegmentFields, SegIdField)
+	} else {
+		p.SegmentFields = append(p.SegmentFields, SegCodeField, MemberIdField)
+	}
+
+	for _, f := range []SegmentFieldName{ExpirationField, ValueField, TimestampField} {
+		if rnd.Intn(2) == 0 {
+			p.SegmentFields = append(p.SegmentFields, f)
+		}
+	}
+
+	rnd.Shuffle(len(p.SegmentFields), func(i, j int) {
+		p.SegmentFields[i], p.SegmentFields[j] = p.SegmentFields[j], p.SegmentFields[i]
+	})
+
+	return p
+}
+
+// randomString returns a non-empty string without separators of p.
+func randomString(rnd *rand.Rand, p *TextEncoderParameters) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.:;,#^ "
+
+	var b strings.Builder
+	for b.Len() == 0 {
+		for i, n := 0, 1+rnd.Intn(20); i < n; i++ {
+			c := alphabet[rnd.Intn(len(alphabet))]
+			if !strings.ContainsRune(p.Sep1+p.Sep2+p.Sep3+p.Sep4+p.Sep5, rune(c)) {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// randomRecord returns a record which can be encoded with p.
+func randomRecord(rnd *rand.Rand, p *TextEncoderParameters) *UserRecord {
+	ur := &UserRecord{Domain: []Domain{XandrID, IDFA, AAID}[rnd.Intn(3)]}
+
+	switch {
+	case ur.Domain != XandrID:
+		ur.UID = fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", rnd.Uint32(), rnd.Intn(1<<16), rnd.Intn(1<<16), rnd.Intn(1<<16), rnd.Int63n(1<<48))
+	case rnd.Intn(2) == 0:
+		ur.UID = strconv.FormatInt(rnd.Int63(), 10)
+	default:
+		ur.UID = randomString(rnd, p)
+	}
+
+	for i, n := 0, rnd.Intn(10); i < n; i++ {
+		seg := Segment{
+			ID:         1 + rnd.Int31(),
+			Code:       randomString(rnd, p),
+			MemberID:   1 + rnd.Int31n(10000),
+			Expiration: rnd.Int31n(MaxExpiration+2) - 1,
+			Value:      rnd.Int31(),
+			Timestamp:  rnd.Int63n(1 << 40),
+		}
+		ur.Segments = append(ur.Segments, seg)
+	}
+
+	return ur
+}
+
+// decoded returns the record expected after encoding with p and decoding: additions followed
+// by removals with fields missing in the format set to zero.
+func decoded(ur *UserRecord, p *TextEncoderParameters) *UserRecord {
+	exp := &UserRecord{UID: ur.UID, Domain: ur.Domain}
+
+	for _, removals := range []bool{false, true} {
+		for _, seg := range ur.Segments {
+			if (seg.Expiration == Expired) != removals {
+				continue
+			}
+
+			var s Segment
+			for _, f := range p.SegmentFields {
+				switch f {
+				case SegIdField:
+					s.ID = seg.ID
+				case SegCodeField:
+					s.Code = seg.Code
+				case MemberIdField:
+					s.MemberID = seg.MemberID
+				case ExpirationField:
+					s.Expiration = seg.Expiration
+				case ValueField:
+					s.Value = seg.Value
+				case TimestampField:
+					s.Timestamp = seg.Timestamp
+				}
+			}
+			if removals {
+				s.Expiration = Expired
+			}
+
+			exp.Segments = append(exp.Segments, s)
+		}
+	}
+
+	return exp
+}
+
+// collides reports whether the UID or encoded codes contain separators or line breaks.
+func collides(ur *UserRecord, p *TextEncoderParameters) bool {
+	seps := p.Sep1 + p.Sep2 + p.Sep3 + p.Sep4 + p.Sep5 + "\r\n"
+
+	if strings.ContainsAny(ur.UID, seps) {
+		return true
+	}
+
+	for _, f := range p.SegmentFields {
+		if f != SegCodeField {
+			continue
+		}
+		for _, seg := range ur.Segments {
+			if strings.ContainsAny(seg.Code, seps) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// replaced returns a copy of ur with characters colliding with separators of p replaced.
+func replaced(ur *UserRecord, p *TextEncoderParameters) *UserRecord {
+	seps := p.Sep1 + p.Sep2 + p.Sep3 + p.Sep4 + p.Sep5 + "\r\n"
+	replace := func(s string) string {
+		for _, c := range seps {
+			s = strings.ReplaceAll(s, string(c), DefaultReplacement)
+		}
+		return s
+	}
+
+	r := &UserRecord{UID: replace(ur.UID), Domain: ur.Domain}
+	for _, seg := range ur.Segments {
+		seg.Code = replace(seg.Code)
+		r.Segments = append(r.Segments, seg)
+	}
+
+	return r
+}
+
+func checkRoundTrip(t *testing.T, ur *UserRecord, p *TextEncoderParameters) {
+	enc, err := NewTextEncoder(*p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := NewTextDecoder(*p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := enc.FormatLine(ur)
+	if err != nil {
+		t.Fatalf("%+v: %v", ur, err)
+	}
+
+	actual, err := dec.ParseLine(line)
+	if err != nil {
+		t.Fatalf("%q: %v", line, err)
+	}
+
+	expected := decoded(ur, p)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("line %q, parameters %+v\nexpected: %+v\nactual  : %+v", line, *p, expected, actual)
+	}
+}
+
+func TestTextRoundTripProperty(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		p := randomParameters(rnd)
+		checkRoundTrip(t, randomRecord(rnd, &p), &p)
+	}
+
+	for _, p := range []TextEncoderParameters{MinimalFormat, FullFormat, FullExternalFormat} {
+		for i := 0; i < 500; i++ {
+			checkRoundTrip(t, randomRecord(rnd, &p), &p)
+		}
+	}
+}
+
+func FuzzTextRoundTrip(f *testing.F) {
+	f.Add("12345", uint8(0), ":;:#^", uint8(1), int32(100), "code", int32(1), int32(1440), int32(5), int64(1700000000), false)
+	f.Add("6d92078a-8246-4ba4-ae5b-76104861e7dc", uint8(1), ":;,#^", uint8(0x3e), int32(0), "a:b", int32(7), int32(-1), int32(0), int64(0), true)
+	f.Add("1", uint8(2), "\t ,#^", uint8(0x39), int32(-5), "", int32(0), int32(MaxExpiration+1), int32(-1), int64(-1), false)
+
+	f.Fuzz(func(t *testing.T, uid string, domain uint8, seps string, fields uint8, id int32, code string, memberID, expiration, value int32, ts int64, removal bool) {
+		if len(seps) < 5 {
+			return
+		}
+
+		p := TextEncoderParameters{
+			Sep1: seps[0:1],
+			Sep2: seps[1:2],
+			Sep3: seps[2:3],
+			Sep4: seps[3:4],
+			Sep5: seps[4:5],
+		}
+		for i, f := range allFields {
+			if fields&(1<<i) != 0 {
+				p.SegmentFields = append(p.SegmentFields, f)
+			}
+		}
+
+		enc, err := NewTextEncoder(p)
+		if err != nil {
+			return
+		}
+
+		ur := &UserRecord{
+			UID:    uid,
+			Domain: []Domain{XandrID, IDFA, AAID, "4"}[domain%4],
+			Segments: []Segment{
+				{ID: id, Code: code, MemberID: memberID, Expiration: expiration, Value: value, Timestamp: ts},
+				{ID: id + 1, Code: code + "x", MemberID: memberID, Value: value / 2},
+			},
+		}
+		if removal {
+			ur.Segments[1].Expiration = Expired
+		}
+
+		dec, err := NewTextDecoder(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		line, err := enc.FormatLine(ur)
+		if err == nil && collides(ur, &p) {
+			t.Fatalf("collision is not detected: %q", line)
+		}
+		if err == nil {
+			actual, err := dec.ParseLine(line)
+			if err != nil {
+				t.Fatalf("%q: %v", line, err)
+			}
+
+			if expected := decoded(ur, &p); !reflect.DeepEqual(actual, expected) {
+				t.Fatalf("line %q\nexpected: %+v\nactual  : %+v", line, expected, actual)
+			}
+		}
+
+		// colliding characters are replaced and the rest of the record is kept
+		p.Collision = CollisionReplace
+		enc, err = NewTextEncoder(p)
+		if err != nil {
+			return
+		}
+
+		line, err = enc.FormatLine(ur)
+		if err != nil {
+			var ce *CollisionError
+			if errors.As(err, &ce) {
+				t.Fatal(err)
+			}
+			return
+		}
+
+		actual, err := dec.ParseLine(line)
+		if err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+
+		if expected := decoded(replaced(ur, &p), &p); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("line %q\nexpected: %+v\nactual  : %+v", line, expected, actual)
+		}
+	})
+}
+
+func FuzzParseLine(f *testing.F) {
+	f.Add("12345:100;101#102^3")
+	f.Add("12345:code:1:1440:5:1700000000;x:2:0:0:0#y:3:-1:0:0^8")
+	f.Add("12345::;#^^")
+
+	formats := []TextEncoderParameters{MinimalFormat, FullFormat, FullExternalFormat}
+
+	f.Fuzz(func(t *testing.T, line string) {
+		for _, p := range formats {
+			enc, err := NewTextEncoder(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dec, err := NewTextDecoder(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ur, err := dec.ParseLine(line)
+			if err != nil {
+				continue
+			}
+
+			// the encoding of a parsed line is stable
+			line2, err := enc.FormatLine(ur)
+			if err != nil {
+				continue
+			}
+
+			ur2, err := dec.ParseLine(line2)
+			if err != nil {
+				t.Fatalf("%q encoded as %q: %v", line, line2, err)
+			}
+
+			line3, err := enc.FormatLine(ur2)
+			if err != nil || line3 != line2 {
+				t.Fatalf("%q encoded as %q and then as %q: %v", line, line2, line3, err)
+			}
+		}
+	})
+}
+
+func benchmarkRecords(p *TextEncoderParameters) []*UserRecord {
+	rnd := rand.New(rand.NewSource(1))
+
+	users := make([]*UserRecord, 1000)
+	for i := range users {
+		users[i] = randomRecord(rnd, p)
+	}
+
+	return users
+}
+
+func BenchmarkFormatLine(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		p    TextEncoderParameters
+	}{{"minimal", MinimalFormat}, {"full", FullFormat}, {"full-external", FullExternalFormat}} {
+		b.Run(bc.name, func(b *testing.B) {
+			enc, err := NewTextEncoder(bc.p)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			users := benchmarkRecords(&bc.p)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := enc.FormatLine(users[i%len(users)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseLine(b *testing.B) {
+	p := FullFormat
+
+	enc, err := NewTextEncoder(p)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	dec, err := NewTextDecoder(p)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var lines []string
+	for _, ur := range benchmarkRecords(&p) {
+		line, err := enc.FormatLine(ur)
+		if err != nil {
+			b.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := dec.ParseLine(lines[i%len(lines)]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
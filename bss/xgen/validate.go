@@ -0,0 +1,83 @@
+package xgen
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ValidDomain reports whether the domain is supported.
+func ValidDomain(d Domain) bool {
+	_, ok := domains[d]
+	return ok
+}
+
+// ValidateUID checks that uid has a valid format for the domain.
+// Xandr IDs are positive 64-bit integers, IDFA and AAID are not empty.
+// Device IDs are not required to be UUIDs, see ValidateUUID.
+func ValidateUID(uid string, d Domain) error {
+	if uid == "" {
+		return errors.New("uid is empty")
+	}
+
+	switch d {
+	case XandrID:
+		n, err := strconv.ParseInt(uid, 10, 64)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("uid %q is not a valid xandr id", uid)
+		}
+	case IDFA, AAID:
+	default:
+		return fmt.Errorf("invalid domain: %s", d)
+	}
+
+	return nil
+}
+
+// ValidateSegment checks segment fields regardless of the output format.
+func ValidateSegment(seg *Segment) error {
+	if seg.ID == 0 && seg.Code == "" {
+		return errors.New("segment has neither ID nor Code")
+	}
+	if seg.Code != "" && seg.MemberID == 0 {
+		return fmt.Errorf("segment code %q has no MemberID", seg.Code)
+	}
+	if seg.Expiration < Expired || seg.Expiration > MaxExpiration {
+		return fmt.Errorf("expiration %d is not in the range [-1, %d]", seg.Expiration, MaxExpiration)
+	}
+	if seg.Value < 0 || seg.Value > MaxValue {
+		return fmt.Errorf("value %d is not in the range [0, %d]", seg.Value, MaxValue)
+	}
+	if seg.Timestamp < 0 {
+		return fmt.Errorf("timestamp %d is negative", seg.Timestamp)
+	}
+	return nil
+}
+
+// ValidateUUID checks that a device ID has 8-4-4-4-12 hex format.
+func ValidateUUID(uid string) error {
+	if !isUUID(uid) {
+		return fmt.Errorf("uid %q is not a valid uuid", uid)
+	}
+	return nil
+}
+
+// isUUID checks 8-4-4-4-12 hex format.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/lld"
+)
+
+func runAudit(args []string) error {
+	var in formatFlags
+
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	in.register(fs, "")
+	feed := fs.String("feed", "", "segment_feed `file`, can be gzip compressed")
+	feedFormat := fs.String("feed-format", string(lld.FormatTSV), "segment feed `format`: csv or avro")
+	mapping := fs.String("map", "", "CSV or JSON `file` mapping segment codes to IDs")
+	maxUsers := fs.Int("users", 10, "maximum number of listed `users` per segment, 0 lists all")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss audit [flags] -feed segment_feed file")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *feed == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	format, params, err := in.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	ff, err := os.Open(*feed)
+	if err != nil {
+		return err
+	}
+	defer ff.Close()
+
+	rd, err := lld.NewReader[lld.SegmentFeed](ff, lld.Format(*feedFormat), lld.SegmentFeedColumns)
+	if err != nil {
+		return err
+	}
+
+	observed, err := lld.AggregateSegmentFeed(rd)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *feed, err)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dr, err := bss.NewSegmentDataReader(f, format, params)
+	if err != nil {
+		return err
+	}
+
+	var uploaded bss.RecordReader = dr
+
+	if *mapping != "" {
+		sr, err := loadResolver(*mapping)
+		if err != nil {
+			return err
+		}
+		uploaded = &transformReader{r: dr, transform: sr.ToID}
+	}
+
+	rep, err := lld.Audit(uploaded, &lld.SliceReader{Records: observed})
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	return rep.WriteText(os.Stdout, *maxUsers)
+}
+
+// transformReader applies transform to each record of r.
+type transformReader struct {
+	r         bss.RecordReader
+	transform func(ur *xgen.UserRecord)
+}
+
+func (tr *transformReader) Read() (*xgen.UserRecord, error) {
+	ur, err := tr.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	tr.transform(ur)
+	return ur, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/suppress"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// consentFlags describe ConsentFilter on the command line.
+type consentFlags struct {
+	lists         map[xgen.Domain]string
+	action        string
+	blockDomains  string
+	blockSegments string
+	blockAction   string
+}
+
+func (cf *consentFlags) register(fs *flag.FlagSet) {
+	fs.Func("suppress", "suppression list `domain=file` with one UID per line, an index or a bloom filter, "+
+		"domain is xandr, idfa or aaid, can be repeated for each domain", cf.addList)
+	fs.StringVar(&cf.action, "suppress-action", "remove", "`action` for suppressed users: drop or remove")
+	fs.StringVar(&cf.blockDomains, "block-domains", "", "comma separated `domains` which are not uploaded: xandr, idfa or aaid")
+	fs.StringVar(&cf.blockSegments, "block-segments", "", "comma separated segment `ids` which are not uploaded")
+	fs.StringVar(&cf.blockAction, "block-action", "drop", "`action` for blocked domains and segments: drop or remove")
+}
+
+// filter returns consent filter or nil if no suppression is configured.
+func (cf *consentFlags) filter() (*bss.ConsentFilter, error) {
+	if len(cf.lists) == 0 && cf.blockDomains == "" && cf.blockSegments == "" {
+		return nil, nil
+	}
+
+	f := &bss.ConsentFilter{}
+
+	var err error
+
+	if f.Action, err = parseConsentAction(cf.action); err != nil {
+		return nil, err
+	}
+
+	blockAction, err := parseConsentAction(cf.blockAction)
+	if err != nil {
+		return nil, err
+	}
+
+	for d, file := range cf.lists {
+		list, err := suppress.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		if f.Lists == nil {
+			f.Lists = make(map[xgen.Domain]bss.Suppressor)
+		}
+		f.Lists[d] = list
+	}
+
+	if cf.blockDomains != "" {
+		rule := bss.ConsentRule{Action: blockAction}
+		for _, name := range splitList(cf.blockDomains) {
+			d, err := parseDomain(name)
+			if err != nil {
+				return nil, err
+			}
+			rule.Domains = append(rule.Domains, d)
+		}
+		f.Rules = append(f.Rules, rule)
+	}
+
+	if cf.blockSegments != "" {
+		rule := bss.ConsentRule{Action: blockAction}
+		for _, s := range splitList(cf.blockSegments) {
+			id, err := strconv.ParseInt(s, 10, 32)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid segment id: %s", s)
+			}
+			rule.Segments = append(rule.Segments, xgen.SegmentKey{ID: int32(id)})
+		}
+		f.Rules = append(f.Rules, rule)
+	}
+
+	return f, nil
+}
+
+// addList parses domain=file value of the -suppress flag.
+func (cf *consentFlags) addList(s string) error {
+	name, file, ok := strings.Cut(s, "=")
+	if !ok || file == "" {
+		return fmt.Errorf("invalid suppression list %q, should be domain=file", s)
+	}
+
+	d, err := parseDomain(name)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := cf.lists[d]; ok {
+		return fmt.Errorf("duplicate suppression list for domain %s", name)
+	}
+
+	if cf.lists == nil {
+		cf.lists = make(map[xgen.Domain]string)
+	}
+	cf.lists[d] = file
+
+	return nil
+}
+
+func parseConsentAction(s string) (bss.ConsentAction, error) {
+	switch s {
+	case "drop":
+		return bss.ConsentDrop, nil
+	case "remove":
+		return bss.ConsentRemove, nil
+	}
+	return 0, fmt.Errorf("invalid consent action %q, should be drop or remove", s)
+}
+
+func parseDomain(name string) (xgen.Domain, error) {
+	for _, d := range []xgen.Domain{xgen.XandrID, xgen.IDFA, xgen.AAID} {
+		if d.Name() == name {
+			return d, nil
+		}
+	}
+	return "", fmt.Errorf("unknown domain: %s", name)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func runConvert(args []string) error {
+	var in, out formatFlags
+	var consent consentFlags
+	var expiration expirationFlags
+	var limit limitFlags
+	var normalize normalizeFlags
+	var mf metricsFlags
+
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	in.register(fs, "in-")
+	out.register(fs, "out-")
+	consent.register(fs)
+	expiration.register(fs)
+	limit.register(fs)
+	normalize.register(fs)
+	mf.register(fs)
+	output := fs.String("o", "-", "output `file`")
+	fail := fs.String("fail", "", "comma separated segment `fields` which fail conversion if they cannot be represented")
+	ignore := fs.String("ignore", "", "comma separated segment `fields` which are dropped silently if they cannot be represented")
+	mapping := fs.String("map", "", "segment mapping `file` in CSV or JSON format used by -resolve")
+	resolve := fs.String("resolve", "", "rewrite segments to `id` or code using -map")
+	dropUnknown := fs.Bool("drop-unknown", false, "drop segments not found in -map")
+	sinkURL := fs.String("sink", "", "write parts to a local `directory`, s3://bucket/prefix or sftp://user@host/dir instead of -o")
+	splitUsers := fs.Int("split-users", 0, "maximum number of `users` per part written to -sink")
+	splitBytes := fs.Int64("split-bytes", 0, "approximate maximum `size` of parts written to -sink")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss convert [flags] [file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	inFormat, inParams, err := in.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	outFormat, outParams, err := out.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	opts := &bss.ConvertOptions{
+		Policies: make(map[xgen.SegmentFieldName]bss.LossPolicy),
+		Warn: func(line int, msg string) {
+			fmt.Fprintf(os.Stderr, "warning: line %d: %s\n", line, msg)
+		},
+	}
+
+	for _, f := range splitList(*fail) {
+		opts.Policies[xgen.SegmentFieldName(f)] = bss.LossFail
+	}
+	for _, f := range splitList(*ignore) {
+		opts.Policies[xgen.SegmentFieldName(f)] = bss.LossIgnore
+	}
+
+	var sr *bss.SegmentResolver
+
+	if *resolve != "" {
+		sr, err = loadResolver(*mapping)
+		if err != nil {
+			return err
+		}
+		sr.DropUnknown = *dropUnknown
+
+		switch *resolve {
+		case "id":
+			opts.Transform = sr.ToID
+		case "code":
+			opts.Transform = sr.ToCode
+		default:
+			return fmt.Errorf("-resolve should be id or code")
+		}
+	}
+
+	if _, err := mf.instrument(); err != nil {
+		return err
+	}
+
+	es, err := expiration.stage()
+	if err != nil {
+		return err
+	}
+	if es != nil {
+		opts.Stages = append(opts.Stages, mf.stage("expiration", es))
+	}
+
+	cf, err := consent.filter()
+	if err != nil {
+		return err
+	}
+	if cf != nil {
+		opts.Stages = append(opts.Stages, mf.stage("consent", cf))
+	}
+
+	sl, err := limit.limit()
+	if err != nil {
+		return err
+	}
+	if sl != nil {
+		opts.Stages = append(opts.Stages, mf.stage("limit", sl))
+	}
+
+	fopts, nm, err := normalize.options()
+	if err != nil {
+		return err
+	}
+	fopts = append(fopts, mf.options()...)
+
+	r, err := openInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dr, err := bss.NewSegmentDataReader(r, inFormat, inParams)
+	if err != nil {
+		return err
+	}
+
+	var stats *bss.ConvertStats
+
+	if *sinkURL != "" {
+		split := &bss.SplitOptions{
+			Format:   outFormat,
+			Params:   outParams,
+			MaxUsers: *splitUsers,
+			MaxBytes: *splitBytes,
+			Options:  fopts,
+			OnPart: func(ctx context.Context, p bss.PartInfo) error {
+				fmt.Fprintf(os.Stderr, "stored %s: %d users, %d bytes, sha256 %s\n", p.Name, p.Users, p.Bytes, p.Hash)
+				return nil
+			},
+		}
+		stats, err = convertToSink(dr, opts, split, *sinkURL)
+	} else {
+		stats, err = convertToFile(dr, opts, outFormat, outParams, *output, fopts...)
+	}
+
+	// metrics of failed runs are written too
+	if merr := mf.write(); merr != nil && err == nil {
+		err = merr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "converted %d users, dropped %d, %d warnings\n", stats.Users, stats.Dropped, stats.Warnings)
+
+	if es != nil {
+		es.WriteStats(os.Stderr)
+	}
+
+	if cf != nil {
+		cf.WriteStats(os.Stderr)
+	}
+
+	if sl != nil {
+		sl.WriteStats(os.Stderr)
+	}
+
+	if nm != nil {
+		nm.WriteStats(os.Stderr)
+	}
+
+	if sr != nil {
+		for key, n := range sr.Unknown() {
+			fmt.Fprintf(os.Stderr, "unknown segment %s: %d occurrences\n", key, n)
+		}
+	}
+
+	return nil
+}
+
+func convertToFile(dr *bss.SegmentDataReader, opts *bss.ConvertOptions, format bss.DataFormat, params *xgen.TextEncoderParameters, output string, fopts ...bss.FormatterOption) (*bss.ConvertStats, error) {
+	w, err := createOutput(output)
+	if err != nil {
+		return nil, err
+	}
+
+	df, err := bss.NewSegmentDataFormatter(w, format, params, fopts...)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	stats, err := bss.Convert(dr, df, opts)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	if err := df.Close(); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if h := df.Hash(); h != "" {
+		fmt.Fprintf(os.Stderr, "sha256 %s\n", h)
+	}
+
+	return stats, nil
+}
+
+func convertToSink(dr *bss.SegmentDataReader, opts *bss.ConvertOptions, split *bss.SplitOptions, sinkURL string) (*bss.ConvertStats, error) {
+	ctx := context.Background()
+
+	sink, closer, err := openSink(sinkURL)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	sw, err := bss.NewSplitWriter(ctx, sink, split)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := bss.Convert(dr, sw, opts)
+	if err != nil {
+		sw.Abort()
+		return nil, err
+	}
+
+	if err := sw.Close(); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// loadResolver loads segment mapping from CSV or JSON file depending on the extension.
+func loadResolver(name string) (*bss.SegmentResolver, error) {
+	if name == "" {
+		return nil, fmt.Errorf("mapping file is not specified")
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(name, ".json") {
+		return bss.NewSegmentResolver(&bss.JSONMappings{R: f})
+	}
+
+	return bss.NewSegmentResolver(&bss.CSVMappings{R: f})
+}
+
+// splitList splits comma separated list skipping empty items.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// createOutput creates the named file or returns stdout if name is "-" or empty.
+func createOutput(name string) (io.WriteCloser, error) {
+	if name == "" || name == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(name)
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/milla-v/xandr/bss"
+)
+
+func runDiff(args []string) error {
+	var in, out formatFlags
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	in.register(fs, "in-")
+	out.register(fs, "out-")
+	output := fs.String("o", "-", "output `file` for the delta")
+	sorted := fs.Bool("sorted", false, "inputs are already sorted by UID, skip external sort")
+	chunk := fs.Int("chunk", 0, "number of `records` sorted in memory")
+	tmp := fs.String("tmp", "", "`directory` for temporary sort files")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss diff [flags] previous current")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	inFormat, inParams, err := in.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	outFormat, outParams, err := out.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	var readers [2]bss.RecordReader
+
+	for i, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		dr, err := bss.NewSegmentDataReader(f, inFormat, inParams)
+		if err != nil {
+			return err
+		}
+
+		readers[i] = dr
+
+		if *sorted {
+			continue
+		}
+
+		sr, err := bss.SortRecords(dr, &bss.SortOptions{ChunkSize: *chunk, TempDir: *tmp})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		defer sr.Close()
+
+		readers[i] = sr
+	}
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	df, err := bss.NewSegmentDataFormatter(w, outFormat, outParams)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	stats, err := bss.Diff(readers[0], readers[1], df)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := df.Close(); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "users %d, additions %d, removals %d, updates %d\n",
+		stats.Users, stats.Additions, stats.Removals, stats.Updates)
+
+	return nil
+}
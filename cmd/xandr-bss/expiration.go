@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// expirationFlags describe ExpirationStage on the command line.
+type expirationFlags struct {
+	file string
+}
+
+func (ef *expirationFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&ef.file, "expiration", "", "expiration policy `file` in YAML or JSON format")
+}
+
+// policyDef is an expiration policy in the policy file. TTL accepts days, e.g. 7d.
+type policyDef struct {
+	TTL       string `json:"ttl" yaml:"ttl"`
+	FromEvent bool   `json:"from_event" yaml:"from_event"`
+}
+
+func (pd *policyDef) policy() (bss.ExpirationPolicy, error) {
+	ttl, err := bss.ParseTTL(pd.TTL)
+	if err != nil {
+		return bss.ExpirationPolicy{}, err
+	}
+	return bss.ExpirationPolicy{TTL: ttl, FromEvent: pd.FromEvent}, nil
+}
+
+// segmentDef assigns a policy or a category to a segment.
+type segmentDef struct {
+	ID        int32  `json:"id" yaml:"id"`
+	Code      string `json:"code" yaml:"code"`
+	MemberID  int32  `json:"member_id" yaml:"member_id"`
+	Category  string `json:"category" yaml:"category"`
+	TTL       string `json:"ttl" yaml:"ttl"`
+	FromEvent bool   `json:"from_event" yaml:"from_event"`
+}
+
+// expirationFile is the policy file, e.g.
+//
+//	default: {ttl: 30d}
+//	categories:
+//	  intent: {ttl: 7d, from_event: true}
+//	segments:
+//	  - {id: 100, ttl: 1d}
+//	  - {code: cart, member_id: 1234, category: intent}
+type expirationFile struct {
+	Default    *policyDef           `json:"default" yaml:"default"`
+	Categories map[string]policyDef `json:"categories" yaml:"categories"`
+	Segments   []segmentDef         `json:"segments" yaml:"segments"`
+}
+
+// stage returns expiration stage or nil if no policy file is specified.
+func (ef *expirationFlags) stage() (*bss.ExpirationStage, error) {
+	if ef.file == "" {
+		return nil, nil
+	}
+
+	var f expirationFile
+	if err := decodeFile(ef.file, &f); err != nil {
+		return nil, err
+	}
+
+	// the same time for all users of the file
+	now := time.Now()
+
+	es := &bss.ExpirationStage{
+		Segments:   make(map[xgen.SegmentKey]bss.ExpirationPolicy),
+		Categories: make(map[string]bss.ExpirationPolicy),
+		Category:   make(map[xgen.SegmentKey]string),
+		Now:        func() time.Time { return now },
+		Warn: func(uid string, msg string) {
+			fmt.Fprintf(os.Stderr, "warning: uid %s: %s\n", uid, msg)
+		},
+	}
+
+	if f.Default != nil {
+		p, err := f.Default.policy()
+		if err != nil {
+			return nil, fmt.Errorf("%s: default: %w", ef.file, err)
+		}
+		es.Default = &p
+	}
+
+	for name, pd := range f.Categories {
+		p, err := pd.policy()
+		if err != nil {
+			return nil, fmt.Errorf("%s: category %s: %w", ef.file, name, err)
+		}
+		es.Categories[name] = p
+	}
+
+	for i, sd := range f.Segments {
+		seg := xgen.Segment{ID: sd.ID, Code: sd.Code, MemberID: sd.MemberID}
+		if sd.ID == 0 && (sd.Code == "" || sd.MemberID == 0) {
+			return nil, fmt.Errorf("%s: segments[%d]: id or code and member_id are required", ef.file, i)
+		}
+
+		if sd.Category != "" {
+			if _, ok := es.Categories[sd.Category]; !ok {
+				return nil, fmt.Errorf("%s: segments[%d]: unknown category %s", ef.file, i, sd.Category)
+			}
+			es.Category[seg.Key()] = sd.Category
+		}
+
+		if sd.TTL != "" {
+			pd := policyDef{TTL: sd.TTL, FromEvent: sd.FromEvent}
+			p, err := pd.policy()
+			if err != nil {
+				return nil, fmt.Errorf("%s: segments[%d]: %w", ef.file, i, err)
+			}
+			es.Segments[seg.Key()] = p
+		}
+	}
+
+	return es, nil
+}
+
+// decodeFile decodes YAML (.yaml, .yml) or JSON file rejecting unknown fields.
+func decodeFile(name string, v interface{}) error {
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(buf))
+		dec.KnownFields(true)
+		err = dec.Decode(v)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(v)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
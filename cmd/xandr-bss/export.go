@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/export"
+	"github.com/milla-v/xandr/upload"
+)
+
+func runExport(args []string) error {
+	var in, out formatFlags
+	var af apiFlags
+	var lf ledgerFlags
+	var consent consentFlags
+	var expiration expirationFlags
+	var limit limitFlags
+	var normalize normalizeFlags
+	var mf metricsFlags
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	in.register(fs, "in-")
+	out.register(fs, "out-")
+	af.register(fs)
+	lf.register(fs)
+	consent.register(fs)
+	expiration.register(fs)
+	limit.register(fs)
+	normalize.register(fs)
+	mf.register(fs)
+	state := fs.String("state", "", "state `file` of the export, defaults to the input name with .state suffix")
+	dir := fs.String("dir", ".", "output `directory` of parts")
+	splitUsers := fs.Int("split-users", 0, "maximum number of `users` per part")
+	splitBytes := fs.Int64("split-bytes", 0, "approximate maximum `size` of parts")
+	memberID := fs.Int("member", 0, "upload parts for the member `id`")
+	wait := fs.Bool("wait", false, "wait until every upload job is completed")
+	force := fs.Bool("force", false, "upload parts found in the ledger again")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss export [flags] file")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	inFormat, inParams, err := in.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	outFormat, outParams, err := out.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	job := &export.Job{
+		StateFile: *state,
+		Input:     fs.Arg(0),
+		InFormat:  inFormat,
+		InParams:  inParams,
+		Dir:       *dir,
+		Split: bss.SplitOptions{
+			Format:   outFormat,
+			Params:   outParams,
+			MaxUsers: *splitUsers,
+			MaxBytes: *splitBytes,
+		},
+	}
+
+	instrument, err := mf.instrument()
+	if err != nil {
+		return err
+	}
+
+	es, err := expiration.stage()
+	if err != nil {
+		return err
+	}
+	if es != nil {
+		job.Stages = append(job.Stages, mf.stage("expiration", es))
+	}
+
+	cf, err := consent.filter()
+	if err != nil {
+		return err
+	}
+	if cf != nil {
+		job.Stages = append(job.Stages, mf.stage("consent", cf))
+	}
+
+	sl, err := limit.limit()
+	if err != nil {
+		return err
+	}
+	if sl != nil {
+		job.Stages = append(job.Stages, mf.stage("limit", sl))
+	}
+
+	fopts, nm, err := normalize.options()
+	if err != nil {
+		return err
+	}
+	job.Split.Options = append(fopts, mf.options()...)
+
+	if job.StateFile == "" {
+		job.StateFile = job.Input + ".state"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *memberID != 0 {
+		s, err := af.login(ctx)
+		if err != nil {
+			return err
+		}
+		ledger, err := lf.open()
+		if err != nil {
+			return err
+		}
+		defer ledger.Close()
+
+		client := upload.NewClient(s)
+		client.Instrument = instrument
+
+		job.Uploader = &upload.Uploader{
+			Client:   client,
+			MemberID: int32(*memberID),
+			Wait:     *wait,
+			Ledger:   ledger,
+			Force:    *force,
+		}
+	}
+
+	st, err := export.Run(ctx, job)
+	if merr := mf.write(); merr != nil && err == nil {
+		err = merr
+	}
+	if es != nil {
+		es.WriteStats(os.Stderr)
+	}
+	if cf != nil {
+		cf.WriteStats(os.Stderr)
+	}
+	if sl != nil {
+		sl.WriteStats(os.Stderr)
+	}
+	if nm != nil {
+		nm.WriteStats(os.Stderr)
+	}
+	if st != nil {
+		uploaded := len(st.Parts) - len(st.Pending())
+		fmt.Fprintf(os.Stderr, "parts %d, uploaded %d, done %v\n", len(st.Parts), uploaded, st.Done)
+	}
+	if err != nil {
+		return fmt.Errorf("%w (run again to resume)", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/synth"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func runGenerate(args []string) error {
+	var out formatFlags
+	var cfg synth.Config
+
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	out.register(fs, "out-")
+	output := fs.String("o", "-", "output `file`")
+	fs.IntVar(&cfg.Users, "users", 1000, "`number` of users")
+	fs.Int64Var(&cfg.Seed, "seed", 1, "random `seed`, the same seed and flags generate the same file")
+	domains := fs.String("domains", "xandr=1", "comma separated domain `proportions`, e.g. xandr=0.6,idfa=0.2,aaid=0.2")
+	fs.IntVar(&cfg.Catalog, "catalog", 1000, "`number` of distinct segments")
+	firstID := fs.Int("first-id", 100, "`id` of the first segment")
+	fs.IntVar(&cfg.MaxSegments, "max-segments", 20, "maximum `number` of segments per user")
+	fs.Float64Var(&cfg.CountZipf, "count-zipf", 1.5, "zipf `exponent` of the number of segments per user")
+	fs.Float64Var(&cfg.PopularityZipf, "popularity-zipf", 0, "zipf `exponent` of segment popularity, 0 is uniform")
+	fs.Float64Var(&cfg.RemovalRatio, "removals", 0, "`share` of memberships written as removals")
+	fs.Float64Var(&cfg.CodeRatio, "codes", 0, "`share` of segments referenced by code, requires avro or a format with codes")
+	memberID := fs.Int("code-member", 1, "member `id` of segment codes")
+	minExp := fs.String("min-expiration", "", "minimum expiration `ttl`, e.g. 1d")
+	maxExp := fs.String("max-expiration", "", "maximum expiration `ttl`, default expiration if not set")
+	maxValue := fs.Int("max-value", 0, "maximum segment `value`")
+	spread := fs.String("timestamps", "", "set timestamps within the `ttl` before now")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss generate [flags]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if cfg.Users <= 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	format, params, err := out.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	cfg.FirstID = int32(*firstID)
+	cfg.MemberID = int32(*memberID)
+	cfg.MaxValue = int32(*maxValue)
+
+	cfg.Domains = make(map[xgen.Domain]float64)
+	for _, item := range splitList(*domains) {
+		name, value, _ := strings.Cut(item, "=")
+		d, err := parseDomain(name)
+		if err != nil {
+			return err
+		}
+		if cfg.Domains[d], err = strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("invalid proportion of %s: %s", name, value)
+		}
+	}
+
+	for _, d := range []struct {
+		s   string
+		dst *time.Duration
+	}{{*minExp, &cfg.MinExpiration}, {*maxExp, &cfg.MaxExpiration}, {*spread, &cfg.TimestampSpread}} {
+		if d.s == "" {
+			continue
+		}
+		if *d.dst, err = bss.ParseTTL(d.s); err != nil {
+			return err
+		}
+	}
+
+	if *spread != "" {
+		cfg.Now = time.Now()
+	}
+
+	g, err := synth.New(cfg)
+	if err != nil {
+		return err
+	}
+
+	w, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+
+	df, err := bss.NewSegmentDataFormatter(w, format, params)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	start := time.Now()
+
+	n, err := g.Write(df, 0)
+	if err == nil {
+		err = df.Close()
+	}
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "generated %d users in %s\n", n, time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/milla-v/xandr/bss"
+)
+
+func runInspect(args []string) error {
+	var ff formatFlags
+
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	ff.register(fs, "")
+	top := fs.Int("top", 20, "number of top segments to show, 0 shows all")
+	asJSON := fs.Bool("json", false, "print report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss inspect [flags] [file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	format, params, err := ff.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	f, err := openInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dr, err := bss.NewSegmentDataReader(f, format, params)
+	if err != nil {
+		return err
+	}
+
+	rep, err := bss.Inspect(dr, *top)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return rep.WriteJSON(os.Stdout)
+	}
+
+	return rep.WriteTable(os.Stdout)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// limitFlags describe SegmentLimit on the command line.
+type limitFlags struct {
+	max      int
+	priority string
+	rank     string
+	verbose  bool
+}
+
+func (lf *limitFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&lf.max, "max-segments", 0, "maximum `number` of added segments per user, 0 is unlimited")
+	fs.StringVar(&lf.priority, "priority", "rank", "`priority` of segments kept by -max-segments: rank, value or timestamp")
+	fs.StringVar(&lf.rank, "rank", "", "comma separated segment `ids` from the highest rank used by -priority rank")
+	fs.BoolVar(&lf.verbose, "show-trimmed", false, "print users with trimmed segments")
+}
+
+// limit returns segment limit or nil if the number of segments is unlimited.
+func (lf *limitFlags) limit() (*bss.SegmentLimit, error) {
+	if lf.max <= 0 {
+		return nil, nil
+	}
+
+	sl := &bss.SegmentLimit{Max: lf.max, Rank: make(map[xgen.SegmentKey]int)}
+
+	switch lf.priority {
+	case "rank":
+		sl.Priority = bss.PriorityRank
+	case "value":
+		sl.Priority = bss.PriorityValue
+	case "timestamp":
+		sl.Priority = bss.PriorityTimestamp
+	default:
+		return nil, fmt.Errorf("invalid priority %q, should be rank, value or timestamp", lf.priority)
+	}
+
+	for i, s := range splitList(lf.rank) {
+		id, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid segment id: %s", s)
+		}
+		sl.Rank[xgen.SegmentKey{ID: int32(id)}] = i + 1
+	}
+
+	if lf.verbose {
+		sl.OnTrim = func(uid string, trimmed []xgen.Segment) {
+			fmt.Fprintf(os.Stderr, "trimmed: uid %s:", uid)
+			for i := range trimmed {
+				fmt.Fprintf(os.Stderr, " %s", trimmed[i].Key())
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+
+	return sl, nil
+}
@@ -0,0 +1,77 @@
+// Command xandr-bss provides tools for preparing Xandr batch segment (BSS) files.
+//
+// Usage:
+//
+//	xandr-bss <command> [flags] [arguments]
+//
+// Commands:
+//
+//	validate    check a BSS file before upload
+//	convert     convert a BSS file between text formats and avro
+//	inspect     show statistics of a BSS file
+//	diff        write delta between two BSS snapshots
+//	segments    reconcile segments and taxonomy with a YAML or JSON definition file
+//	audit       compare an uploaded BSS file with the segment feed
+//	verify      compare uploaded users with the segment load report
+//	export      split a BSS file into parts and upload them, resuming interrupted exports
+//	upload      upload BSS files skipping content uploaded before
+//	history     show the upload ledger
+//	suppress    build and check suppression indexes and bloom filters
+//	generate    write synthetic BSS data for load tests
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"validate", "check a BSS file before upload", runValidate},
+	{"convert", "convert a BSS file between text formats and avro", runConvert},
+	{"inspect", "show statistics of a BSS file", runInspect},
+	{"diff", "write delta between two BSS snapshots", runDiff},
+	{"segments", "reconcile segments and taxonomy with a YAML or JSON definition file", runSegments},
+	{"audit", "compare an uploaded BSS file with the segment feed", runAudit},
+	{"verify", "compare uploaded users with the segment load report", runVerify},
+	{"export", "split a BSS file into parts and upload them, resuming interrupted exports", runExport},
+	{"upload", "upload BSS files skipping content uploaded before", runUpload},
+	{"history", "show the upload ledger", runHistory},
+	{"suppress", "build and check suppression indexes and bloom filters", runSuppress},
+	{"generate", "write synthetic BSS data for load tests", runGenerate},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: xandr-bss <command> [flags] [arguments]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+		if err := c.run(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "xandr-bss "+c.name+":", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "unknown command:", os.Args[1])
+	usage()
+	os.Exit(2)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/metrics"
+)
+
+// metricsFlags describe instrumentation on the command line.
+type metricsFlags struct {
+	file     string
+	logLevel string
+	logJSON  bool
+
+	registry *metrics.Registry
+	in       metrics.Instrument
+}
+
+func (mf *metricsFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&mf.file, "metrics", "", "write metrics in Prometheus text format to the `file` on exit, e.g. for a textfile collector")
+	fs.StringVar(&mf.logLevel, "log-level", "", "log pipeline events to stderr at the `level`: debug, info, warn or error")
+	fs.BoolVar(&mf.logJSON, "log-json", false, "log events in JSON instead of text")
+}
+
+// instrument returns instrument or nil if neither metrics nor logging is requested.
+func (mf *metricsFlags) instrument() (metrics.Instrument, error) {
+	if mf.file != "" {
+		mf.registry = metrics.NewRegistry()
+	}
+
+	var logger *metrics.Logger
+
+	if mf.logLevel != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(mf.logLevel)); err != nil {
+			return nil, fmt.Errorf("invalid -log-level %q, should be debug, info, warn or error", mf.logLevel)
+		}
+
+		opts := &slog.HandlerOptions{Level: level}
+
+		var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+		if mf.logJSON {
+			h = slog.NewJSONHandler(os.Stderr, opts)
+		}
+
+		logger = metrics.NewLogger(slog.New(h))
+	}
+
+	// nil pointers are passed as nil interfaces to be skipped by Multi
+	var list []metrics.Instrument
+	if mf.registry != nil {
+		list = append(list, mf.registry)
+	}
+	if logger != nil {
+		list = append(list, logger)
+	}
+
+	mf.in = metrics.Multi(list...)
+
+	return mf.in, nil
+}
+
+// stage returns the stage reporting users it processes and drops.
+func (mf *metricsFlags) stage(name string, s bss.Stage) bss.Stage {
+	return bss.Instrumented(name, s, mf.in)
+}
+
+// options returns formatter options reporting written data.
+func (mf *metricsFlags) options() []bss.FormatterOption {
+	if mf.in == nil {
+		return nil
+	}
+	return []bss.FormatterOption{bss.WithInstrument(mf.in)}
+}
+
+// write replaces the metrics file, so collectors never read a partial file.
+func (mf *metricsFlags) write() error {
+	if mf.registry == nil {
+		return nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(mf.file), filepath.Base(mf.file)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	// temporary files are private, collectors may run as another user
+	err = f.Chmod(0o644)
+	if err == nil {
+		err = mf.registry.WritePrometheus(f)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), mf.file)
+}
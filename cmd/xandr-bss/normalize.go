@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/milla-v/xandr/bss"
+)
+
+// normalizeFlags describe Normalizer on the command line.
+type normalizeFlags struct {
+	dedup     string
+	sort      bool
+	canonical bool
+	chunk     int
+	tmp       string
+}
+
+func (nf *normalizeFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&nf.dedup, "dedup", "", "merge duplicate segments of a user keeping max-expiration, latest or max-value `membership`")
+	fs.BoolVar(&nf.sort, "sort-segments", false, "sort segments of every user, implies -dedup max-expiration if -dedup is not set")
+	fs.BoolVar(&nf.canonical, "canonical", false, "write byte-identical output for the same users sorting them by UID")
+	fs.IntVar(&nf.chunk, "chunk", 0, "number of `users` sorted in memory by -canonical")
+	fs.StringVar(&nf.tmp, "tmp", "", "`directory` for temporary sort files of -canonical")
+}
+
+// options returns formatter options and the normalizer if it is requested.
+func (nf *normalizeFlags) options() ([]bss.FormatterOption, *bss.Normalizer, error) {
+	var opts []bss.FormatterOption
+
+	n, err := nf.normalizer()
+	if err != nil {
+		return nil, nil, err
+	}
+	if n != nil {
+		opts = append(opts, bss.WithNormalizer(n))
+	}
+
+	if nf.canonical {
+		opts = append(opts, bss.WithCanonical(), bss.WithSortOptions(&bss.SortOptions{ChunkSize: nf.chunk, TempDir: nf.tmp}))
+	}
+
+	return opts, n, nil
+}
+
+// normalizer returns normalizer or nil if normalization is not requested.
+func (nf *normalizeFlags) normalizer() (*bss.Normalizer, error) {
+	if nf.dedup == "" && !nf.sort {
+		return nil, nil
+	}
+
+	n := &bss.Normalizer{Sort: nf.sort}
+
+	switch nf.dedup {
+	case "", "max-expiration":
+		n.Merge = bss.MergeMaxExpiration
+	case "latest":
+		n.Merge = bss.MergeLatestTimestamp
+	case "max-value":
+		n.Merge = bss.MergeMaxValue
+	default:
+		return nil, fmt.Errorf("invalid -dedup %q, should be max-expiration, latest or max-value", nf.dedup)
+	}
+
+	return n, nil
+}
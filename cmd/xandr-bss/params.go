@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+var presets = map[string]xgen.TextEncoderParameters{
+	"minimal":       xgen.MinimalFormat,
+	"full":          xgen.FullFormat,
+	"full-external": xgen.FullExternalFormat,
+}
+
+// formatFlags describe a BSS file format on the command line.
+type formatFlags struct {
+	format string
+	preset string
+	params string
+}
+
+func (ff *formatFlags) register(fs *flag.FlagSet, prefix string) {
+	fs.StringVar(&ff.format, prefix+"format", "text", "file `format`: text or avro")
+	fs.StringVar(&ff.preset, prefix+"preset", "minimal", "text format `preset`: minimal, full or full-external")
+	fs.StringVar(&ff.params, prefix+"params", "", "JSON `file` with TextEncoderParameters, overrides preset")
+}
+
+// dataFormat returns data format and text parameters. Parameters are nil for avro format.
+func (ff *formatFlags) dataFormat() (bss.DataFormat, *xgen.TextEncoderParameters, error) {
+	switch bss.DataFormat(ff.format) {
+	case bss.FormatAvro:
+		return bss.FormatAvro, nil, nil
+	case bss.FormatText:
+	default:
+		return "", nil, fmt.Errorf("unsupported format: %s", ff.format)
+	}
+
+	if ff.params != "" {
+		buf, err := os.ReadFile(ff.params)
+		if err != nil {
+			return "", nil, err
+		}
+		var p xgen.TextEncoderParameters
+		if err := json.Unmarshal(buf, &p); err != nil {
+			return "", nil, fmt.Errorf("%s: %w", ff.params, err)
+		}
+		return bss.FormatText, &p, nil
+	}
+
+	p, ok := presets[ff.preset]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown preset: %s", ff.preset)
+	}
+
+	return bss.FormatText, &p, nil
+}
+
+// openInput opens the named file or stdin if name is "-" or empty.
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "" || name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/milla-v/xandr/segment"
+)
+
+func runSegments(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "sync":
+			return runSegmentsSync(args[1:])
+		case "taxonomy":
+			return runSegmentsTaxonomy(args[1:])
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "usage: xandr-bss segments sync|taxonomy [flags] file")
+	os.Exit(2)
+	return nil
+}
+
+func runSegmentsSync(args []string) error {
+	var af apiFlags
+
+	fs := flag.NewFlagSet("segments sync", flag.ExitOnError)
+	af.register(fs)
+	apply := fs.Bool("apply", false, "apply the plan after confirmation")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss segments sync [flags] file.yaml|file.json")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	desired, err := segment.LoadDesired(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	s, err := af.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	c := segment.NewClient(s)
+
+	plan, err := c.Sync(ctx, desired)
+	if err != nil {
+		return err
+	}
+
+	if err := plan.WriteText(os.Stdout); err != nil {
+		return err
+	}
+
+	if !*apply || plan.Empty() {
+		return nil
+	}
+
+	if !*yes && !confirm("apply this plan?") {
+		fmt.Println("plan is not applied")
+		return nil
+	}
+
+	if err := c.Apply(ctx, plan); err != nil {
+		return err
+	}
+
+	fmt.Println("plan is applied")
+
+	return nil
+}
+
+func runSegmentsTaxonomy(args []string) error {
+	var af apiFlags
+
+	fs := flag.NewFlagSet("segments taxonomy", flag.ExitOnError)
+	af.register(fs)
+	apply := fs.Bool("apply", false, "apply the plan after confirmation")
+	yes := fs.Bool("yes", false, "do not ask for confirmation")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss segments taxonomy [flags] file.yaml|file.json")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	tax, err := segment.LoadTaxonomy(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if err := tax.Validate(); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	s, err := af.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	c := segment.NewClient(s)
+
+	plan, err := c.PlanTaxonomy(ctx, tax)
+	if err != nil {
+		return err
+	}
+
+	if err := plan.WriteText(os.Stdout); err != nil {
+		return err
+	}
+
+	if !*apply || plan.Empty() {
+		return nil
+	}
+
+	if !*yes && !confirm("apply this plan?") {
+		fmt.Println("plan is not applied")
+		return nil
+	}
+
+	if err := c.ApplyTaxonomy(ctx, tax, plan); err != nil {
+		return err
+	}
+
+	fmt.Println("plan is applied")
+
+	return nil
+}
+
+// confirm asks a yes/no question on stdin.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/milla-v/xandr/api"
+)
+
+// apiFlags describe API connection. Credentials are taken from XANDR_USERNAME and XANDR_PASSWORD.
+type apiFlags struct {
+	url string
+}
+
+func (af *apiFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&af.url, "api", api.DefaultBaseURL, "API base `url`")
+}
+
+// login creates authenticated API session.
+func (af *apiFlags) login(ctx context.Context) (*api.Session, error) {
+	username := os.Getenv("XANDR_USERNAME")
+	password := os.Getenv("XANDR_PASSWORD")
+
+	if username == "" || password == "" {
+		return nil, errors.New("XANDR_USERNAME and XANDR_PASSWORD should be set")
+	}
+
+	s := api.NewSession(af.url)
+	if err := s.Login(ctx, username, password); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
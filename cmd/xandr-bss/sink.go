@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/s3sink"
+	"github.com/milla-v/xandr/bss/sftpsink"
+)
+
+// openSink opens sink described by a local directory, s3://bucket/prefix or sftp://user@host[:port]/dir.
+//
+// S3 credentials are taken from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, region from AWS_REGION
+// and custom endpoint from AWS_ENDPOINT_URL. SFTP password is taken from SFTP_PASSWORD or private key
+// from SFTP_KEY_FILE, host keys are verified with ~/.ssh/known_hosts.
+func openSink(dest string) (bss.Sink, io.Closer, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme == "" {
+		return &bss.DirSink{Dir: dest}, nopWriteCloser{}, nil
+	}
+
+	switch u.Scheme {
+	case "s3":
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+
+		endpoint := os.Getenv("AWS_ENDPOINT_URL")
+		if endpoint == "" {
+			endpoint = "https://s3." + region + ".amazonaws.com"
+		}
+
+		prefix := strings.TrimPrefix(u.Path, "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+
+		sink, err := s3sink.New(s3sink.Config{
+			Endpoint:     endpoint,
+			Region:       region,
+			Bucket:       u.Host,
+			Prefix:       prefix,
+			AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return sink, nopWriteCloser{}, nil
+
+	case "sftp":
+		config, err := sshConfig(u.User.Username())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		addr := u.Host
+		if u.Port() == "" {
+			addr = net.JoinHostPort(u.Hostname(), "22")
+		}
+
+		sink, err := sftpsink.Dial(addr, config, u.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return sink, sink, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported sink: %s", dest)
+}
+
+func sshConfig(user string) (*ssh.ClientConfig, error) {
+	if user == "" {
+		return nil, errors.New("sftp user is not specified")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ClientConfig{User: user, HostKeyCallback: hostKeys}
+
+	if keyFile := os.Getenv("SFTP_KEY_FILE"); keyFile != "" {
+		pem, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", keyFile, err)
+		}
+
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	}
+
+	if password := os.Getenv("SFTP_PASSWORD"); password != "" {
+		config.Auth = append(config.Auth, ssh.Password(password))
+	}
+
+	if len(config.Auth) == 0 {
+		return nil, errors.New("SFTP_KEY_FILE or SFTP_PASSWORD should be set")
+	}
+
+	return config, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/milla-v/xandr/bss/suppress"
+)
+
+func runSuppress(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "build":
+			return runSuppressBuild(args[1:])
+		case "check":
+			return runSuppressCheck(args[1:])
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "usage: xandr-bss suppress build|check [flags] file...")
+	os.Exit(2)
+	return nil
+}
+
+func runSuppressBuild(args []string) error {
+	fs := flag.NewFlagSet("suppress build", flag.ExitOnError)
+	typ := fs.String("type", "index", "output `type`: index or bloom")
+	fpRate := fs.Float64("fp", 0.001, "false positive `rate` of the bloom filter")
+	shards := fs.Int("shards", 64, "`number` of bloom filter shards, a power of two")
+	out := fs.String("o", "", "output `file`")
+	run := fs.Int("run", 0, "number of `keys` sorted in memory")
+	tmp := fs.String("tmp", "", "`directory` for temporary sort files")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss suppress build [flags] -o output list.csv...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || *out == "" || (*typ != "index" && *typ != "bloom") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	b := &suppress.Builder{RunSize: *run, TempDir: *tmp}
+	defer b.Close()
+
+	for _, name := range fs.Args() {
+		if err := readList(name, b.Add); err != nil {
+			return err
+		}
+	}
+
+	var wt io.WriterTo
+
+	switch *typ {
+	case "index":
+		wt = writerToFunc(b.WriteIndex)
+	case "bloom":
+		sb, err := b.Bloom(*fpRate, *shards)
+		if err != nil {
+			return err
+		}
+		wt = sb
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+
+	if _, err := wt.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d unique user ids\n", *out, b.Len())
+
+	return nil
+}
+
+func readList(name string, add func(uid string)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := suppress.ReadUIDs(bufio.NewReaderSize(f, 1024*1024), add); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+// writerToFunc adapts a write function to io.WriterTo.
+type writerToFunc func(w io.Writer) error
+
+func (fn writerToFunc) WriteTo(w io.Writer) (int64, error) {
+	return 0, fn(w)
+}
+
+func runSuppressCheck(args []string) error {
+	fs := flag.NewFlagSet("suppress check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss suppress check list uid...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	list, err := suppress.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if c, ok := list.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	for _, uid := range fs.Args()[1:] {
+		fmt.Printf("%s\t%t\n", uid, list.Contains(uid))
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/milla-v/xandr/upload"
+)
+
+// ledgerFlags describe the upload ledger file.
+type ledgerFlags struct {
+	name string
+}
+
+func (lf *ledgerFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&lf.name, "ledger", "", "upload ledger `file`, defaults to xandr-bss/ledger.jsonl in the user config directory")
+}
+
+// open opens the ledger creating its directory if needed.
+func (lf *ledgerFlags) open() (*upload.Ledger, error) {
+	name := lf.name
+	if name == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+
+		name = filepath.Join(dir, "xandr-bss", "ledger.jsonl")
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	return upload.OpenLedger(name)
+}
+
+func runUpload(args []string) error {
+	var af apiFlags
+	var lf ledgerFlags
+	var mf metricsFlags
+
+	fs := flag.NewFlagSet("upload", flag.ExitOnError)
+	af.register(fs)
+	lf.register(fs)
+	mf.register(fs)
+	memberID := fs.Int("member", 0, "member `id`")
+	force := fs.Bool("force", false, "upload content found in the ledger again")
+	wait := fs.Bool("wait", false, "wait until every upload job is completed")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss upload [flags] file...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || *memberID == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	instrument, err := mf.instrument()
+	if err != nil {
+		return err
+	}
+
+	ledger, err := lf.open()
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+
+	ctx := context.Background()
+
+	s, err := af.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	client := upload.NewClient(s)
+	client.Instrument = instrument
+
+	u := &upload.Uploader{
+		Client:   client,
+		MemberID: int32(*memberID),
+		Wait:     *wait,
+		Ledger:   ledger,
+		Force:    *force,
+	}
+
+	var failed int
+
+	for _, name := range fs.Args() {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+
+		jobID, err := u.Upload(ctx, filepath.Base(name), f)
+		f.Close()
+
+		switch {
+		case errors.Is(err, upload.ErrAlreadyUploaded):
+			fmt.Fprintf(os.Stderr, "skipped %v, use -force to upload it again\n", err)
+			failed++
+		case err != nil:
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+		default:
+			fmt.Fprintf(os.Stderr, "uploaded %s: job %s\n", name, jobID)
+		}
+	}
+
+	if err := mf.write(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files are not uploaded", failed, fs.NArg())
+	}
+
+	return nil
+}
+
+func runHistory(args []string) error {
+	var lf ledgerFlags
+
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	lf.register(fs)
+	memberID := fs.Int("member", 0, "show uploads of the member `id` only")
+	fs.Parse(args)
+
+	ledger, err := lf.open()
+	if err != nil {
+		return err
+	}
+	defer ledger.Close()
+
+	var entries []upload.LedgerEntry
+	for _, e := range ledger.History() {
+		if *memberID == 0 || e.MemberID == int32(*memberID) {
+			entries = append(entries, e)
+		}
+	}
+
+	return upload.WriteHistory(os.Stdout, entries)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/milla-v/xandr/bss"
+)
+
+func runValidate(args []string) error {
+	var ff formatFlags
+
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	ff.register(fs, "")
+	maxIssues := fs.Int("max-issues", 100, "maximum number of issues and warnings to print, 0 prints all")
+	strictUUID := fs.Bool("strict-uuid", false, "report device IDs which are not UUIDs as issues instead of warnings")
+	duplicates := fs.Bool("duplicates", false, "report duplicate users, memory grows with the number of users")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss validate [flags] [file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	format, params, err := ff.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	f, err := openInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dr, err := bss.NewSegmentDataReader(f, format, params)
+	if err != nil {
+		return err
+	}
+
+	vr, err := bss.Validate(dr, &bss.ValidationOptions{StrictUUID: *strictUUID, Duplicates: *duplicates})
+	if err != nil {
+		return err
+	}
+
+	printIssues("issues", vr.Issues, *maxIssues)
+	printIssues("warnings", vr.Warnings, *maxIssues)
+
+	if err := vr.WriteSummary(os.Stdout); err != nil {
+		return err
+	}
+
+	if len(vr.Issues) > 0 {
+		return errors.New("validation failed")
+	}
+
+	return nil
+}
+
+func printIssues(kind string, list []bss.ValidationIssue, max int) {
+	for i, issue := range list {
+		if max > 0 && i >= max {
+			fmt.Printf("... %d more %s\n", len(list)-i, kind)
+			break
+		}
+		fmt.Println(issue)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/report"
+)
+
+func runVerify(args []string) error {
+	var in formatFlags
+	var af apiFlags
+
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	in.register(fs, "")
+	af.register(fs)
+	memberID := fs.Int("member", 0, "member `id`")
+	interval := fs.String("interval", string(report.IntervalYesterday), "report `interval` covering the upload, users loaded during the interval are compared, e.g. yesterday or last_7_days")
+	threshold := fs.Float64("threshold", 0.1, "allowed relative `difference` of uploaded and loaded users")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: xandr-bss verify [flags] file")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *memberID == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	format, params, err := in.dataFormat()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dr, err := bss.NewSegmentDataReader(f, format, params)
+	if err != nil {
+		return err
+	}
+
+	uploaded, err := report.UploadedUsers(dr)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	ids := make([]int32, 0, len(uploaded))
+	for id := range uploaded {
+		ids = append(ids, id)
+	}
+
+	ctx := context.Background()
+
+	s, err := af.login(ctx)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := report.NewClient(s).SegmentTotalLoads(ctx, int32(*memberID), report.Interval(*interval), ids...)
+	if err != nil {
+		return err
+	}
+
+	list := report.Compare(uploaded, loaded, *threshold)
+	if len(list) == 0 {
+		fmt.Fprintf(os.Stderr, "%d segments match within %.1f%%\n", len(uploaded), *threshold*100)
+		return nil
+	}
+
+	if err := report.WriteDiscrepancies(os.Stdout, list); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%d of %d segments differ by more than %.1f%%", len(list), len(uploaded), *threshold*100)
+}
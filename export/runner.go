@@ -0,0 +1,292 @@
+// Package export runs resumable BSS exports. Progress is checkpointed to a state file after every
+// committed part, so a restarted export skips exported parts and uploads only parts which are not uploaded yet.
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/upload"
+)
+
+const defaultBatchSize = 1000
+
+// Uploader uploads a part and returns the upload job ID. Parts failed with upload.ErrAlreadyUploaded
+// are considered uploaded by the returned job.
+type Uploader interface {
+	Upload(ctx context.Context, name string, r io.Reader) (jobID string, err error)
+}
+
+// Job describes an export. Running the same job with the same state file resumes it.
+type Job struct {
+	StateFile string
+	Input     string
+	InFormat  bss.DataFormat
+	InParams  *xgen.TextEncoderParameters
+	Dir       string           // directory of parts
+	Split     bss.SplitOptions // output format and part limits, Name and OnPart are set by Run
+	BatchSize int              // number of users per Append call
+
+	Transform func(ur *xgen.UserRecord) // called for every record before encoding
+	Stages    bss.Pipeline              // run after Transform, users dropped by a stage are not written
+	Uploader  Uploader                  // nil disables uploading
+}
+
+// description identifies the job in the state file.
+type description struct {
+	Input     string                      `json:"input"`
+	Size      int64                       `json:"size"`
+	ModTime   time.Time                   `json:"mod_time"`
+	InFormat  bss.DataFormat              `json:"in_format"`
+	InParams  *xgen.TextEncoderParameters `json:"in_params,omitempty"`
+	OutFormat bss.DataFormat              `json:"out_format"`
+	OutParams *xgen.TextEncoderParameters `json:"out_params,omitempty"`
+	MaxUsers  int                         `json:"max_users"`
+	MaxBytes  int64                       `json:"max_bytes"`
+}
+
+func (job *Job) describe() (json.RawMessage, error) {
+	input, err := filepath.Abs(job.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(description{
+		Input:     input,
+		Size:      fi.Size(),
+		ModTime:   fi.ModTime().UTC(),
+		InFormat:  job.InFormat,
+		InParams:  job.InParams,
+		OutFormat: job.Split.Format,
+		OutParams: job.Split.Params,
+		MaxUsers:  job.Split.MaxUsers,
+		MaxBytes:  job.Split.MaxBytes,
+	})
+}
+
+// Run exports the input into parts and uploads them. If the state file has progress of the job,
+// exported parts are skipped and only parts which are not uploaded yet are uploaded.
+// Run returns the state even if it fails.
+func Run(ctx context.Context, job *Job) (*State, error) {
+	desc, err := job.describe()
+	if err != nil {
+		return nil, err
+	}
+
+	sf, err := openState(job.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	defer sf.Close()
+
+	if sf.state.Job == nil {
+		if err := sf.append(&entry{Type: "start", Job: desc}); err != nil {
+			return nil, err
+		}
+	} else if !bytes.Equal(sf.state.Job, desc) {
+		return nil, fmt.Errorf("state file %s belongs to another export or the input has changed", job.StateFile)
+	}
+
+	if !sf.state.Done {
+		if err := job.export(ctx, sf); err != nil {
+			return &sf.state, err
+		}
+	}
+
+	if job.Uploader != nil {
+		if err := job.upload(ctx, sf); err != nil {
+			return &sf.state, err
+		}
+	}
+
+	return &sf.state, nil
+}
+
+func (job *Job) export(ctx context.Context, sf *stateFile) error {
+	f, err := os.Open(job.Input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cp := sf.state.Checkpoint()
+
+	if job.InFormat == bss.FormatText {
+		if _, err := f.Seek(cp.Offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	dr, err := bss.NewSegmentDataReader(f, job.InFormat, job.InParams)
+	if err != nil {
+		return err
+	}
+
+	// line numbers of errors continue from the checkpoint
+	if job.InFormat == bss.FormatText {
+		dr.SetLine(int(cp.Lines))
+	}
+
+	records := cp.Records
+
+	// avro input cannot be positioned, consumed records are skipped
+	if job.InFormat == bss.FormatAvro {
+		for i := int64(0); i < records; i++ {
+			if _, err := dr.Read(); err != nil {
+				return fmt.Errorf("skip exported records: %w", err)
+			}
+		}
+	}
+
+	// checkpoints after every appended user which is not committed yet
+	var pending []Checkpoint
+
+	base := len(sf.state.Parts)
+	ext := "txt"
+	if job.Split.Format == bss.FormatAvro {
+		ext = "avro"
+	}
+
+	opts := job.Split
+	opts.Name = func(index int) string { return fmt.Sprintf("part-%05d.%s", base+index, ext) }
+	opts.OnPart = func(ctx context.Context, p bss.PartInfo) error {
+		part := &PartState{
+			Index:      base + p.Index,
+			Name:       p.Name,
+			Users:      p.Users,
+			Bytes:      p.Bytes,
+			Checkpoint: pending[p.Users-1],
+		}
+		pending = pending[p.Users:]
+		return sf.append(&entry{Type: "part", Part: part})
+	}
+
+	sw, err := bss.NewSplitWriter(ctx, &bss.DirSink{Dir: job.Dir}, &opts)
+	if err != nil {
+		return err
+	}
+
+	batchSize := job.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	batch := make([]*xgen.UserRecord, 0, batchSize)
+
+	flush := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := sw.Append(batch)
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		user, err := dr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			sw.Abort()
+			return err
+		}
+
+		records++
+
+		if job.Transform != nil {
+			job.Transform(user)
+		}
+
+		user, err = job.Stages.Process(user)
+		if err != nil {
+			sw.Abort()
+			return fmt.Errorf("record %d: %w", records, err)
+		}
+		if user == nil {
+			continue
+		}
+
+		batch = append(batch, user)
+		pending = append(pending, Checkpoint{Offset: cp.Offset + dr.Offset(), Lines: int64(dr.Line()), Records: records})
+
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				sw.Abort()
+				return err
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			sw.Abort()
+			return err
+		}
+	}
+
+	if err := sw.Close(); err != nil {
+		return err
+	}
+
+	return sf.append(&entry{Type: "done"})
+}
+
+func (job *Job) upload(ctx context.Context, sf *stateFile) error {
+	var errs []error
+
+	pending := sf.state.Pending()
+
+	for _, part := range pending {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		jobID, err := job.uploadPart(ctx, part.Name)
+
+		// a previous run could upload the part and crash before recording it
+		if errors.Is(err, upload.ErrAlreadyUploaded) {
+			err = nil
+		}
+
+		e := &entry{Type: "upload", Name: part.Name, JobID: jobID}
+		if err != nil {
+			e.Error = err.Error()
+			errs = append(errs, err)
+		}
+
+		if err := sf.append(e); err != nil {
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d parts are not uploaded: %w", len(errs), len(pending), errors.Join(errs...))
+	}
+
+	return nil
+}
+
+func (job *Job) uploadPart(ctx context.Context, name string) (string, error) {
+	f, err := os.Open(filepath.Join(job.Dir, name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return job.Uploader.Upload(ctx, name, f)
+}
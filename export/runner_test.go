@@ -0,0 +1,173 @@
+package export_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/export"
+	"github.com/milla-v/xandr/upload"
+	"github.com/milla-v/xandr/upload/uploadtest"
+)
+
+func TestRunResume(t *testing.T) {
+	dir := t.TempDir()
+
+	var input strings.Builder
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&input, "%d:100\n", 1000+i)
+		if i == 4 {
+			input.WriteString("\n")
+		}
+	}
+
+	inputName := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(inputName, []byte(input.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := uploadtest.NewServer()
+	defer srv.Close()
+
+	s := api.NewSession(srv.URL)
+	if err := s.Login(context.Background(), "user", uploadtest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	users := 0
+
+	job := &export.Job{
+		StateFile: filepath.Join(dir, "state.log"),
+		Input:     inputName,
+		InFormat:  bss.FormatText,
+		InParams:  &xgen.MinimalFormat,
+		Dir:       dir,
+		Split:     bss.SplitOptions{Format: bss.FormatText, Params: &xgen.MinimalFormat, MaxUsers: 3},
+		BatchSize: 2,
+		Transform: func(ur *xgen.UserRecord) {
+			// the export dies after the second part is committed
+			if users++; users == 7 {
+				cancel()
+			}
+		},
+		Uploader: &upload.Uploader{Client: upload.NewClient(s), MemberID: 7},
+	}
+
+	st, err := export.Run(ctx, job)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected interrupted export, got", err)
+	}
+
+	if len(st.Parts) != 2 || st.Done {
+		t.Fatalf("unexpected state after interruption: %+v", st)
+	}
+
+	// incomplete entry left by a crash during the state file write
+	sf, err := os.OpenFile(job.StateFile, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf.WriteString(`{"type":"part","part":{"ind`)
+	sf.Close()
+
+	srv.FailUploads(1)
+
+	st, err = export.Run(context.Background(), job)
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 parts are not uploaded") {
+		t.Fatal("expected upload failure, got", err)
+	}
+
+	if !st.Done || len(st.Parts) != 4 || len(st.Pending()) != 1 || users != 12 {
+		t.Fatalf("unexpected state after resume: %+v, users %d", st, users)
+	}
+
+	st, err = export.Run(context.Background(), job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// records read after the last checkpoint are read again
+	if len(st.Pending()) != 0 || users != 12 {
+		t.Fatalf("unexpected state after retry: %+v", st)
+	}
+
+	// the failed first part is uploaded last
+	uploads := srv.Uploads()
+	uploaded := string(uploads[len(uploads)-1])
+	for _, data := range uploads[:len(uploads)-1] {
+		uploaded += string(data)
+	}
+
+	if uploaded != strings.Replace(input.String(), "\n\n", "\n", 1) {
+		t.Fatalf("uploaded data differs from input:\n%s", uploaded)
+	}
+
+	for i, p := range st.Parts {
+		if p.Name != fmt.Sprintf("part-%05d.txt", i+1) || p.JobID == "" {
+			t.Fatalf("unexpected part %d: %+v", i, p)
+		}
+	}
+
+	if err := os.WriteFile(inputName, []byte("1:100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := export.Run(context.Background(), job); err == nil {
+		t.Fatal("expected changed input error")
+	}
+}
+
+func TestRunResumeLine(t *testing.T) {
+	dir := t.TempDir()
+
+	// the invalid line 9 is read after the resume
+	input := "1000:100\n\n1001:100\n1002:100\n1003:100\n1004:100\n\n1005:100\ninvalid\n"
+
+	inputName := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(inputName, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	users := 0
+
+	job := &export.Job{
+		StateFile: filepath.Join(dir, "state.log"),
+		Input:     inputName,
+		InFormat:  bss.FormatText,
+		InParams:  &xgen.MinimalFormat,
+		Dir:       dir,
+		Split:     bss.SplitOptions{Format: bss.FormatText, Params: &xgen.MinimalFormat, MaxUsers: 3},
+		BatchSize: 1,
+		Transform: func(ur *xgen.UserRecord) {
+			// the export dies after the first part is committed
+			if users++; users == 4 {
+				cancel()
+			}
+		},
+	}
+
+	st, err := export.Run(ctx, job)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected interrupted export, got", err)
+	}
+
+	if cp := st.Checkpoint(); len(st.Parts) != 1 || cp.Lines != 4 {
+		t.Fatalf("unexpected state after interruption: %+v", st)
+	}
+
+	_, err = export.Run(context.Background(), job)
+
+	var le *bss.LineError
+	if !errors.As(err, &le) || le.Line != 9 {
+		t.Fatal("expected error on line 9, got", err)
+	}
+}
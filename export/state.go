@@ -0,0 +1,180 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Checkpoint is a position in the input after which nothing is written yet.
+type Checkpoint struct {
+	Offset  int64 `json:"offset"`  // input bytes consumed, text format only
+	Lines   int64 `json:"lines"`   // input lines consumed including empty ones, text format only
+	Records int64 `json:"records"` // input records consumed
+}
+
+// PartState is a committed part of the export.
+type PartState struct {
+	Index      int        `json:"index"`
+	Name       string     `json:"name"`
+	Users      int        `json:"users"`
+	Bytes      int64      `json:"bytes"`
+	Checkpoint Checkpoint `json:"checkpoint"` // input position after the part
+	JobID      string     `json:"job_id,omitempty"`
+	Uploaded   bool       `json:"uploaded,omitempty"`
+	Error      string     `json:"error,omitempty"` // last upload error
+}
+
+// State is the export progress rebuilt from the state file.
+type State struct {
+	Job   json.RawMessage `json:"job"` // job description, a restarted job should have the same one
+	Parts []PartState     `json:"parts"`
+	Done  bool            `json:"done"` // input is exported completely
+}
+
+// Checkpoint returns input position to resume export from.
+func (st *State) Checkpoint() Checkpoint {
+	if len(st.Parts) == 0 {
+		return Checkpoint{}
+	}
+	return st.Parts[len(st.Parts)-1].Checkpoint
+}
+
+// Pending returns parts which are not uploaded.
+func (st *State) Pending() []*PartState {
+	var list []*PartState
+	for i := range st.Parts {
+		if !st.Parts[i].Uploaded {
+			list = append(list, &st.Parts[i])
+		}
+	}
+	return list
+}
+
+// entry is a record of the state file.
+type entry struct {
+	Type  string          `json:"type"` // start, part, upload or done
+	Job   json.RawMessage `json:"job,omitempty"`
+	Part  *PartState      `json:"part,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	JobID string          `json:"job_id,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+func (st *State) apply(e *entry) error {
+	switch e.Type {
+	case "start":
+		st.Job = e.Job
+	case "part":
+		if e.Part == nil || e.Part.Index != len(st.Parts)+1 {
+			return errors.New("part entry is out of order")
+		}
+		st.Parts = append(st.Parts, *e.Part)
+	case "upload":
+		for i := range st.Parts {
+			if st.Parts[i].Name == e.Name {
+				st.Parts[i].JobID = e.JobID
+				st.Parts[i].Error = e.Error
+				st.Parts[i].Uploaded = e.Error == ""
+				return nil
+			}
+		}
+		return fmt.Errorf("upload entry of unknown part %s", e.Name)
+	case "done":
+		st.Done = true
+	default:
+		return fmt.Errorf("unknown entry type %q", e.Type)
+	}
+	return nil
+}
+
+// stateFile is an append-only log of state entries. Every entry is synced to disk before
+// the state changes, so the state survives crashes at any point.
+type stateFile struct {
+	f     *os.File
+	state State
+}
+
+// openState replays the state file. Incomplete last entry left by a crash is discarded.
+func openState(name string) (*stateFile, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	sf := &stateFile{f: f}
+
+	br := bufio.NewReader(f)
+	var valid int64
+
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			break // incomplete line without newline is a torn write
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		var e entry
+		if err := json.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: entry %d: %w", name, n, err)
+		}
+
+		if err := sf.state.apply(&e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: entry %d: %w", name, n, err)
+		}
+
+		valid += int64(len(line))
+	}
+
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if valid == 0 {
+		// make the new file durable
+		if d, err := os.Open(filepath.Dir(name)); err == nil {
+			d.Sync()
+			d.Close()
+		}
+	}
+
+	return sf, nil
+}
+
+// append writes entry to the file, syncs it and applies it to the state.
+func (sf *stateFile) append(e *entry) error {
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sf.f.Write(append(buf, '\n')); err != nil {
+		return err
+	}
+
+	if err := sf.f.Sync(); err != nil {
+		return err
+	}
+
+	return sf.state.apply(e)
+}
+
+func (sf *stateFile) Close() error {
+	return sf.f.Close()
+}
@@ -0,0 +1,181 @@
+// Package decode sets struct fields from decoded feed and report values by reflection.
+package decode
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Fields returns field indexes of struct type t by names in the tag.
+func Fields(t reflect.Type, tag string) (map[string]int, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("record type %s is not a struct", t)
+	}
+
+	fields := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		if name := t.Field(i).Tag.Get(tag); name != "" {
+			fields[name] = i
+		}
+	}
+
+	return fields, nil
+}
+
+// Setter sets string, bool, integer, float and time.Time fields.
+type Setter struct {
+	TimeLayouts []string // layouts of time values tried in order
+	Thousands   bool     // numbers may contain comma thousands separators
+}
+
+// SetString parses s into the field.
+func (st *Setter) SetString(f reflect.Value, s string) error {
+	if f.Type() == timeType {
+		for _, layout := range st.TimeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				f.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid time %q", s)
+	}
+
+	if st.Thousands && f.Kind() != reflect.String {
+		s = strings.ReplaceAll(s, ",", "")
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		f.SetBool(s == "1" || s == "true")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+
+	return nil
+}
+
+// SetValue sets a decoded avro, protobuf or JSON value. Int64 values of time.Time fields are seconds since epoch.
+// Numbers out of the range of the field and fractional numbers set to integer fields fail.
+func (st *Setter) SetValue(f reflect.Value, value interface{}) error {
+	switch x := value.(type) {
+	case string:
+		return st.SetString(f, x)
+	case bool:
+		if f.Kind() == reflect.Bool {
+			f.SetBool(x)
+			return nil
+		}
+	case int32:
+		return setInt(f, int64(x))
+	case int64:
+		if f.Type() == timeType {
+			f.Set(reflect.ValueOf(time.Unix(x, 0).UTC()))
+			return nil
+		}
+		return setInt(f, x)
+	case uint64:
+		return setUint(f, x)
+	case float32:
+		return setFloat(f, float64(x))
+	case float64:
+		return setFloat(f, x)
+	}
+
+	return fmt.Errorf("cannot set %T to %s", value, f.Type())
+}
+
+func setInt(f reflect.Value, n int64) error {
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, f.Type())
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n < 0 || f.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %d overflows %s", n, f.Type())
+		}
+		f.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f.SetFloat(float64(n))
+	case reflect.Bool:
+		f.SetBool(n != 0)
+	default:
+		return fmt.Errorf("cannot set number to %s", f.Type())
+	}
+	return nil
+}
+
+func setUint(f reflect.Value, n uint64) error {
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n > math.MaxInt64 {
+			return fmt.Errorf("value %d overflows %s", n, f.Type())
+		}
+		return setInt(f, int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if f.OverflowUint(n) {
+			return fmt.Errorf("value %d overflows %s", n, f.Type())
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f.SetFloat(float64(n))
+	case reflect.Bool:
+		f.SetBool(n != 0)
+	default:
+		return fmt.Errorf("cannot set number to %s", f.Type())
+	}
+	return nil
+}
+
+func setFloat(f reflect.Value, x float64) error {
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// float64(math.MaxInt64) rounds up to 2^63, which is out of range
+		if x != math.Trunc(x) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return fmt.Errorf("value %v is not an integer in the range of %s", x, f.Type())
+		}
+		return setInt(f, int64(x))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if x != math.Trunc(x) || x < 0 || x >= math.MaxUint64 {
+			return fmt.Errorf("value %v is not an integer in the range of %s", x, f.Type())
+		}
+		return setUint(f, uint64(x))
+	case reflect.Float32, reflect.Float64:
+		if f.OverflowFloat(x) {
+			return fmt.Errorf("value %v overflows %s", x, f.Type())
+		}
+		f.SetFloat(x)
+	case reflect.Bool:
+		f.SetBool(x != 0)
+	default:
+		return fmt.Errorf("cannot set number to %s", f.Type())
+	}
+	return nil
+}
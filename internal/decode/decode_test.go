@@ -0,0 +1,82 @@
+package decode
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type record struct {
+	Day    time.Time `csv:"day"`
+	Count  int64     `csv:"count"`
+	Units  uint32    `csv:"units"`
+	Cost   float64   `csv:"cost"`
+	OK     bool      `csv:"ok"`
+	Name   string    `csv:"name"`
+	Hidden int
+}
+
+func TestSetter(t *testing.T) {
+	fields, err := Fields(reflect.TypeOf(record{}), "csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fields) != 6 || fields["cost"] != 3 {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+
+	st := &Setter{TimeLayouts: []string{"2006-01-02 15:04", "2006-01-02"}, Thousands: true}
+
+	var r record
+	v := reflect.ValueOf(&r).Elem()
+
+	for name, s := range map[string]string{"day": "2023-05-05", "count": "1,234", "units": "7", "cost": "1,000.5", "ok": "1", "name": "a,b"} {
+		if err := st.SetString(v.Field(fields[name]), s); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+
+	expected := record{Day: time.Date(2023, 5, 5, 0, 0, 0, 0, time.UTC), Count: 1234, Units: 7, Cost: 1000.5, OK: true, Name: "a,b"}
+	if r != expected {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, r)
+	}
+
+	if err := st.SetString(v.Field(fields["day"]), "May 5"); err == nil {
+		t.Fatal("expected invalid time error")
+	}
+
+	if err := st.SetValue(v.Field(fields["day"]), int64(1683280800)); err != nil || r.Day != time.Unix(1683280800, 0).UTC() {
+		t.Fatal("unexpected time:", r.Day, err)
+	}
+
+	if err := st.SetValue(v.Field(fields["units"]), uint64(9)); err != nil || r.Units != 9 {
+		t.Fatal("unexpected units:", r.Units, err)
+	}
+
+	if err := st.SetValue(v.Field(fields["name"]), 1.5); err == nil {
+		t.Fatal("expected type error")
+	}
+
+	for _, value := range []interface{}{int64(-1), int64(1 << 32), uint64(1 << 40), 2.5, -1.0, 1e20} {
+		if err := st.SetValue(v.Field(fields["units"]), value); err == nil {
+			t.Fatalf("expected range error for %v, got units %d", value, r.Units)
+		}
+	}
+
+	if err := st.SetValue(v.Field(fields["count"]), uint64(1<<63)); err == nil {
+		t.Fatal("expected range error, got count", r.Count)
+	}
+
+	if err := st.SetValue(v.Field(fields["count"]), 9.223372036854775807e18); err == nil {
+		t.Fatal("expected range error, got count", r.Count)
+	}
+
+	if err := st.SetValue(v.Field(fields["count"]), float64(-42)); err != nil || r.Count != -42 {
+		t.Fatal("unexpected count:", r.Count, err)
+	}
+
+	if _, err := Fields(reflect.TypeOf(1), "csv"); err == nil {
+		t.Fatal("expected error for non-struct type")
+	}
+}
@@ -0,0 +1,207 @@
+package lld
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// AggregateSegmentFeed groups segment feed records by user into user records sorted by UID.
+// Segment timestamp is the time of the latest load.
+func AggregateSegmentFeed(rd *Reader[SegmentFeed]) ([]*xgen.UserRecord, error) {
+	users := make(map[int64]map[int32]xgen.Segment)
+
+	for rd.Next() {
+		rec := rd.Record()
+
+		segs := users[rec.UserID64]
+		if segs == nil {
+			segs = make(map[int32]xgen.Segment)
+			users[rec.UserID64] = segs
+		}
+
+		ts := rec.DateTime.Unix()
+		if seg, ok := segs[rec.SegmentID]; ok && seg.Timestamp > ts {
+			continue
+		}
+
+		segs[rec.SegmentID] = xgen.Segment{ID: rec.SegmentID, Value: rec.Value, Timestamp: ts}
+	}
+
+	if err := rd.Err(); err != nil {
+		return nil, err
+	}
+
+	list := make([]*xgen.UserRecord, 0, len(users))
+
+	for uid, segs := range users {
+		ur := &xgen.UserRecord{UID: strconv.FormatInt(uid, 10), Domain: xgen.XandrID}
+		for _, seg := range segs {
+			ur.Segments = append(ur.Segments, seg)
+		}
+		sort.Slice(ur.Segments, func(i, j int) bool { return ur.Segments[i].ID < ur.Segments[j].ID })
+		list = append(list, ur)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].UID < list[j].UID })
+
+	return list, nil
+}
+
+// SegmentAudit compares uploaded and observed users of a segment.
+type SegmentAudit struct {
+	SegmentID  int32
+	Uploaded   int      // users added by the upload
+	Removed    int      // users removed by the upload
+	Observed   int      // users loaded into the segment according to the feed
+	Missing    []string // added users which were never loaded
+	NotRemoved []string // removed users which are still loaded
+}
+
+// AuditReport is the result of Audit sorted by segment ID.
+type AuditReport struct {
+	Segments []SegmentAudit
+	Skipped  int // uploaded memberships which cannot be compared: device IDs or segments without ID
+}
+
+// Audit compares uploaded user records with records observed in the segment feed.
+// Only Xandr user IDs and segment IDs can be compared, use bss.SegmentResolver to convert codes.
+func Audit(uploaded, observed bss.RecordReader) (*AuditReport, error) {
+	type state struct {
+		removed  bool
+		observed bool
+	}
+
+	segments := make(map[int32]map[string]*state)
+	observedCount := make(map[int32]int)
+	rep := &AuditReport{}
+
+	for {
+		ur, err := uploaded.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, seg := range ur.Segments {
+			if ur.Domain != xgen.XandrID || seg.ID == 0 {
+				rep.Skipped++
+				continue
+			}
+
+			users := segments[seg.ID]
+			if users == nil {
+				users = make(map[string]*state)
+				segments[seg.ID] = users
+			}
+
+			users[ur.UID] = &state{removed: seg.Expiration == xgen.Expired}
+		}
+	}
+
+	for {
+		ur, err := observed.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, seg := range ur.Segments {
+			observedCount[seg.ID]++
+			if st := segments[seg.ID][ur.UID]; st != nil {
+				st.observed = true
+			}
+		}
+	}
+
+	for id, users := range segments {
+		sa := SegmentAudit{SegmentID: id, Observed: observedCount[id]}
+
+		for uid, st := range users {
+			switch {
+			case st.removed:
+				sa.Removed++
+				if st.observed {
+					sa.NotRemoved = append(sa.NotRemoved, uid)
+				}
+			default:
+				sa.Uploaded++
+				if !st.observed {
+					sa.Missing = append(sa.Missing, uid)
+				}
+			}
+		}
+
+		sort.Strings(sa.Missing)
+		sort.Strings(sa.NotRemoved)
+
+		rep.Segments = append(rep.Segments, sa)
+	}
+
+	sort.Slice(rep.Segments, func(i, j int) bool { return rep.Segments[i].SegmentID < rep.Segments[j].SegmentID })
+
+	return rep, nil
+}
+
+// WriteText writes per segment summary followed by up to maxUsers missing and not removed users per segment.
+func (rep *AuditReport) WriteText(w io.Writer, maxUsers int) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "segment\tuploaded\tremoved\tobserved\tmissing\tnot removed\n")
+	for _, sa := range rep.Segments {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%d\n", sa.SegmentID, sa.Uploaded, sa.Removed, sa.Observed, len(sa.Missing), len(sa.NotRemoved))
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "skipped memberships: %d\n", rep.Skipped)
+
+	for _, sa := range rep.Segments {
+		writeUsers(w, fmt.Sprintf("segment %d missing", sa.SegmentID), sa.Missing, maxUsers)
+		writeUsers(w, fmt.Sprintf("segment %d not removed", sa.SegmentID), sa.NotRemoved, maxUsers)
+	}
+
+	return nil
+}
+
+func writeUsers(w io.Writer, title string, uids []string, max int) {
+	if len(uids) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "%s:", title)
+	for i, uid := range uids {
+		if max > 0 && i == max {
+			fmt.Fprintf(w, " ... (%d more)", len(uids)-i)
+			break
+		}
+		fmt.Fprintf(w, " %s", uid)
+	}
+	fmt.Fprintln(w)
+}
+
+// SliceReader returns records of a slice, e.g. aggregated by AggregateSegmentFeed.
+type SliceReader struct {
+	Records []*xgen.UserRecord
+}
+
+// Read implements bss.RecordReader.
+func (sr *SliceReader) Read() (*xgen.UserRecord, error) {
+	if len(sr.Records) == 0 {
+		return nil, io.EOF
+	}
+	ur := sr.Records[0]
+	sr.Records = sr.Records[1:]
+	return ur, nil
+}
@@ -0,0 +1,68 @@
+package lld
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+func TestAudit(t *testing.T) {
+	f, err := os.Open("testdata/segment_feed.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rd, err := NewReader[SegmentFeed](f, FormatTSV, SegmentFeedColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	observed, err := AggregateSegmentFeed(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(observed) != 2 || len(observed[0].Segments) != 2 || observed[0].Segments[1].Timestamp != 1683281700 {
+		t.Fatalf("invalid aggregated records: %+v", observed[0])
+	}
+
+	const uploaded = `1234567890:100
+1234567891:100#101
+1234567892:101
+6D92078A-8246-4BA4-AE5B-76104861E7DC:100^3
+`
+
+	dr, err := bss.NewSegmentDataReader(strings.NewReader(uploaded), bss.FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := Audit(dr, &SliceReader{Records: observed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &AuditReport{
+		Segments: []SegmentAudit{
+			{SegmentID: 100, Uploaded: 2, Observed: 1, Missing: []string{"1234567891"}},
+			{SegmentID: 101, Uploaded: 1, Removed: 1, Observed: 2, Missing: []string{"1234567892"}, NotRemoved: []string{"1234567891"}},
+		},
+		Skipped: 1,
+	}
+
+	if !reflect.DeepEqual(rep, expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, rep)
+	}
+
+	var out bytes.Buffer
+	if err := rep.WriteText(&out, 10); err != nil {
+		t.Fatal(err)
+	}
+	t.Log("\n" + out.String())
+}
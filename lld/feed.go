@@ -0,0 +1,48 @@
+package lld
+
+import "time"
+
+// SegmentFeed is a record of segment_feed, a segment load of a user.
+type SegmentFeed struct {
+	DateTime        time.Time `lld:"date_time"`
+	UserID64        int64     `lld:"user_id_64"`
+	MemberID        int32     `lld:"member_id"`
+	SegmentID       int32     `lld:"segment_id"`
+	IsDailyUnique   bool      `lld:"is_daily_unique"`
+	IsMonthlyUnique bool      `lld:"is_monthly_unique"`
+	Value           int32     `lld:"value"`
+}
+
+// SegmentFeedColumns is the column order of segment_feed in tab separated format.
+var SegmentFeedColumns = []string{
+	"date_time",
+	"user_id_64",
+	"member_id",
+	"segment_id",
+	"is_daily_unique",
+	"is_monthly_unique",
+	"value",
+}
+
+// StandardFeed contains commonly used columns of standard_feed.
+// Columns which are not declared here are skipped by the Reader.
+// Tab separated standard_feed files require column list of the feed configuration.
+type StandardFeed struct {
+	AuctionID64         int64     `lld:"auction_id_64"`
+	DateTime            time.Time `lld:"date_time"`
+	EventType           string    `lld:"event_type"`
+	ImpType             int32     `lld:"imp_type"`
+	MediaCostDollarsCPM float64   `lld:"media_cost_dollars_cpm"`
+	UserID64            int64     `lld:"user_id_64"`
+	GeoCountry          string    `lld:"geo_country"`
+	SellerMemberID      int32     `lld:"seller_member_id"`
+	PublisherID         int32     `lld:"publisher_id"`
+	SiteDomain          string    `lld:"site_domain"`
+	TagID               int32     `lld:"tag_id"`
+	BuyerMemberID       int32     `lld:"buyer_member_id"`
+	AdvertiserID        int32     `lld:"advertiser_id"`
+	CampaignID          int32     `lld:"campaign_id"`
+	CreativeID          int32     `lld:"creative_id"`
+	BuyerSpend          float64   `lld:"buyer_spend"`
+	DeviceUniqueID      string    `lld:"device_unique_id"`
+}
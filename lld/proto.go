@@ -0,0 +1,141 @@
+package lld
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// maxProtoRecordSize limits size of a single protobuf record.
+const maxProtoRecordSize = 64 * 1024 * 1024
+
+// ProtoSchema describes records of a protobuf feed.
+type ProtoSchema struct {
+	Message protoreflect.MessageDescriptor
+}
+
+// ParseDescriptorSet returns schema of the message from a serialized google.protobuf.FileDescriptorSet,
+// e.g. written by protoc --include_imports --descriptor_set_out for the feed .proto file. Message is
+// a full name with the package, e.g. "lld.SegmentFeed", or a name without the package.
+func ParseDescriptorSet(data []byte, message string) (*ProtoSchema, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("descriptor set: %w", err)
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("descriptor set: %w", err)
+	}
+
+	var md protoreflect.MessageDescriptor
+
+	if d, err := files.FindDescriptorByName(protoreflect.FullName(message)); err == nil {
+		md, _ = d.(protoreflect.MessageDescriptor)
+	}
+
+	// a name without the package matches the first message with the name
+	if md == nil && !strings.Contains(message, ".") {
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			md = findMessage(fd.Messages(), protoreflect.Name(message))
+			return md == nil
+		})
+	}
+
+	if md == nil {
+		return nil, fmt.Errorf("descriptor set: message %s not found", message)
+	}
+
+	return &ProtoSchema{Message: md}, nil
+}
+
+// findMessage looks for the message in the list and in nested messages.
+func findMessage(list protoreflect.MessageDescriptors, name protoreflect.Name) protoreflect.MessageDescriptor {
+	for i := 0; i < list.Len(); i++ {
+		md := list.Get(i)
+		if md.Name() == name {
+			return md
+		}
+		if nested := findMessage(md.Messages(), name); nested != nil {
+			return nested
+		}
+	}
+	return nil
+}
+
+// protoColumn is a message field decoded into a field of the record.
+type protoColumn struct {
+	field protoreflect.FieldDescriptor
+	index int
+}
+
+// protoReader reads varint length-delimited messages.
+type protoReader struct {
+	r       *bufio.Reader
+	message protoreflect.MessageDescriptor
+	columns []protoColumn
+}
+
+// newProtoReader matches message fields with record fields. Only scalar fields can be decoded,
+// repeated, map and message fields matching a record field fail.
+func newProtoReader(r *bufio.Reader, schema *ProtoSchema, fields map[string]int) (*protoReader, error) {
+	pr := &protoReader{r: r, message: schema.Message}
+
+	list := schema.Message.Fields()
+	for i := 0; i < list.Len(); i++ {
+		fd := list.Get(i)
+
+		idx, ok := fields[string(fd.Name())]
+		if !ok {
+			continue
+		}
+
+		switch {
+		case fd.Cardinality() == protoreflect.Repeated:
+			return nil, fmt.Errorf("field %s: repeated fields are not supported", fd.Name())
+		case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+			return nil, fmt.Errorf("field %s: %s fields are not supported", fd.Name(), fd.Kind())
+		}
+
+		pr.columns = append(pr.columns, protoColumn{field: fd, index: idx})
+	}
+
+	return pr, nil
+}
+
+// next returns the next message or io.EOF. Messages with fields unknown to the descriptor fail,
+// including fields encoded with a wire type which does not match the descriptor.
+func (pr *protoReader) next() (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(pr.message)
+
+	opts := protodelim.UnmarshalOptions{MaxSize: maxProtoRecordSize}
+	if err := opts.UnmarshalFrom(pr.r, msg); err != nil {
+		return nil, err
+	}
+
+	if len(msg.GetUnknown()) > 0 {
+		return nil, fmt.Errorf("fields unknown to the %s descriptor", pr.message.FullName())
+	}
+
+	return msg, nil
+}
+
+// protoValue converts a field value to a Go value accepted by decode.Setter.
+func protoValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return int32(v.Enum())
+	case protoreflect.BytesKind:
+		return string(v.Bytes())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return int64(v.Uint())
+	}
+	return v.Interface()
+}
@@ -0,0 +1,218 @@
+package lld
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func fieldDescriptor(name string, number int32, t descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   t.Enum(),
+	}
+}
+
+// testDescriptorSet returns FileDescriptorSet of package lld with SegmentFeed, Other.Nested
+// and Repeated messages.
+func testDescriptorSet(t *testing.T) []byte {
+	repeated := fieldDescriptor("segment_id", 4, descriptorpb.FieldDescriptorProto_TYPE_INT32)
+	repeated.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("lld.proto"),
+		Package: proto.String("lld"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("SegmentFeed"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					fieldDescriptor("date_time", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+					fieldDescriptor("user_id_64", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64),
+					fieldDescriptor("member_id", 3, descriptorpb.FieldDescriptorProto_TYPE_INT32),
+					fieldDescriptor("segment_id", 4, descriptorpb.FieldDescriptorProto_TYPE_SINT32),
+					fieldDescriptor("is_daily_unique", 5, descriptorpb.FieldDescriptorProto_TYPE_BOOL),
+					fieldDescriptor("is_monthly_unique", 6, descriptorpb.FieldDescriptorProto_TYPE_BOOL),
+					fieldDescriptor("value", 7, descriptorpb.FieldDescriptorProto_TYPE_UINT32),
+					fieldDescriptor("comment", 8, descriptorpb.FieldDescriptorProto_TYPE_STRING),
+				},
+			},
+			{
+				Name: proto.String("Other"),
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name:  proto.String("Nested"),
+					Field: []*descriptorpb.FieldDescriptorProto{fieldDescriptor("cost", 1, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE)},
+				}},
+			},
+			{
+				Name:  proto.String("Repeated"),
+				Field: []*descriptorpb.FieldDescriptorProto{repeated},
+			},
+		},
+	}
+
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func parseSchema(t *testing.T, message string) *ProtoSchema {
+	schema, err := ParseDescriptorSet(testDescriptorSet(t), message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// appendMessage appends length-delimited message of the schema with the field values.
+func appendMessage(t *testing.T, b []byte, schema *ProtoSchema, values map[string]protoreflect.Value) []byte {
+	msg := dynamicpb.NewMessage(schema.Message)
+	for name, v := range values {
+		if fd := schema.Message.Fields().ByName(protoreflect.Name(name)); fd.IsList() {
+			list := msg.Mutable(fd).List()
+			list.Append(v)
+		} else {
+			msg.Set(fd, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := protodelim.MarshalTo(&buf, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	return append(b, buf.Bytes()...)
+}
+
+func TestParseDescriptorSet(t *testing.T) {
+	for _, name := range []string{"lld.SegmentFeed", "SegmentFeed"} {
+		schema := parseSchema(t, name)
+		if schema.Message.FullName() != "lld.SegmentFeed" || schema.Message.Fields().Len() != 8 {
+			t.Fatalf("unexpected schema: %v", schema.Message.FullName())
+		}
+	}
+
+	if schema := parseSchema(t, "Nested"); schema.Message.FullName() != "lld.Other.Nested" {
+		t.Fatalf("unexpected schema: %v", schema.Message.FullName())
+	}
+
+	if _, err := ParseDescriptorSet(testDescriptorSet(t), "lld.Missing"); err == nil {
+		t.Fatal("expected error for missing message")
+	}
+
+	if _, err := ParseDescriptorSet([]byte("invalid"), "lld.SegmentFeed"); err == nil {
+		t.Fatal("expected error for invalid descriptor set")
+	}
+}
+
+func readAll(t *testing.T, data []byte, schema *ProtoSchema) ([]SegmentFeed, error) {
+	rd, err := NewProtobufReader[SegmentFeed](bytes.NewReader(data), schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var list []SegmentFeed
+	for rd.Next() {
+		list = append(list, *rd.Record())
+	}
+
+	return list, rd.Err()
+}
+
+func TestReaderProtobuf(t *testing.T) {
+	_, err := NewReader[SegmentFeed](bytes.NewReader(nil), FormatProtobuf, nil)
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatal("expected ErrUnsupportedFormat, got", err)
+	}
+
+	schema := parseSchema(t, "lld.SegmentFeed")
+
+	data := appendMessage(t, nil, schema, map[string]protoreflect.Value{
+		"date_time":       protoreflect.ValueOfInt64(time.Date(2023, 5, 5, 10, 0, 2, 0, time.UTC).Unix()),
+		"user_id_64":      protoreflect.ValueOfInt64(1234567891),
+		"member_id":       protoreflect.ValueOfInt32(7),
+		"segment_id":      protoreflect.ValueOfInt32(-101),
+		"is_daily_unique": protoreflect.ValueOfBool(true),
+		"value":           protoreflect.ValueOfUint32(5),
+		"comment":         protoreflect.ValueOfString("skipped"),
+	})
+	data = appendMessage(t, data, schema, map[string]protoreflect.Value{"member_id": protoreflect.ValueOfInt32(8)})
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(data)
+	w.Close()
+
+	expected := []SegmentFeed{
+		{
+			DateTime:      time.Date(2023, 5, 5, 10, 0, 2, 0, time.UTC),
+			UserID64:      1234567891,
+			MemberID:      7,
+			SegmentID:     -101,
+			IsDailyUnique: true,
+			Value:         5,
+		},
+		{MemberID: 8},
+	}
+
+	for _, input := range [][]byte{data, gz.Bytes()} {
+		list, err := readAll(t, input, schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(list, expected) {
+			t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, list)
+		}
+	}
+
+	// truncated record
+	list, err := readAll(t, data[:len(data)-1], schema)
+	if err == nil || !strings.HasPrefix(err.Error(), "record 2: ") || len(list) != 1 {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestReaderProtobufInvalid(t *testing.T) {
+	schema := parseSchema(t, "lld.SegmentFeed")
+	repeated := parseSchema(t, "lld.Repeated")
+
+	// a repeated field cannot be decoded into a column
+	_, err := NewProtobufReader[SegmentFeed](bytes.NewReader(nil), repeated)
+	if err == nil || err.Error() != "field segment_id: repeated fields are not supported" {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// packed values of a field declared as scalar in the descriptor are not skipped
+	data := appendMessage(t, nil, repeated, map[string]protoreflect.Value{"segment_id": protoreflect.ValueOfInt32(100)})
+
+	_, err = readAll(t, data, schema)
+	if err == nil || err.Error() != "record 1: fields unknown to the lld.SegmentFeed descriptor" {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// field number 0 is invalid
+	data = binary.AppendUvarint(nil, 2)
+	data = append(data, 0, 0)
+
+	_, err = readAll(t, data, schema)
+	if err == nil || !strings.HasPrefix(err.Error(), "record 1: ") {
+		t.Fatal("unexpected error:", err)
+	}
+}
@@ -0,0 +1,256 @@
+package lld
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/milla-v/xandr/internal/decode"
+)
+
+type Format string
+
+const (
+	FormatTSV      Format = "csv" // tab separated, named csv in feed settings
+	FormatAvro     Format = "avro"
+	FormatProtobuf Format = "protobuf"
+)
+
+// ErrUnsupportedFormat is returned for formats which cannot be decoded without a schema.
+var ErrUnsupportedFormat = errors.New("unsupported feed format")
+
+// setter decodes values of feed columns, date_time is formatted as "2006-01-02 15:04:05" in text formats.
+var setter = &decode.Setter{TimeLayouts: []string{"2006-01-02 15:04:05"}}
+
+// Reader decodes feed records into T. Fields of T are matched with columns by the lld tag.
+// Gzip compressed input is detected automatically.
+type Reader[T any] struct {
+	fields  map[string]int // column -> field index
+	columns []string
+	scanner *bufio.Scanner
+	ocf     *goavro.OCFReader
+	pb      *protoReader
+	line    int
+	record  T
+	err     error
+}
+
+// NewReader creates streaming reader. Columns define column order of tab separated format
+// and are ignored for avro which has column names in the schema. Protobuf feeds are read
+// by NewProtobufReader.
+func NewReader[T any](r io.Reader, format Format, columns []string) (*Reader[T], error) {
+	rd, err := newReader[T](columns)
+	if err != nil {
+		return nil, err
+	}
+
+	if r, err = decompress(r); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatTSV:
+		if len(columns) == 0 {
+			return nil, errors.New("columns are required for tab separated format")
+		}
+		rd.scanner = bufio.NewScanner(r)
+		rd.scanner.Buffer(nil, 1024*1024)
+	case FormatAvro:
+		ocf, err := goavro.NewOCFReader(r)
+		if err != nil {
+			return nil, err
+		}
+		rd.ocf = ocf
+	case FormatProtobuf:
+		return nil, fmt.Errorf("%w: %s requires the feed descriptor, use NewProtobufReader", ErrUnsupportedFormat, format)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+
+	return rd, nil
+}
+
+// NewProtobufReader creates streaming reader of a protobuf feed. Records are varint length-delimited
+// messages of the schema, fields are matched with the lld tag by their names in the descriptor.
+// Matched fields must be scalar, repeated and message fields are not supported.
+func NewProtobufReader[T any](r io.Reader, schema *ProtoSchema) (*Reader[T], error) {
+	rd, err := newReader[T](nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if r, err = decompress(r); err != nil {
+		return nil, err
+	}
+
+	if rd.pb, err = newProtoReader(bufio.NewReader(r), schema, rd.fields); err != nil {
+		return nil, err
+	}
+
+	return rd, nil
+}
+
+func newReader[T any](columns []string) (*Reader[T], error) {
+	rd := &Reader[T]{columns: columns}
+
+	var err error
+	if rd.fields, err = decode.Fields(reflect.TypeOf(rd.record), "lld"); err != nil {
+		return nil, err
+	}
+
+	return rd, nil
+}
+
+// decompress returns reader of gzip compressed data or buffered r.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// Next decodes next record. It returns false at the end of the input or on error.
+func (rd *Reader[T]) Next() bool {
+	if rd.err != nil {
+		return false
+	}
+
+	var zero T
+	rd.record = zero
+
+	if rd.ocf != nil {
+		return rd.nextAvro()
+	}
+
+	if rd.pb != nil {
+		return rd.nextProtobuf()
+	}
+
+	for rd.scanner.Scan() {
+		rd.line++
+
+		text := rd.scanner.Text()
+		if text == "" {
+			continue
+		}
+
+		values := strings.Split(text, "\t")
+		if len(values) != len(rd.columns) {
+			rd.err = fmt.Errorf("line %d: %d columns, expected %d", rd.line, len(values), len(rd.columns))
+			return false
+		}
+
+		v := reflect.ValueOf(&rd.record).Elem()
+
+		for i, s := range values {
+			idx, ok := rd.fields[rd.columns[i]]
+			if !ok || s == "" || s == `\N` || s == "NULL" {
+				continue
+			}
+			if err := setter.SetString(v.Field(idx), s); err != nil {
+				rd.err = fmt.Errorf("line %d: %s: %w", rd.line, rd.columns[i], err)
+				return false
+			}
+		}
+
+		return true
+	}
+
+	rd.err = rd.scanner.Err()
+
+	return false
+}
+
+func (rd *Reader[T]) nextAvro() bool {
+	if !rd.ocf.Scan() {
+		rd.err = rd.ocf.Err()
+		return false
+	}
+
+	rd.line++
+
+	datum, err := rd.ocf.Read()
+	if err != nil {
+		rd.err = err
+		return false
+	}
+
+	m, ok := datum.(map[string]interface{})
+	if !ok {
+		rd.err = fmt.Errorf("record %d: unexpected type %T", rd.line, datum)
+		return false
+	}
+
+	v := reflect.ValueOf(&rd.record).Elem()
+
+	for name, value := range m {
+		idx, ok := rd.fields[name]
+		if !ok {
+			continue
+		}
+
+		// nullable fields are decoded as single key maps
+		if u, ok := value.(map[string]interface{}); ok && len(u) == 1 {
+			for _, uv := range u {
+				value = uv
+			}
+		}
+
+		if value == nil {
+			continue
+		}
+
+		if err := setter.SetValue(v.Field(idx), value); err != nil {
+			rd.err = fmt.Errorf("record %d: %s: %w", rd.line, name, err)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (rd *Reader[T]) nextProtobuf() bool {
+	msg, err := rd.pb.next()
+	if err == io.EOF {
+		return false
+	}
+
+	rd.line++
+
+	if err != nil {
+		rd.err = fmt.Errorf("record %d: %w", rd.line, err)
+		return false
+	}
+
+	v := reflect.ValueOf(&rd.record).Elem()
+
+	for _, c := range rd.pb.columns {
+		if !msg.Has(c.field) {
+			continue
+		}
+
+		value := protoValue(c.field, msg.Get(c.field))
+		if err := setter.SetValue(v.Field(c.index), value); err != nil {
+			rd.err = fmt.Errorf("record %d: %s: %w", rd.line, c.field.Name(), err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// Record returns the record decoded by the last Next call.
+func (rd *Reader[T]) Record() *T {
+	return &rd.record
+}
+
+// Err returns the first error occurred during reading.
+func (rd *Reader[T]) Err() error {
+	return rd.err
+}
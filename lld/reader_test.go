@@ -0,0 +1,90 @@
+package lld
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"testing"
+	"time"
+)
+
+func readSegmentFeed(t *testing.T, name string, format Format, gz bool) []SegmentFeed {
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gz {
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		w.Write(buf)
+		w.Close()
+		buf = b.Bytes()
+	}
+
+	rd, err := NewReader[SegmentFeed](bytes.NewReader(buf), format, SegmentFeedColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var list []SegmentFeed
+	for rd.Next() {
+		list = append(list, *rd.Record())
+	}
+
+	if err := rd.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return list
+}
+
+func TestReaderTSV(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		list := readSegmentFeed(t, "testdata/segment_feed.tsv", FormatTSV, gz)
+
+		if len(list) != 3 {
+			t.Fatal("expected 3 records, got", len(list))
+		}
+
+		expected := SegmentFeed{
+			DateTime:        time.Date(2023, 5, 5, 10, 0, 2, 0, time.UTC),
+			UserID64:        1234567891,
+			MemberID:        7,
+			SegmentID:       101,
+			IsDailyUnique:   true,
+			IsMonthlyUnique: true,
+			Value:           5,
+		}
+
+		if list[1] != expected {
+			t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, list[1])
+		}
+
+		if list[2].Value != 0 || list[2].IsDailyUnique {
+			t.Fatalf("invalid record: %+v", list[2])
+		}
+	}
+}
+
+func TestReaderAvro(t *testing.T) {
+	list := readSegmentFeed(t, "testdata/segment_feed.avro", FormatAvro, false)
+
+	if len(list) != 2 {
+		t.Fatal("expected 2 records, got", len(list))
+	}
+
+	expected := SegmentFeed{
+		DateTime:        time.Date(2023, 5, 5, 10, 0, 2, 0, time.UTC),
+		UserID64:        1234567891,
+		MemberID:        7,
+		SegmentID:       101,
+		IsDailyUnique:   true,
+		IsMonthlyUnique: true,
+		Value:           5,
+	}
+
+	if list[1] != expected {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, list[1])
+	}
+}
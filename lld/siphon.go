@@ -0,0 +1,98 @@
+// Package lld lists, downloads and decodes Log-Level Data feeds described on
+// https://learn.microsoft.com/en-us/xandr/log-level-data/log-level-data-feeds
+package lld
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/milla-v/xandr/api"
+)
+
+// ErrChecksum is returned by Download when the downloaded file does not match the split checksum.
+var ErrChecksum = errors.New("checksum mismatch")
+
+// Split is a part of a feed file.
+type Split struct {
+	Part     string `json:"part"`
+	Status   string `json:"status"`
+	Checksum string `json:"checksum"` // MD5 in hex
+}
+
+// Siphon describes an hour of a feed available for download.
+type Siphon struct {
+	Name      string  `json:"name"`      // feed name, e.g. standard_feed or segment_feed
+	Hour      string  `json:"hour"`      // e.g. 2023_05_05_10
+	Timestamp string  `json:"timestamp"` // generation time, e.g. 20230505115313
+	Splits    []Split `json:"splits"`
+}
+
+// Client calls Siphon and Siphon Download services using authenticated session.
+type Client struct {
+	s *api.Session
+}
+
+// NewClient creates LLD client sharing the session with other clients.
+func NewClient(s *api.Session) *Client {
+	return &Client{s: s}
+}
+
+// List returns feed files available for the member. Empty feed lists all feeds.
+func (c *Client) List(ctx context.Context, memberID int32, feed string) ([]Siphon, error) {
+	q := url.Values{"member_id": {fmt.Sprint(memberID)}}
+	if feed != "" {
+		q.Set("siphon_name", feed)
+	}
+
+	var resp struct {
+		Siphons []Siphon `json:"siphons"`
+	}
+
+	if err := c.s.Do(ctx, http.MethodGet, "/siphon", q, nil, &resp); err != nil {
+		return nil, fmt.Errorf("list siphons: %w", err)
+	}
+
+	return resp.Siphons, nil
+}
+
+// Download writes the split of the feed hour to w and verifies its MD5 checksum.
+// The data is written as is, it can be compressed.
+func (c *Client) Download(ctx context.Context, memberID int32, siphon *Siphon, split *Split, w io.Writer) error {
+	q := url.Values{
+		"member_id":   {fmt.Sprint(memberID)},
+		"siphon_name": {siphon.Name},
+		"hour":        {siphon.Hour},
+		"timestamp":   {siphon.Timestamp},
+		"split_part":  {split.Part},
+	}
+
+	resp, err := c.s.DoRaw(ctx, http.MethodGet, "/siphon-download", q, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s %s part %s: status %s", siphon.Name, siphon.Hour, split.Part, resp.Status)
+	}
+
+	h := md5.New()
+
+	if _, err := io.Copy(io.MultiWriter(w, h), resp.Body); err != nil {
+		return err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if split.Checksum != "" && !strings.EqualFold(sum, split.Checksum) {
+		return fmt.Errorf("%s %s part %s: %w: expected %s, got %s", siphon.Name, siphon.Hour, split.Part, ErrChecksum, split.Checksum, sum)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package lld
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/api/apitest"
+)
+
+func TestSiphonDownload(t *testing.T) {
+	data, err := os.ReadFile("testdata/segment_feed.tsv")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := apitest.NewServer("secret")
+	defer srv.Close()
+
+	srv.Handle("/siphon", func(w http.ResponseWriter, r *http.Request) {
+		apitest.WriteResponse(w, map[string]interface{}{
+			"siphons": []Siphon{
+				{
+					Name:      "segment_feed",
+					Hour:      "2023_05_05_10",
+					Timestamp: "20230505115313",
+					Splits: []Split{
+						{Part: "0", Status: "completed", Checksum: "484d7a3626f920e822a7a5742107a996"},
+						{Part: "1", Status: "completed", Checksum: "00000000000000000000000000000000"},
+					},
+				},
+			},
+		})
+	})
+
+	srv.Handle("/siphon-download", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("siphon_name") != "segment_feed" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+
+	s := api.NewSession(srv.URL)
+	ctx := context.Background()
+
+	if err := s.Login(ctx, "user", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(s)
+
+	siphons, err := c.List(ctx, 7, "segment_feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(siphons) != 1 || len(siphons[0].Splits) != 2 {
+		t.Fatalf("invalid siphons: %+v", siphons)
+	}
+
+	var out bytes.Buffer
+
+	if err := c.Download(ctx, 7, &siphons[0], &siphons[0].Splits[0], &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("downloaded data differs")
+	}
+
+	err = c.Download(ctx, 7, &siphons[0], &siphons[0].Splits[1], &out)
+	if !errors.Is(err, ErrChecksum) {
+		t.Fatal("expected checksum error, got", err)
+	}
+}
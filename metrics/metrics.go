@@ -0,0 +1,112 @@
+// Package metrics provides optional instrumentation of BSS pipelines. Formatters, stages and
+// upload clients report counters and durations to an Instrument, which can keep them for
+// Prometheus text exposition or log them with log/slog.
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Metric names reported by the bss and upload packages.
+const (
+	Users          = "xandr_bss_users_total"         // users written by formatters
+	Segments       = "xandr_bss_segments_total"      // segments of written users
+	Bytes          = "xandr_bss_bytes_total"         // bytes written by formatters
+	Rejects        = "xandr_bss_rejects_total"       // users dropped by a stage or failed to encode, labeled by stage
+	StageUsers     = "xandr_bss_stage_users_total"   // users processed by a stage, labeled by stage
+	UploadBytes    = "xandr_upload_bytes_total"      // bytes sent to upload URLs
+	UploadDuration = "xandr_upload_duration_seconds" // upload requests labeled by status ok or error
+	UploadedUsers  = "xandr_upload_users_total"      // users of completed jobs labeled by result
+)
+
+// Label is a dimension of a metric, e.g. a stage name.
+type Label struct {
+	Key   string
+	Value string
+}
+
+// L returns a label.
+func L(key, value string) Label {
+	return Label{Key: key, Value: value}
+}
+
+// Instrument receives pipeline events. Implementations should be safe for concurrent use.
+type Instrument interface {
+	Count(name string, n int64, labels ...Label)           // adds n to a counter
+	Observe(name string, d time.Duration, labels ...Label) // records a duration
+}
+
+// Multi returns an instrument sending events to every non-nil instrument of the list.
+// It returns nil if there are no instruments.
+func Multi(list ...Instrument) Instrument {
+	var m multi
+	for _, in := range list {
+		if in != nil {
+			m = append(m, in)
+		}
+	}
+
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	}
+
+	return m
+}
+
+type multi []Instrument
+
+func (m multi) Count(name string, n int64, labels ...Label) {
+	for _, in := range m {
+		in.Count(name, n, labels...)
+	}
+}
+
+func (m multi) Observe(name string, d time.Duration, labels ...Label) {
+	for _, in := range m {
+		in.Observe(name, d, labels...)
+	}
+}
+
+// Logger logs events as structured records with the metric name as the message.
+// Counters are logged at debug level and durations at info level.
+type Logger struct {
+	L *slog.Logger
+}
+
+// NewLogger creates logger, slog.Default() is used if l is nil.
+func NewLogger(l *slog.Logger) *Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &Logger{L: l}
+}
+
+// Count implements Instrument.
+func (l *Logger) Count(name string, n int64, labels ...Label) {
+	l.log(slog.LevelDebug, name, slog.Int64("n", n), labels)
+}
+
+// Observe implements Instrument.
+func (l *Logger) Observe(name string, d time.Duration, labels ...Label) {
+	l.log(slog.LevelInfo, name, slog.Duration("duration", d), labels)
+}
+
+func (l *Logger) log(level slog.Level, name string, value slog.Attr, labels []Label) {
+	ctx := context.Background()
+	if !l.L.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, len(labels)+1)
+	attrs = append(attrs, value)
+	for _, lb := range labels {
+		attrs = append(attrs, slog.String(lb.Key, lb.Value))
+	}
+
+	l.L.LogAttrs(ctx, level, name, attrs...)
+}
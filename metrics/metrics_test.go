@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+
+	r.Count(Users, 2)
+	r.Count(Users, 3)
+	r.Count(Rejects, 1, L("stage", "consent"))
+	r.Count(Rejects, 4, L("stage", "limit"))
+	r.Count(Rejects, 1, L("stage", "consent"))
+	r.Observe(UploadDuration, 1500*time.Millisecond, L("status", "ok"))
+	r.Observe(UploadDuration, 500*time.Millisecond, L("status", "ok"))
+	r.Count("with_labels", 1, L("b", "2"), L("a", "x\"y\\z\n"))
+
+	if n := r.Value(Users); n != 5 {
+		t.Fatal("unexpected users:", n)
+	}
+	if n := r.Value(Rejects, L("stage", "consent")); n != 2 {
+		t.Fatal("unexpected rejects:", n)
+	}
+	if d := r.Sum(UploadDuration, L("status", "ok")); d != 2*time.Second {
+		t.Fatal("unexpected duration:", d)
+	}
+
+	var b strings.Builder
+	if err := r.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# TYPE with_labels counter
+with_labels{a="x\"y\\z\n",b="2"} 1
+# TYPE xandr_bss_rejects_total counter
+xandr_bss_rejects_total{stage="consent"} 2
+xandr_bss_rejects_total{stage="limit"} 4
+# TYPE xandr_bss_users_total counter
+xandr_bss_users_total 5
+# TYPE xandr_upload_duration_seconds summary
+xandr_upload_duration_seconds_sum{status="ok"} 2
+xandr_upload_duration_seconds_count{status="ok"} 2
+`
+	if b.String() != expected {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, b.String())
+	}
+}
+
+func TestLoggerAndMulti(t *testing.T) {
+	var buf bytes.Buffer
+
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	r := NewRegistry()
+	in := Multi(nil, r, NewLogger(slog.New(h)))
+
+	in.Count(Rejects, 3, L("stage", "consent"))
+	in.Observe(UploadDuration, time.Second, L("status", "error"))
+
+	expected := "level=DEBUG msg=xandr_bss_rejects_total n=3 stage=consent\n" +
+		"level=INFO msg=xandr_upload_duration_seconds duration=1s status=error\n"
+	if buf.String() != expected {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, buf.String())
+	}
+
+	if n := r.Value(Rejects, L("stage", "consent")); n != 3 {
+		t.Fatal("unexpected rejects:", n)
+	}
+
+	if Multi(nil, nil) != nil {
+		t.Fatal("empty multi should be nil")
+	}
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry keeps counters and duration summaries in memory.
+type Registry struct {
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	name    string
+	labels  string // rendered labels, e.g. {stage="consent"}
+	summary bool
+	value   int64 // counter value or number of observations
+	sum     time.Duration
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{series: make(map[string]*series)}
+}
+
+// Count implements Instrument.
+func (r *Registry) Count(name string, n int64, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.get(name, labels, false).value += n
+}
+
+// Observe implements Instrument.
+func (r *Registry) Observe(name string, d time.Duration, labels ...Label) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.get(name, labels, true)
+	s.value++
+	s.sum += d
+}
+
+// Value returns the counter value or the number of observed durations.
+func (r *Registry) Value(name string, labels ...Label) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.series[name+renderLabels(labels)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// Sum returns the total of observed durations.
+func (r *Registry) Sum(name string, labels ...Label) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s, ok := r.series[name+renderLabels(labels)]; ok {
+		return s.sum
+	}
+	return 0
+}
+
+func (r *Registry) get(name string, labels []Label, summary bool) *series {
+	rendered := renderLabels(labels)
+
+	s, ok := r.series[name+rendered]
+	if !ok {
+		s = &series{name: name, labels: rendered, summary: summary}
+		r.series[name+rendered] = s
+	}
+
+	return s
+}
+
+// WritePrometheus writes metrics in Prometheus text exposition format sorted by name and labels.
+// Durations are written as summaries in seconds.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	list := make([]series, 0, len(r.series))
+	for _, s := range r.series {
+		list = append(list, *s)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].name != list[j].name {
+			return list[i].name < list[j].name
+		}
+		return list[i].labels < list[j].labels
+	})
+
+	bw := bufio.NewWriter(w)
+
+	for i, s := range list {
+		if i == 0 || list[i-1].name != s.name {
+			typ := "counter"
+			if s.summary {
+				typ = "summary"
+			}
+			fmt.Fprintf(bw, "# TYPE %s %s\n", s.name, typ)
+		}
+
+		if !s.summary {
+			fmt.Fprintf(bw, "%s%s %d\n", s.name, s.labels, s.value)
+			continue
+		}
+
+		fmt.Fprintf(bw, "%s_sum%s %s\n", s.name, s.labels, strconv.FormatFloat(s.sum.Seconds(), 'g', -1, 64))
+		fmt.Fprintf(bw, "%s_count%s %d\n", s.name, s.labels, s.value)
+	}
+
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// renderLabels renders labels sorted by key in exposition format.
+func renderLabels(labels []Label) string {
+	switch len(labels) {
+	case 0:
+		return ""
+	case 1:
+		return "{" + labels[0].Key + `="` + labelEscaper.Replace(labels[0].Value) + `"}`
+	}
+
+	sorted := append([]Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, lb := range sorted {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(lb.Key)
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(lb.Value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/milla-v/xandr/internal/decode"
+)
+
+// setter decodes report values. Time columns are hours, days or months, numbers may have thousands separators.
+var setter = &decode.Setter{
+	TimeLayouts: []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "2006-01"},
+	Thousands:   true,
+}
+
+// Decoder decodes CSV report rows into T. Fields of T are matched with the header by the csv tag.
+// Columns without a field are skipped.
+type Decoder[T any] struct {
+	r      *csv.Reader
+	fields []int // field index by column, -1 for skipped columns
+	header []string
+	row    int
+	record T
+	err    error
+}
+
+// NewDecoder reads the header of the CSV report.
+func NewDecoder[T any](r io.Reader) (*Decoder[T], error) {
+	d := &Decoder[T]{r: csv.NewReader(r)}
+
+	byName, err := decode.Fields(reflect.TypeOf(d.record), "csv")
+	if err != nil {
+		return nil, err
+	}
+
+	d.r.ReuseRecord = true
+
+	header, err := d.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	d.header = append([]string(nil), header...)
+
+	for _, col := range d.header {
+		idx, ok := byName[col]
+		if !ok {
+			idx = -1
+		}
+		d.fields = append(d.fields, idx)
+	}
+
+	return d, nil
+}
+
+// Header returns column names of the report.
+func (d *Decoder[T]) Header() []string {
+	return d.header
+}
+
+// Next decodes next row. It returns false at the end of the report or on error.
+func (d *Decoder[T]) Next() bool {
+	if d.err != nil {
+		return false
+	}
+
+	values, err := d.r.Read()
+	if err == io.EOF {
+		return false
+	}
+	if err != nil {
+		d.err = err
+		return false
+	}
+
+	d.row++
+
+	var zero T
+	d.record = zero
+
+	v := reflect.ValueOf(&d.record).Elem()
+
+	for i, s := range values {
+		idx := d.fields[i]
+		if idx < 0 || s == "" || s == "--" {
+			continue
+		}
+		if err := setter.SetString(v.Field(idx), s); err != nil {
+			d.err = fmt.Errorf("row %d: %s: %w", d.row, d.header[i], err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// Record returns the row decoded by the last Next call.
+func (d *Decoder[T]) Record() *T {
+	return &d.record
+}
+
+// Err returns the first error occurred during decoding.
+func (d *Decoder[T]) Err() error {
+	return d.err
+}
+
+// DecodeAll reads all rows of the report.
+func DecodeAll[T any](r io.Reader) ([]T, error) {
+	d, err := NewDecoder[T](r)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []T
+	for d.Next() {
+		list = append(list, *d.Record())
+	}
+
+	return list, d.Err()
+}
@@ -0,0 +1,296 @@
+// Package report implements client of Xandr Report Service
+// described on https://learn.microsoft.com/en-us/xandr/digital-platform-api/report-service
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/milla-v/xandr/api"
+)
+
+// Type is a report_type.
+type Type string
+
+const (
+	TypeSegmentLoad             Type = "segment_load"
+	TypeBuyerSegmentPerformance Type = "buyer_segment_performance"
+	TypeNetworkAnalytics        Type = "network_analytics"
+)
+
+// Interval is a report_interval relative to the current day in the report timezone.
+type Interval string
+
+const (
+	IntervalToday       Interval = "today"
+	IntervalYesterday   Interval = "yesterday"
+	IntervalLast7Days   Interval = "last_7_days"
+	IntervalLast30Days  Interval = "last_30_days"
+	IntervalMonthToDate Interval = "month_to_date"
+	IntervalLastMonth   Interval = "last_month"
+)
+
+// Execution statuses returned by Status.
+const (
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusError   = "error"
+)
+
+const dateLayout = "2006-01-02"
+
+// DefaultPollInterval is used by Wait when Client.PollInterval is not set.
+const DefaultPollInterval = 5 * time.Second
+
+// ErrFailed is returned when the report service fails to run the report.
+var ErrFailed = errors.New("report failed")
+
+// Filter limits report rows to the column values.
+type Filter struct {
+	Column string
+	Values []string
+}
+
+// MarshalJSON encodes the filter as {"column": values} object expected by the API.
+func (f Filter) MarshalJSON() ([]byte, error) {
+	if len(f.Values) == 1 {
+		return json.Marshal(map[string]string{f.Column: f.Values[0]})
+	}
+	return json.Marshal(map[string][]string{f.Column: f.Values})
+}
+
+// UnmarshalJSON decodes {"column": value} or {"column": [values]} object.
+func (f *Filter) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	if len(m) != 1 {
+		return fmt.Errorf("filter should have one column, got %d", len(m))
+	}
+
+	for col, raw := range m {
+		f.Column = col
+		f.Values = nil
+
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			f.Values = []string{s}
+			return nil
+		}
+
+		return json.Unmarshal(raw, &f.Values)
+	}
+
+	return nil
+}
+
+// Request is a report request. Either Interval or Start and End dates should be set.
+type Request struct {
+	Type      Type     `json:"report_type"`
+	Columns   []string `json:"columns"`
+	Filters   []Filter `json:"filters,omitempty"`
+	Interval  Interval `json:"report_interval,omitempty"`
+	StartDate string   `json:"start_date,omitempty"` // inclusive, YYYY-MM-DD
+	EndDate   string   `json:"end_date,omitempty"`   // exclusive, YYYY-MM-DD
+	Timezone  string   `json:"timezone,omitempty"`
+	OrderBy   []string `json:"orders,omitempty"`
+	Format    string   `json:"format"`
+}
+
+// NewRequest creates CSV report request of the type for the interval.
+func NewRequest(t Type, interval Interval, columns ...string) *Request {
+	return &Request{
+		Type:     t,
+		Columns:  columns,
+		Interval: interval,
+		Format:   "csv",
+	}
+}
+
+// Between sets report dates from start inclusive to end exclusive and clears the interval.
+func (r *Request) Between(start, end time.Time) *Request {
+	r.Interval = ""
+	r.StartDate = start.Format(dateLayout)
+	r.EndDate = end.Format(dateLayout)
+	return r
+}
+
+// Where adds filter of the column.
+func (r *Request) Where(column string, values ...string) *Request {
+	r.Filters = append(r.Filters, Filter{Column: column, Values: values})
+	return r
+}
+
+// Validate checks the request before submission.
+func (r *Request) Validate() error {
+	if r.Type == "" {
+		return errors.New("report type is required")
+	}
+
+	if len(r.Columns) == 0 {
+		return errors.New("columns are required")
+	}
+
+	if r.Format != "" && r.Format != "csv" {
+		return fmt.Errorf("unsupported format: %s", r.Format)
+	}
+
+	if r.Interval != "" && (r.StartDate != "" || r.EndDate != "") {
+		return errors.New("report interval and dates are mutually exclusive")
+	}
+
+	if r.Interval == "" {
+		if r.StartDate == "" || r.EndDate == "" {
+			return errors.New("report interval or start and end dates are required")
+		}
+
+		start, err := time.Parse(dateLayout, r.StartDate)
+		if err != nil {
+			return fmt.Errorf("invalid start_date: %w", err)
+		}
+
+		end, err := time.Parse(dateLayout, r.EndDate)
+		if err != nil {
+			return fmt.Errorf("invalid end_date: %w", err)
+		}
+
+		if !start.Before(end) {
+			return fmt.Errorf("start_date %s is not before end_date %s", r.StartDate, r.EndDate)
+		}
+	}
+
+	for _, f := range r.Filters {
+		if f.Column == "" || len(f.Values) == 0 {
+			return fmt.Errorf("invalid filter: %+v", f)
+		}
+	}
+
+	return nil
+}
+
+// Status is the execution status of a submitted report.
+type Status struct {
+	ExecutionStatus string `json:"execution_status"`
+	Report          struct {
+		ID        string `json:"id"`
+		Created   string `json:"created_on"`
+		RowCount  string `json:"row_count"`
+		URL       string `json:"url"`
+		ErrorText string `json:"error_text"`
+	} `json:"report"`
+}
+
+// Client calls Report Service using authenticated session.
+type Client struct {
+	PollInterval time.Duration
+
+	s *api.Session
+}
+
+// NewClient creates report client sharing the session with other clients.
+func NewClient(s *api.Session) *Client {
+	return &Client{s: s, PollInterval: DefaultPollInterval}
+}
+
+// Submit requests report for the member and returns report ID.
+func (c *Client) Submit(ctx context.Context, memberID int32, req *Request) (string, error) {
+	if err := req.Validate(); err != nil {
+		return "", err
+	}
+
+	q := url.Values{"member_id": {fmt.Sprint(memberID)}}
+
+	var resp struct {
+		ReportID string `json:"report_id"`
+	}
+
+	if err := c.s.Do(ctx, http.MethodPost, "/report", q, map[string]interface{}{"report": req}, &resp); err != nil {
+		return "", fmt.Errorf("submit %s report: %w", req.Type, err)
+	}
+
+	if resp.ReportID == "" {
+		return "", fmt.Errorf("submit %s report: response has no report_id", req.Type)
+	}
+
+	return resp.ReportID, nil
+}
+
+// Status returns execution status of the report.
+func (c *Client) Status(ctx context.Context, id string) (*Status, error) {
+	q := url.Values{"id": {id}}
+
+	var st Status
+	if err := c.s.Do(ctx, http.MethodGet, "/report", q, nil, &st); err != nil {
+		return nil, fmt.Errorf("report %s status: %w", id, err)
+	}
+
+	return &st, nil
+}
+
+// Wait polls report status until the report is ready or failed.
+func (c *Client) Wait(ctx context.Context, id string) error {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	for {
+		st, err := c.Status(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		switch st.ExecutionStatus {
+		case StatusReady:
+			return nil
+		case StatusError:
+			return fmt.Errorf("report %s: %w: %s", id, ErrFailed, st.Report.ErrorText)
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Download returns CSV data of the ready report. The caller should close the returned reader.
+func (c *Client) Download(ctx context.Context, id string) (io.ReadCloser, error) {
+	resp, err := c.s.DoRaw(ctx, http.MethodGet, "/report-download", url.Values{"id": {id}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download report %s: status %s", id, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// Run submits the report, waits until it is ready and returns its CSV data.
+// The caller should close the returned reader.
+func (c *Client) Run(ctx context.Context, memberID int32, req *Request) (io.ReadCloser, error) {
+	id, err := c.Submit(ctx, memberID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Wait(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return c.Download(ctx, id)
+}
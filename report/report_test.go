@@ -0,0 +1,118 @@
+package report_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/report"
+	"github.com/milla-v/xandr/report/reporttest"
+)
+
+const segmentLoadCSV = `day,segment_id,segment_name,segment_code,total_loads,daily_uniques,monthly_uniques,member_id
+2023-05-04,100,Sports,sports,"1,250",1000,5000,7
+2023-05-05,101,Travel,,300,--,900,7
+`
+
+func TestRun(t *testing.T) {
+	srv := reporttest.NewServer()
+	defer srv.Close()
+
+	srv.PendingPolls = 2
+	srv.SetData(report.TypeSegmentLoad, segmentLoadCSV)
+
+	s := api.NewSession(srv.URL)
+	ctx := context.Background()
+
+	if err := s.Login(ctx, "user", reporttest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	c := report.NewClient(s)
+	c.PollInterval = time.Millisecond
+
+	req := report.NewRequest(report.TypeSegmentLoad, report.IntervalLast7Days, report.SegmentLoadColumns...).
+		Where("segment_id", "100", "101")
+
+	r, err := c.Run(ctx, 7, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	rows, err := report.DecodeAll[report.SegmentLoad](r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []report.SegmentLoad{
+		{
+			Day:            time.Date(2023, 5, 4, 0, 0, 0, 0, time.UTC),
+			SegmentID:      100,
+			SegmentName:    "Sports",
+			SegmentCode:    "sports",
+			TotalLoads:     1250,
+			DailyUniques:   1000,
+			MonthlyUniques: 5000,
+		},
+		{
+			Day:            time.Date(2023, 5, 5, 0, 0, 0, 0, time.UTC),
+			SegmentID:      101,
+			SegmentName:    "Travel",
+			TotalLoads:     300,
+			MonthlyUniques: 900,
+		},
+	}
+
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, rows)
+	}
+
+	submitted := srv.Requests()
+	if len(submitted) != 1 || !reflect.DeepEqual(submitted[0].Filters, req.Filters) {
+		t.Fatalf("invalid submitted requests: %+v", submitted)
+	}
+
+	_, err = c.Run(ctx, 7, report.NewRequest(report.TypeNetworkAnalytics, report.IntervalToday, "imps"))
+	if !errors.Is(err, report.ErrFailed) {
+		t.Fatal("expected failed report, got", err)
+	}
+}
+
+func TestRequestValidate(t *testing.T) {
+	start := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	req := report.NewRequest(report.TypeSegmentLoad, "", "day").Between(start, start.AddDate(0, 0, 7))
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	req = report.NewRequest(report.TypeSegmentLoad, "", "day").Between(start, start)
+	if err := req.Validate(); err == nil || !strings.Contains(err.Error(), "is not before") {
+		t.Fatal("expected date range error, got", err)
+	}
+
+	req = report.NewRequest(report.TypeSegmentLoad, report.IntervalToday)
+	if err := req.Validate(); err == nil {
+		t.Fatal("expected columns error")
+	}
+}
+
+func TestDecoderError(t *testing.T) {
+	d, err := report.NewDecoder[report.SegmentLoad](strings.NewReader("segment_id,total_loads\n100,x\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Next() {
+		t.Fatal("expected decoding error")
+	}
+
+	if err := d.Err(); err == nil || !strings.HasPrefix(err.Error(), "row 1: total_loads:") {
+		t.Fatal("unexpected error:", err)
+	}
+}
@@ -0,0 +1,151 @@
+// Package reporttest provides a fake Report Service for testing.
+package reporttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/milla-v/xandr/api/apitest"
+	"github.com/milla-v/xandr/report"
+)
+
+// Password accepted by the fake server.
+const Password = "secret"
+
+type job struct {
+	req   report.Request
+	polls int
+}
+
+// Server is a fake Report Service. Reports become ready after PendingPolls status requests
+// and return CSV data set by SetData for the report type.
+type Server struct {
+	*apitest.Server
+
+	PendingPolls int
+
+	mu   sync.Mutex
+	data map[report.Type]string
+	jobs map[string]*job
+	next int
+}
+
+// NewServer starts fake Report Service.
+func NewServer() *Server {
+	s := &Server{
+		Server:       apitest.NewServer(Password),
+		PendingPolls: 1,
+		data:         make(map[report.Type]string),
+		jobs:         make(map[string]*job),
+	}
+
+	s.Handle("/report", s.handleReport)
+	s.Handle("/report-download", s.handleDownload)
+
+	return s
+}
+
+// SetData sets CSV data returned for reports of the type. Reports of other types fail.
+func (s *Server) SetData(t report.Type, csv string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[t] = csv
+}
+
+// Requests returns submitted report requests.
+func (s *Server) Requests() []report.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]report.Request, 0, len(s.jobs))
+	for i := 1; i <= s.next; i++ {
+		list = append(list, s.jobs[fmt.Sprint(i)].req)
+	}
+
+	return list
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		s.submit(w, r)
+	case http.MethodGet:
+		s.status(w, r.URL.Query().Get("id"))
+	default:
+		apitest.WriteError(w, http.StatusMethodNotAllowed, "SYNTAX", "method not allowed")
+	}
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Report *report.Request `json:"report"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Report == nil {
+		apitest.WriteError(w, http.StatusBadRequest, "SYNTAX", "invalid report object")
+		return
+	}
+
+	if r.URL.Query().Get("member_id") == "" {
+		apitest.WriteError(w, http.StatusBadRequest, "SYNTAX", "member_id is required")
+		return
+	}
+
+	s.mu.Lock()
+	s.next++
+	id := fmt.Sprint(s.next)
+	s.jobs[id] = &job{req: *req.Report}
+	s.mu.Unlock()
+
+	apitest.WriteResponse(w, map[string]interface{}{"report_id": id})
+}
+
+func (s *Server) status(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		apitest.WriteError(w, http.StatusNotFound, "NOTFOUND", "report not found")
+		return
+	}
+
+	j.polls++
+
+	st := report.StatusPending
+	rep := map[string]interface{}{"id": id}
+
+	switch _, ok := s.data[j.req.Type]; {
+	case !ok:
+		st = report.StatusError
+		rep["error_text"] = "unsupported report type " + string(j.req.Type)
+	case j.polls > s.PendingPolls:
+		st = report.StatusReady
+		rep["url"] = "report-download?id=" + id
+	}
+
+	apitest.WriteResponse(w, map[string]interface{}{"execution_status": st, "report": rep})
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	ready := ok && j.polls > s.PendingPolls
+	data := ""
+	if ready {
+		data = s.data[j.req.Type]
+	}
+	s.mu.Unlock()
+
+	if !ready {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	fmt.Fprint(w, data)
+}
@@ -0,0 +1,45 @@
+package report
+
+import "time"
+
+// SegmentLoad is a row of segment_load report.
+type SegmentLoad struct {
+	Day            time.Time `csv:"day"`
+	SegmentID      int32     `csv:"segment_id"`
+	SegmentName    string    `csv:"segment_name"`
+	SegmentCode    string    `csv:"segment_code"`
+	TotalLoads     int64     `csv:"total_loads"`
+	DailyUniques   int64     `csv:"daily_uniques"`
+	MonthlyUniques int64     `csv:"monthly_uniques"`
+}
+
+// SegmentLoadColumns are the columns of SegmentLoad.
+var SegmentLoadColumns = []string{
+	"day",
+	"segment_id",
+	"segment_name",
+	"segment_code",
+	"total_loads",
+	"daily_uniques",
+	"monthly_uniques",
+}
+
+// SegmentPerformance is a row of buyer_segment_performance report.
+type SegmentPerformance struct {
+	Day         time.Time `csv:"day"`
+	SegmentID   int32     `csv:"segment_id"`
+	SegmentName string    `csv:"segment_name"`
+	Imps        int64     `csv:"imps"`
+	Clicks      int64     `csv:"clicks"`
+	Spend       float64   `csv:"media_cost"`
+}
+
+// SegmentPerformanceColumns are the columns of SegmentPerformance.
+var SegmentPerformanceColumns = []string{
+	"day",
+	"segment_id",
+	"segment_name",
+	"imps",
+	"clicks",
+	"media_cost",
+}
@@ -0,0 +1,152 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+)
+
+// SegmentLoads returns daily segment loads sorted by day keyed by segment ID.
+// Empty segmentIDs returns loads of all segments of the member.
+func (c *Client) SegmentLoads(ctx context.Context, memberID int32, interval Interval, segmentIDs ...int32) (map[int32][]SegmentLoad, error) {
+	req := NewRequest(TypeSegmentLoad, interval, SegmentLoadColumns...)
+	if len(segmentIDs) > 0 {
+		ids := make([]string, len(segmentIDs))
+		for i, id := range segmentIDs {
+			ids[i] = strconv.Itoa(int(id))
+		}
+		req.Where("segment_id", ids...)
+	}
+
+	r, err := c.Run(ctx, memberID, req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	d, err := NewDecoder[SegmentLoad](r)
+	if err != nil {
+		return nil, err
+	}
+
+	loads := make(map[int32][]SegmentLoad)
+	for d.Next() {
+		row := d.Record()
+		loads[row.SegmentID] = append(loads[row.SegmentID], *row)
+	}
+
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, list := range loads {
+		sort.Slice(list, func(i, j int) bool { return list[i].Day.Before(list[j].Day) })
+	}
+
+	return loads, nil
+}
+
+// SegmentTotalLoads returns number of users loaded to segments during the interval keyed by segment ID.
+// It is the sum of daily total loads, so it counts users added by uploads of the interval rather than
+// the segment population, which includes users added before and excludes expired ones.
+func (c *Client) SegmentTotalLoads(ctx context.Context, memberID int32, interval Interval, segmentIDs ...int32) (map[int32]int64, error) {
+	loads, err := c.SegmentLoads(ctx, memberID, interval, segmentIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[int32]int64, len(loads))
+	for id, list := range loads {
+		for _, row := range list {
+			totals[id] += row.TotalLoads
+		}
+	}
+
+	return totals, nil
+}
+
+// UploadedUsers counts users added to segments by BSS records keyed by segment ID.
+// Removals and segments without ID are not counted.
+func UploadedUsers(r bss.RecordReader) (map[int32]int64, error) {
+	users := make(map[int32]int64)
+
+	for {
+		ur, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, seg := range ur.Segments {
+			if seg.ID != 0 && seg.Expiration != xgen.Expired {
+				users[seg.ID]++
+			}
+		}
+	}
+
+	return users, nil
+}
+
+// Discrepancy is a segment where loaded number of users differs from uploaded.
+type Discrepancy struct {
+	SegmentID int32
+	Uploaded  int64
+	Loaded    int64
+	Diff      float64 // (Loaded - Uploaded) / Uploaded, +Inf if nothing was uploaded
+}
+
+// Compare returns segments where the relative difference between uploaded and loaded users
+// exceeds threshold, e.g. 0.1 for 10%. Result is sorted by segment ID.
+func Compare(uploaded, loaded map[int32]int64, threshold float64) []Discrepancy {
+	ids := make(map[int32]bool)
+	for id := range uploaded {
+		ids[id] = true
+	}
+	for id := range loaded {
+		ids[id] = true
+	}
+
+	var list []Discrepancy
+
+	for id := range ids {
+		d := Discrepancy{SegmentID: id, Uploaded: uploaded[id], Loaded: loaded[id]}
+
+		switch {
+		case d.Uploaded == d.Loaded:
+			continue
+		case d.Uploaded == 0:
+			d.Diff = math.Inf(1)
+		default:
+			d.Diff = float64(d.Loaded-d.Uploaded) / float64(d.Uploaded)
+		}
+
+		if math.Abs(d.Diff) > threshold {
+			list = append(list, d)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].SegmentID < list[j].SegmentID })
+
+	return list
+}
+
+// WriteDiscrepancies writes discrepancies as a table.
+func WriteDiscrepancies(w io.Writer, list []Discrepancy) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintf(tw, "segment\tuploaded\tloaded\tdiff\t\n")
+	for _, d := range list {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%+.1f%%\t\n", d.SegmentID, d.Uploaded, d.Loaded, d.Diff*100)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,77 @@
+package report_test
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/report"
+	"github.com/milla-v/xandr/report/reporttest"
+)
+
+func TestCompareUploaded(t *testing.T) {
+	srv := reporttest.NewServer()
+	defer srv.Close()
+
+	srv.SetData(report.TypeSegmentLoad, `day,segment_id,total_loads,monthly_uniques
+2023-05-05,100,490,5000
+2023-05-04,100,500,4500
+2023-05-05,101,10,3000
+2023-05-05,103,5,100
+`)
+
+	s := api.NewSession(srv.URL)
+	ctx := context.Background()
+
+	if err := s.Login(ctx, "user", reporttest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	c := report.NewClient(s)
+	c.PollInterval = time.Millisecond
+
+	loaded, err := c.SegmentTotalLoads(ctx, 7, report.IntervalYesterday, 100, 101, 103)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded[100] != 990 || loaded[101] != 10 {
+		t.Fatalf("invalid total loads: %v", loaded)
+	}
+
+	if f := srv.Requests()[0].Filters; len(f) != 1 || len(f[0].Values) != 3 {
+		t.Fatalf("invalid filters: %+v", f)
+	}
+
+	var sb strings.Builder
+	for i := 0; i < 1000; i++ {
+		sb.WriteString("1234567890:100;101;102#104\n")
+	}
+
+	dr, err := bss.NewSegmentDataReader(strings.NewReader(sb.String()), bss.FormatText, &xgen.MinimalFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded, err := report.UploadedUsers(dr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []report.Discrepancy{
+		{SegmentID: 101, Uploaded: 1000, Loaded: 10, Diff: -0.99},
+		{SegmentID: 102, Uploaded: 1000, Loaded: 0, Diff: -1},
+		{SegmentID: 103, Uploaded: 0, Loaded: 5, Diff: math.Inf(1)},
+	}
+
+	list := report.Compare(uploaded, loaded, 0.05)
+	if !reflect.DeepEqual(list, expected) {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", expected, list)
+	}
+}
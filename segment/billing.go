@@ -0,0 +1,89 @@
+package segment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// BillingCategory is a data marketplace pricing object of the Segment Billing Category Service
+// described on https://learn.microsoft.com/en-us/xandr/digital-platform-api/segment-billing-category-service
+type BillingCategory struct {
+	ID               int32   `json:"id,omitempty"`
+	SegmentID        int32   `json:"segment_id"`
+	DataProviderID   int32   `json:"data_provider_id"`
+	DataCategoryID   int32   `json:"data_category_id,omitempty"`
+	DataSegmentPrice float64 `json:"data_segment_price"` // CPM
+	IsPublic         bool    `json:"is_public"`
+	Active           bool    `json:"active"`
+}
+
+type billingResponse struct {
+	Category *BillingCategory `json:"segment-billing-category"`
+}
+
+// CreateBillingCategory creates pricing of a segment.
+func (c *Client) CreateBillingCategory(ctx context.Context, memberID int32, bc *BillingCategory) (*BillingCategory, error) {
+	q := url.Values{"member_id": {itoa(memberID)}}
+	req := map[string]interface{}{"segment-billing-category": bc}
+
+	var resp billingResponse
+	if err := c.s.Do(ctx, http.MethodPost, "/segment-billing-category", q, req, &resp); err != nil {
+		return nil, fmt.Errorf("create billing category of segment %d: %w", bc.SegmentID, err)
+	}
+
+	return resp.category()
+}
+
+// UpdateBillingCategory modifies pricing with bc.ID.
+func (c *Client) UpdateBillingCategory(ctx context.Context, memberID int32, bc *BillingCategory) (*BillingCategory, error) {
+	q := url.Values{"member_id": {itoa(memberID)}, "id": {itoa(bc.ID)}}
+	req := map[string]interface{}{"segment-billing-category": bc}
+
+	var resp billingResponse
+	if err := c.s.Do(ctx, http.MethodPut, "/segment-billing-category", q, req, &resp); err != nil {
+		return nil, fmt.Errorf("update billing category %d: %w", bc.ID, err)
+	}
+
+	return resp.category()
+}
+
+// ListBillingCategories returns all member's segment pricing objects.
+func (c *Client) ListBillingCategories(ctx context.Context, memberID int32) ([]BillingCategory, error) {
+	var list []BillingCategory
+
+	for start := 0; ; {
+		q := url.Values{
+			"member_id":     {itoa(memberID)},
+			"start_element": {strconv.Itoa(start)},
+			"num_elements":  {strconv.Itoa(MaxPageSize)},
+		}
+
+		var page struct {
+			Categories []BillingCategory `json:"segment-billing-categories"`
+			Count      int               `json:"count"`
+		}
+
+		if err := c.s.Do(ctx, http.MethodGet, "/segment-billing-category", q, nil, &page); err != nil {
+			return nil, fmt.Errorf("list billing categories: %w", err)
+		}
+
+		list = append(list, page.Categories...)
+		start += len(page.Categories)
+
+		if len(page.Categories) == 0 || start >= page.Count {
+			break
+		}
+	}
+
+	return list, nil
+}
+
+func (r *billingResponse) category() (*BillingCategory, error) {
+	if r.Category == nil {
+		return nil, fmt.Errorf("response has no segment-billing-category")
+	}
+	return r.Category, nil
+}
@@ -0,0 +1,189 @@
+// Package segment implements client of Xandr Segment Service
+// described on https://learn.microsoft.com/en-us/xandr/digital-platform-api/segment-service
+package segment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/bss"
+)
+
+const (
+	StateActive   = "active"
+	StateInactive = "inactive"
+
+	// MaxPageSize is the maximum number of elements the API returns in one page.
+	MaxPageSize = 100
+)
+
+// Segment is a segment object of the Segment Service.
+type Segment struct {
+	ID            int32  `json:"id,omitempty" yaml:"id,omitempty"`
+	Code          string `json:"code,omitempty" yaml:"code,omitempty"`
+	ShortName     string `json:"short_name,omitempty" yaml:"short_name,omitempty"`
+	Description   string `json:"description,omitempty" yaml:"description,omitempty"`
+	MemberID      int32  `json:"member_id,omitempty" yaml:"member_id,omitempty"`
+	ExpireMinutes int32  `json:"expire_minutes,omitempty" yaml:"expire_minutes,omitempty"`
+	Category      string `json:"category,omitempty" yaml:"category,omitempty"`
+	State         string `json:"state,omitempty" yaml:"state,omitempty"`
+	LastModified  string `json:"last_modified,omitempty" yaml:"last_modified,omitempty"`
+}
+
+// Page is a page of segments returned by List.
+type Page struct {
+	Segments     []Segment `json:"segments"`
+	Count        int       `json:"count"`
+	StartElement int       `json:"start_element"`
+	NumElements  int       `json:"num_elements"`
+}
+
+// Client calls Segment Service using authenticated session.
+type Client struct {
+	s *api.Session
+}
+
+// NewClient creates segment client sharing the session with other clients.
+func NewClient(s *api.Session) *Client {
+	return &Client{s: s}
+}
+
+type segmentResponse struct {
+	Segment *Segment `json:"segment"`
+}
+
+// Create creates segment owned by the member and returns created segment.
+func (c *Client) Create(ctx context.Context, memberID int32, seg *Segment) (*Segment, error) {
+	q := url.Values{"member_id": {itoa(memberID)}}
+	req := map[string]interface{}{"segment": seg}
+
+	var resp segmentResponse
+	if err := c.s.Do(ctx, http.MethodPost, "/segment", q, req, &resp); err != nil {
+		return nil, fmt.Errorf("create segment %s: %w", seg.Code, err)
+	}
+
+	return resp.segment()
+}
+
+// Get returns segment by ID.
+func (c *Client) Get(ctx context.Context, id int32) (*Segment, error) {
+	q := url.Values{"id": {itoa(id)}}
+
+	var resp segmentResponse
+	if err := c.s.Do(ctx, http.MethodGet, "/segment", q, nil, &resp); err != nil {
+		return nil, fmt.Errorf("get segment %d: %w", id, err)
+	}
+
+	return resp.segment()
+}
+
+// List returns a page of member's segments starting from start element. Page size is limited by MaxPageSize.
+func (c *Client) List(ctx context.Context, memberID int32, start, num int) (*Page, error) {
+	if num <= 0 || num > MaxPageSize {
+		num = MaxPageSize
+	}
+
+	q := url.Values{
+		"member_id":     {itoa(memberID)},
+		"start_element": {strconv.Itoa(start)},
+		"num_elements":  {strconv.Itoa(num)},
+	}
+
+	var page Page
+	if err := c.s.Do(ctx, http.MethodGet, "/segment", q, nil, &page); err != nil {
+		return nil, fmt.Errorf("list segments: %w", err)
+	}
+
+	return &page, nil
+}
+
+// ListAll returns all member's segments reading them page by page.
+func (c *Client) ListAll(ctx context.Context, memberID int32) ([]Segment, error) {
+	var list []Segment
+
+	for start := 0; ; {
+		page, err := c.List(ctx, memberID, start, MaxPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, page.Segments...)
+		start += len(page.Segments)
+
+		if len(page.Segments) == 0 || start >= page.Count {
+			break
+		}
+	}
+
+	return list, nil
+}
+
+// Update modifies segment with seg.ID. Only non-empty fields are changed.
+func (c *Client) Update(ctx context.Context, seg *Segment) (*Segment, error) {
+	if seg.ID == 0 {
+		return nil, fmt.Errorf("update segment %s: id is not set", seg.Code)
+	}
+
+	q := url.Values{"id": {itoa(seg.ID)}}
+	req := map[string]interface{}{"segment": seg}
+
+	var resp segmentResponse
+	if err := c.s.Do(ctx, http.MethodPut, "/segment", q, req, &resp); err != nil {
+		return nil, fmt.Errorf("update segment %d: %w", seg.ID, err)
+	}
+
+	return resp.segment()
+}
+
+// Delete deletes segment by ID.
+func (c *Client) Delete(ctx context.Context, id int32) error {
+	q := url.Values{"id": {itoa(id)}}
+
+	if err := c.s.Do(ctx, http.MethodDelete, "/segment", q, nil, nil); err != nil {
+		return fmt.Errorf("delete segment %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// Mappings returns bss.MappingSource which loads code to ID mapping of member's segments.
+func (c *Client) Mappings(memberID int32) bss.MappingSource {
+	return &mappingSource{c: c, memberID: memberID}
+}
+
+type mappingSource struct {
+	c        *Client
+	memberID int32
+}
+
+func (ms *mappingSource) LoadMappings() ([]bss.SegmentMapping, error) {
+	list, err := ms.c.ListAll(context.Background(), ms.memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings []bss.SegmentMapping
+	for _, seg := range list {
+		if seg.Code == "" {
+			continue
+		}
+		mappings = append(mappings, bss.SegmentMapping{ID: seg.ID, Code: seg.Code, MemberID: seg.MemberID})
+	}
+
+	return mappings, nil
+}
+
+func (r *segmentResponse) segment() (*Segment, error) {
+	if r.Segment == nil {
+		return nil, fmt.Errorf("response has no segment")
+	}
+	return r.Segment, nil
+}
+
+func itoa(n int32) string {
+	return strconv.FormatInt(int64(n), 10)
+}
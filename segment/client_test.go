@@ -0,0 +1,99 @@
+package segment_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/bss"
+	"github.com/milla-v/xandr/bss/xgen"
+	"github.com/milla-v/xandr/segment"
+	"github.com/milla-v/xandr/segment/segmenttest"
+)
+
+func newClient(t *testing.T) (*segment.Client, *segmenttest.Server) {
+	srv := segmenttest.NewServer()
+	t.Cleanup(srv.Close)
+
+	s := api.NewSession(srv.URL)
+	if err := s.Login(context.Background(), "user", segmenttest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	return segment.NewClient(s), srv
+}
+
+func TestClientCRUD(t *testing.T) {
+	c, srv := newClient(t)
+	ctx := context.Background()
+
+	created, err := c.Create(ctx, 7, &segment.Segment{Code: "auto", ShortName: "Auto intenders", ExpireMinutes: 1440})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.ID == 0 || created.MemberID != 7 || created.State != segment.StateActive {
+		t.Fatalf("invalid created segment: %+v", created)
+	}
+
+	seg, err := c.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *seg != *created {
+		t.Fatalf("\nexpected: %+v\nactual  : %+v", created, seg)
+	}
+
+	updated, err := c.Update(ctx, &segment.Segment{ID: seg.ID, State: segment.StateInactive})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.State != segment.StateInactive || updated.Code != "auto" {
+		t.Fatalf("invalid updated segment: %+v", updated)
+	}
+
+	if err := c.Delete(ctx, seg.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(srv.Segments()) != 0 {
+		t.Fatal("segment should be deleted")
+	}
+
+	if _, err := c.Get(ctx, seg.ID); err == nil {
+		t.Fatal("should return error")
+	}
+}
+
+func TestClientListAll(t *testing.T) {
+	c, srv := newClient(t)
+
+	for i := 0; i < 250; i++ {
+		srv.Add(segment.Segment{Code: fmt.Sprintf("code%d", i), MemberID: 7})
+	}
+	other := srv.Add(segment.Segment{Code: "other", MemberID: 8})
+
+	list, err := c.ListAll(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 250 {
+		t.Fatal("expected 250 segments, got", len(list))
+	}
+
+	sr, err := bss.NewSegmentResolver(c.Mappings(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ur := &xgen.UserRecord{UID: "12345", Segments: []xgen.Segment{{Code: "other", MemberID: 8}}}
+	sr.ToID(ur)
+
+	if ur.Segments[0].ID != other.ID {
+		t.Fatalf("segment is not resolved: %+v", ur.Segments[0])
+	}
+}
@@ -0,0 +1,305 @@
+// Package segmenttest provides a fake Segment Service for testing.
+package segmenttest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/milla-v/xandr/api/apitest"
+	"github.com/milla-v/xandr/segment"
+)
+
+// Password accepted by the fake server.
+const Password = "secret"
+
+// Server is a fake Segment Service keeping segments in memory.
+type Server struct {
+	*apitest.Server
+
+	mu       sync.Mutex
+	segments map[int32]segment.Segment
+	billing  map[int32]segment.BillingCategory
+	nextID   int32
+}
+
+// NewServer starts fake Segment Service.
+func NewServer() *Server {
+	s := &Server{
+		Server:   apitest.NewServer(Password),
+		segments: make(map[int32]segment.Segment),
+		billing:  make(map[int32]segment.BillingCategory),
+		nextID:   1000,
+	}
+
+	s.Handle("/segment", s.handleSegment)
+	s.Handle("/segment-billing-category", s.handleBilling)
+
+	return s
+}
+
+// Add stores segment as if it was created by the API.
+func (s *Server) Add(seg segment.Segment) segment.Segment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if seg.ID == 0 {
+		s.nextID++
+		seg.ID = s.nextID
+	}
+	if seg.State == "" {
+		seg.State = segment.StateActive
+	}
+
+	s.segments[seg.ID] = seg
+
+	return seg
+}
+
+// Segments returns stored segments sorted by ID.
+func (s *Server) Segments() []segment.Segment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]segment.Segment, 0, len(s.segments))
+	for _, seg := range s.segments {
+		list = append(list, seg)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list
+}
+
+func (s *Server) handleSegment(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	id, _ := strconv.ParseInt(q.Get("id"), 10, 32)
+	memberID, _ := strconv.ParseInt(q.Get("member_id"), 10, 32)
+
+	switch {
+	case r.Method == http.MethodGet && id != 0:
+		s.get(w, int32(id))
+	case r.Method == http.MethodGet:
+		s.list(w, int32(memberID), q.Get("start_element"), q.Get("num_elements"))
+	case r.Method == http.MethodPost:
+		s.create(w, r, int32(memberID))
+	case r.Method == http.MethodPut:
+		s.update(w, r, int32(id))
+	case r.Method == http.MethodDelete:
+		s.delete(w, int32(id))
+	default:
+		apitest.WriteError(w, http.StatusMethodNotAllowed, "SYNTAX", "method not allowed")
+	}
+}
+
+func (s *Server) get(w http.ResponseWriter, id int32) {
+	s.mu.Lock()
+	seg, ok := s.segments[id]
+	s.mu.Unlock()
+
+	if !ok {
+		apitest.WriteError(w, http.StatusNotFound, "NOTFOUND", "segment not found")
+		return
+	}
+
+	apitest.WriteResponse(w, map[string]interface{}{"segment": seg})
+}
+
+func (s *Server) list(w http.ResponseWriter, memberID int32, startElement, numElements string) {
+	start, _ := strconv.Atoi(startElement)
+	num, _ := strconv.Atoi(numElements)
+	if num <= 0 || num > segment.MaxPageSize {
+		num = segment.MaxPageSize
+	}
+
+	var list []segment.Segment
+	for _, seg := range s.Segments() {
+		if seg.MemberID == memberID {
+			list = append(list, seg)
+		}
+	}
+
+	count := len(list)
+	if start > count {
+		start = count
+	}
+	end := start + num
+	if end > count {
+		end = count
+	}
+
+	apitest.WriteResponse(w, map[string]interface{}{
+		"segments":      list[start:end],
+		"count":         count,
+		"start_element": start,
+		"num_elements":  num,
+	})
+}
+
+func decodeSegment(w http.ResponseWriter, r *http.Request) (*segment.Segment, bool) {
+	var req struct {
+		Segment *segment.Segment `json:"segment"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Segment == nil {
+		apitest.WriteError(w, http.StatusBadRequest, "SYNTAX", "invalid segment object")
+		return nil, false
+	}
+
+	return req.Segment, true
+}
+
+func (s *Server) create(w http.ResponseWriter, r *http.Request, memberID int32) {
+	seg, ok := decodeSegment(w, r)
+	if !ok {
+		return
+	}
+
+	if memberID == 0 {
+		apitest.WriteError(w, http.StatusBadRequest, "SYNTAX", "member_id is required")
+		return
+	}
+
+	s.mu.Lock()
+	for _, other := range s.segments {
+		if seg.Code != "" && other.Code == seg.Code && other.MemberID == memberID {
+			s.mu.Unlock()
+			apitest.WriteError(w, http.StatusBadRequest, "SYNTAX", "code is already in use")
+			return
+		}
+	}
+	s.mu.Unlock()
+
+	seg.ID = 0
+	seg.MemberID = memberID
+	created := s.Add(*seg)
+
+	apitest.WriteResponse(w, map[string]interface{}{"id": created.ID, "segment": created})
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request, id int32) {
+	seg, ok := decodeSegment(w, r)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	stored, found := s.segments[id]
+	if found {
+		merge(&stored, seg)
+		s.segments[id] = stored
+	}
+	s.mu.Unlock()
+
+	if !found {
+		apitest.WriteError(w, http.StatusNotFound, "NOTFOUND", "segment not found")
+		return
+	}
+
+	apitest.WriteResponse(w, map[string]interface{}{"id": id, "segment": stored})
+}
+
+func (s *Server) delete(w http.ResponseWriter, id int32) {
+	s.mu.Lock()
+	_, found := s.segments[id]
+	delete(s.segments, id)
+	s.mu.Unlock()
+
+	if !found {
+		apitest.WriteError(w, http.StatusNotFound, "NOTFOUND", "segment not found")
+		return
+	}
+
+	apitest.WriteResponse(w, map[string]interface{}{})
+}
+
+// merge copies non-empty fields like the API does for PUT requests.
+func merge(dst, src *segment.Segment) {
+	if src.Code != "" {
+		dst.Code = src.Code
+	}
+	if src.ShortName != "" {
+		dst.ShortName = src.ShortName
+	}
+	if src.Description != "" {
+		dst.Description = src.Description
+	}
+	if src.ExpireMinutes != 0 {
+		dst.ExpireMinutes = src.ExpireMinutes
+	}
+	if src.Category != "" {
+		dst.Category = src.Category
+	}
+	if src.State != "" {
+		dst.State = src.State
+	}
+}
+
+// BillingCategories returns stored segment pricing sorted by ID.
+func (s *Server) BillingCategories() []segment.BillingCategory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]segment.BillingCategory, 0, len(s.billing))
+	for _, bc := range s.billing {
+		list = append(list, bc)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list
+}
+
+func (s *Server) handleBilling(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		list := s.BillingCategories()
+		apitest.WriteResponse(w, map[string]interface{}{
+			"segment-billing-categories": list,
+			"count":                      len(list),
+		})
+		return
+	}
+
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		apitest.WriteError(w, http.StatusMethodNotAllowed, "SYNTAX", "method not allowed")
+		return
+	}
+
+	var req struct {
+		Category *segment.BillingCategory `json:"segment-billing-category"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Category == nil {
+		apitest.WriteError(w, http.StatusBadRequest, "SYNTAX", "invalid segment-billing-category object")
+		return
+	}
+
+	bc := *req.Category
+	id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 32)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.segments[bc.SegmentID]; !ok {
+		apitest.WriteError(w, http.StatusBadRequest, "SYNTAX", "segment not found")
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		if _, ok := s.billing[int32(id)]; !ok {
+			apitest.WriteError(w, http.StatusNotFound, "NOTFOUND", "segment-billing-category not found")
+			return
+		}
+		bc.ID = int32(id)
+	} else {
+		s.nextID++
+		bc.ID = s.nextID
+	}
+
+	s.billing[bc.ID] = bc
+
+	apitest.WriteResponse(w, map[string]interface{}{"segment-billing-category": bc})
+}
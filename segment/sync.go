@@ -0,0 +1,265 @@
+package segment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Desired contains segment definitions which should exist for the member.
+// Segments are identified by code.
+type Desired struct {
+	MemberID int32     `json:"member_id" yaml:"member_id"`
+	Segments []Segment `json:"segments" yaml:"segments"`
+}
+
+// LoadDesired reads desired segments from YAML (.yaml, .yml) or JSON file.
+func LoadDesired(name string) (*Desired, error) {
+	var d Desired
+	if err := decodeFile(name, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// decodeFile decodes YAML (.yaml, .yml) or JSON file rejecting unknown fields.
+func decodeFile(name string, v interface{}) error {
+	buf, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(buf))
+		dec.KnownFields(true)
+		err = dec.Decode(v)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(v)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+type ActionType string
+
+const (
+	ActionCreate     ActionType = "create"
+	ActionUpdate     ActionType = "update"
+	ActionDeactivate ActionType = "deactivate"
+)
+
+// Change describes a field which differs between current and desired segment.
+type Change struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Action is a single step of the plan.
+type Action struct {
+	Type    ActionType
+	Code    string
+	Segment Segment // segment to create or fields to update
+	Changes []Change
+}
+
+// Plan contains actions required to reconcile member's segments with desired ones.
+type Plan struct {
+	MemberID int32
+	Actions  []Action
+}
+
+// MakePlan compares desired segments with current segments returned by the API.
+// Active segments with codes not present in desired are deactivated, never deleted.
+func MakePlan(desired *Desired, current []Segment) (*Plan, error) {
+	plan := &Plan{MemberID: desired.MemberID}
+
+	byCode := make(map[string]Segment)
+	for _, seg := range current {
+		if seg.Code != "" {
+			byCode[seg.Code] = seg
+		}
+	}
+
+	wanted := make(map[string]bool)
+
+	for _, d := range desired.Segments {
+		if d.Code == "" {
+			return nil, fmt.Errorf("desired segment %q has no code", d.ShortName)
+		}
+		if wanted[d.Code] {
+			return nil, fmt.Errorf("desired segment %s is defined twice", d.Code)
+		}
+		wanted[d.Code] = true
+
+		if d.State == "" {
+			d.State = StateActive
+		}
+
+		cur, ok := byCode[d.Code]
+		if !ok {
+			d.ID = 0
+			d.MemberID = 0
+			plan.Actions = append(plan.Actions, Action{Type: ActionCreate, Code: d.Code, Segment: d})
+			continue
+		}
+
+		update, changes := diffSegment(&cur, &d)
+		if len(changes) > 0 {
+			plan.Actions = append(plan.Actions, Action{Type: ActionUpdate, Code: d.Code, Segment: update, Changes: changes})
+		}
+	}
+
+	var stale []Segment
+	for _, seg := range current {
+		if seg.Code != "" && !wanted[seg.Code] && seg.State != StateInactive {
+			stale = append(stale, seg)
+		}
+	}
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Code < stale[j].Code })
+
+	for _, seg := range stale {
+		plan.Actions = append(plan.Actions, Action{
+			Type:    ActionDeactivate,
+			Code:    seg.Code,
+			Segment: Segment{ID: seg.ID, State: StateInactive},
+			Changes: []Change{{Field: "state", Old: seg.State, New: StateInactive}},
+		})
+	}
+
+	return plan, nil
+}
+
+// diffSegment returns update request with changed fields only.
+// Empty desired fields are not managed, the API ignores empty fields on update.
+func diffSegment(cur, d *Segment) (Segment, []Change) {
+	update := Segment{ID: cur.ID}
+
+	var changes []Change
+
+	str := func(field, old, new string, dst *string) {
+		if new != "" && old != new {
+			changes = append(changes, Change{Field: field, Old: strconv.Quote(old), New: strconv.Quote(new)})
+			*dst = new
+		}
+	}
+
+	str("short_name", cur.ShortName, d.ShortName, &update.ShortName)
+	str("description", cur.Description, d.Description, &update.Description)
+	str("category", cur.Category, d.Category, &update.Category)
+	str("state", cur.State, d.State, &update.State)
+
+	if d.ExpireMinutes != 0 && cur.ExpireMinutes != d.ExpireMinutes {
+		changes = append(changes, Change{
+			Field: "expire_minutes",
+			Old:   strconv.Itoa(int(cur.ExpireMinutes)),
+			New:   strconv.Itoa(int(d.ExpireMinutes)),
+		})
+		update.ExpireMinutes = d.ExpireMinutes
+	}
+
+	return update, changes
+}
+
+// Empty reports whether current segments already match desired ones.
+func (p *Plan) Empty() bool {
+	return len(p.Actions) == 0
+}
+
+// Count returns number of actions of the type.
+func (p *Plan) Count(t ActionType) int {
+	n := 0
+	for _, a := range p.Actions {
+		if a.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText writes the plan in a diff-like form suitable for code review.
+func (p *Plan) WriteText(w io.Writer) error {
+	for _, a := range p.Actions {
+		switch a.Type {
+		case ActionCreate:
+			fmt.Fprintf(w, "+ create %s\n", a.Code)
+			writeField(w, "short_name", a.Segment.ShortName)
+			writeField(w, "description", a.Segment.Description)
+			writeField(w, "category", a.Segment.Category)
+			if a.Segment.ExpireMinutes != 0 {
+				fmt.Fprintf(w, "    expire_minutes: %d\n", a.Segment.ExpireMinutes)
+			}
+		case ActionUpdate:
+			fmt.Fprintf(w, "~ update %s (id %d)\n", a.Code, a.Segment.ID)
+		case ActionDeactivate:
+			fmt.Fprintf(w, "- deactivate %s (id %d)\n", a.Code, a.Segment.ID)
+		}
+
+		if a.Type != ActionCreate {
+			for _, c := range a.Changes {
+				fmt.Fprintf(w, "    %s: %s -> %s\n", c.Field, c.Old, c.New)
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "plan: %d to create, %d to update, %d to deactivate\n",
+		p.Count(ActionCreate), p.Count(ActionUpdate), p.Count(ActionDeactivate))
+
+	return err
+}
+
+func writeField(w io.Writer, name, value string) {
+	if value != "" {
+		fmt.Fprintf(w, "    %s: %q\n", name, value)
+	}
+}
+
+// Sync loads current member's segments and returns plan to reconcile them with desired.
+func (c *Client) Sync(ctx context.Context, desired *Desired) (*Plan, error) {
+	current, err := c.ListAll(ctx, desired.MemberID)
+	if err != nil {
+		return nil, err
+	}
+
+	return MakePlan(desired, current)
+}
+
+// Apply executes plan actions in order. It stops on the first error.
+func (c *Client) Apply(ctx context.Context, plan *Plan) error {
+	for _, a := range plan.Actions {
+		seg := a.Segment
+
+		var err error
+
+		switch a.Type {
+		case ActionCreate:
+			_, err = c.Create(ctx, plan.MemberID, &seg)
+		case ActionUpdate, ActionDeactivate:
+			_, err = c.Update(ctx, &seg)
+		default:
+			err = fmt.Errorf("unknown action: %s", a.Type)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", a.Type, a.Code, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package segment_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/milla-v/xandr/segment"
+)
+
+func TestSync(t *testing.T) {
+	c, srv := newClient(t)
+	ctx := context.Background()
+
+	srv.Add(segment.Segment{Code: "auto", ShortName: "Auto", MemberID: 7, ExpireMinutes: 1440})
+	srv.Add(segment.Segment{Code: "old", ShortName: "Old", MemberID: 7})
+	srv.Add(segment.Segment{Code: "other", MemberID: 8})
+
+	const desiredYAML = `
+member_id: 7
+segments:
+  - code: auto
+    short_name: Auto intenders
+    expire_minutes: 1440
+  - code: travel
+    short_name: Travel
+    category: intent
+    expire_minutes: 10080
+`
+
+	name := filepath.Join(t.TempDir(), "segments.yaml")
+	if err := os.WriteFile(name, []byte(desiredYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	desired, err := segment.LoadDesired(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := c.Sync(ctx, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := plan.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = `~ update auto (id 1001)
+    short_name: "Auto" -> "Auto intenders"
++ create travel
+    short_name: "Travel"
+    category: "intent"
+    expire_minutes: 10080
+- deactivate old (id 1002)
+    state: active -> inactive
+plan: 1 to create, 1 to update, 1 to deactivate
+`
+
+	if out.String() != expected {
+		t.Fatal("\nexpected:\n" + expected + "\nactual:\n" + out.String())
+	}
+
+	if err := c.Apply(ctx, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = c.Sync(ctx, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !plan.Empty() {
+		out.Reset()
+		plan.WriteText(&out)
+		t.Fatal("second sync should be empty:\n" + out.String())
+	}
+}
+
+func TestMakePlanDuplicateCode(t *testing.T) {
+	desired := &segment.Desired{
+		MemberID: 7,
+		Segments: []segment.Segment{{Code: "auto"}, {Code: "auto"}},
+	}
+
+	_, err := segment.MakePlan(desired, nil)
+	if err == nil {
+		t.Fatal("should return error")
+	}
+
+	if err.Error() != "desired segment auto is defined twice" {
+		t.Fatal("invalid error message:", err.Error())
+	}
+}
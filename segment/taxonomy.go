@@ -0,0 +1,358 @@
+package segment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CategorySeparator joins category names into Segment.Category path.
+const CategorySeparator = " > "
+
+// Category is a node of segment taxonomy.
+type Category struct {
+	Code           string `json:"code" yaml:"code"`
+	Name           string `json:"name" yaml:"name"`
+	Parent         string `json:"parent,omitempty" yaml:"parent,omitempty"`                     // parent category code
+	DataCategoryID int32  `json:"data_category_id,omitempty" yaml:"data_category_id,omitempty"` // marketplace category, inherited by children
+}
+
+// Price is a data marketplace price of a segment for a data provider.
+type Price struct {
+	DataProviderID int32   `json:"data_provider_id" yaml:"data_provider_id"`
+	CPM            float64 `json:"cpm" yaml:"cpm"`
+	Public         bool    `json:"public,omitempty" yaml:"public,omitempty"`
+}
+
+// SegmentPricing contains prices of a segment identified by code.
+type SegmentPricing struct {
+	Code   string  `json:"code" yaml:"code"`
+	Prices []Price `json:"prices" yaml:"prices"`
+}
+
+// Taxonomy extends desired segments with category hierarchy and pricing.
+// Segment.Category refers to a category code.
+type Taxonomy struct {
+	Desired    `yaml:",inline"`
+	Categories []Category       `json:"categories" yaml:"categories"`
+	Pricing    []SegmentPricing `json:"pricing" yaml:"pricing"`
+}
+
+// LoadTaxonomy reads taxonomy from YAML (.yaml, .yml) or JSON file.
+func LoadTaxonomy(name string) (*Taxonomy, error) {
+	var t Taxonomy
+	if err := decodeFile(name, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validate checks the taxonomy locally: unknown and orphaned parents, cycles,
+// unknown segment categories and prices of unknown segments.
+func (t *Taxonomy) Validate() error {
+	cats := make(map[string]*Category)
+
+	for i := range t.Categories {
+		c := &t.Categories[i]
+		if c.Code == "" {
+			return fmt.Errorf("category[%d] has no code", i)
+		}
+		if _, ok := cats[c.Code]; ok {
+			return fmt.Errorf("category %s is defined twice", c.Code)
+		}
+		cats[c.Code] = c
+	}
+
+	for _, c := range t.Categories {
+		if c.Parent != "" && cats[c.Parent] == nil {
+			return fmt.Errorf("category %s has orphaned parent %s", c.Code, c.Parent)
+		}
+	}
+
+	for _, c := range t.Categories {
+		seen := map[string]bool{c.Code: true}
+		path := []string{c.Code}
+
+		for p := c.Parent; p != ""; p = cats[p].Parent {
+			path = append(path, p)
+			if seen[p] {
+				return fmt.Errorf("category cycle: %s", strings.Join(path, " -> "))
+			}
+			seen[p] = true
+		}
+	}
+
+	codes := make(map[string]bool)
+	for _, seg := range t.Segments {
+		codes[seg.Code] = true
+		if seg.Category != "" && cats[seg.Category] == nil {
+			return fmt.Errorf("segment %s has unknown category %s", seg.Code, seg.Category)
+		}
+	}
+
+	for _, sp := range t.Pricing {
+		if !codes[sp.Code] {
+			return fmt.Errorf("pricing of unknown segment %s", sp.Code)
+		}
+		for _, p := range sp.Prices {
+			if p.DataProviderID == 0 {
+				return fmt.Errorf("pricing of segment %s has no data_provider_id", sp.Code)
+			}
+			if p.CPM < 0 {
+				return fmt.Errorf("pricing of segment %s has negative cpm", sp.Code)
+			}
+		}
+	}
+
+	return nil
+}
+
+// categoryPath returns category names from root to the category and the nearest data category ID.
+func (t *Taxonomy) categoryPath(code string) (string, int32) {
+	var names []string
+	var dataCategoryID int32
+
+	for code != "" {
+		var c *Category
+		for i := range t.Categories {
+			if t.Categories[i].Code == code {
+				c = &t.Categories[i]
+				break
+			}
+		}
+		if c == nil {
+			break
+		}
+
+		name := c.Name
+		if name == "" {
+			name = c.Code
+		}
+		names = append([]string{name}, names...)
+
+		if dataCategoryID == 0 {
+			dataCategoryID = c.DataCategoryID
+		}
+
+		code = c.Parent
+	}
+
+	return strings.Join(names, CategorySeparator), dataCategoryID
+}
+
+// DesiredSegments returns segment definitions with category codes replaced by category paths.
+func (t *Taxonomy) DesiredSegments() *Desired {
+	d := &Desired{MemberID: t.MemberID}
+
+	for _, seg := range t.Segments {
+		if seg.Category != "" {
+			seg.Category, _ = t.categoryPath(seg.Category)
+		}
+		d.Segments = append(d.Segments, seg)
+	}
+
+	return d
+}
+
+// PriceAction creates or updates segment pricing.
+type PriceAction struct {
+	Type     ActionType
+	Code     string
+	Billing  BillingCategory
+	OldPrice float64
+}
+
+// TaxonomyPlan contains segment and pricing changes.
+type TaxonomyPlan struct {
+	Segments *Plan
+	Prices   []PriceAction
+}
+
+// PlanTaxonomy validates the taxonomy and compares it with current segments and pricing.
+func (c *Client) PlanTaxonomy(ctx context.Context, t *Taxonomy) (*TaxonomyPlan, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	segments, err := c.ListAll(ctx, t.MemberID)
+	if err != nil {
+		return nil, err
+	}
+
+	segPlan, err := MakePlan(t.DesiredSegments(), segments)
+	if err != nil {
+		return nil, err
+	}
+
+	prices, err := c.planPrices(ctx, t, segments)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TaxonomyPlan{Segments: segPlan, Prices: prices}, nil
+}
+
+// planPrices compares taxonomy pricing with current billing categories of the segments.
+// Prices of segments which do not exist yet are created with zero SegmentID.
+func (c *Client) planPrices(ctx context.Context, t *Taxonomy, segments []Segment) ([]PriceAction, error) {
+	ids := make(map[string]int32)
+	for _, seg := range segments {
+		ids[seg.Code] = seg.ID
+	}
+
+	current, err := c.ListBillingCategories(ctx, t.MemberID)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ segmentID, providerID int32 }
+
+	billing := make(map[key]BillingCategory)
+	for _, bc := range current {
+		billing[key{bc.SegmentID, bc.DataProviderID}] = bc
+	}
+
+	categories := make(map[string]string)
+	for _, seg := range t.Segments {
+		categories[seg.Code] = seg.Category
+	}
+
+	var actions []PriceAction
+
+	for _, sp := range t.Pricing {
+		_, dataCategoryID := t.categoryPath(categories[sp.Code])
+
+		for _, p := range sp.Prices {
+			bc := BillingCategory{
+				SegmentID:        ids[sp.Code],
+				DataProviderID:   p.DataProviderID,
+				DataCategoryID:   dataCategoryID,
+				DataSegmentPrice: p.CPM,
+				IsPublic:         p.Public,
+				Active:           true,
+			}
+
+			cur, ok := billing[key{bc.SegmentID, bc.DataProviderID}]
+			switch {
+			case !ok || bc.SegmentID == 0:
+				actions = append(actions, PriceAction{Type: ActionCreate, Code: sp.Code, Billing: bc})
+			case cur.DataSegmentPrice != bc.DataSegmentPrice || cur.IsPublic != bc.IsPublic ||
+				cur.DataCategoryID != bc.DataCategoryID || !cur.Active:
+				bc.ID = cur.ID
+				actions = append(actions, PriceAction{Type: ActionUpdate, Code: sp.Code, Billing: bc, OldPrice: cur.DataSegmentPrice})
+			}
+		}
+	}
+
+	return actions, nil
+}
+
+// Empty reports whether segments and pricing are up to date.
+func (tp *TaxonomyPlan) Empty() bool {
+	return tp.Segments.Empty() && len(tp.Prices) == 0
+}
+
+// WriteText writes segment plan followed by pricing changes.
+func (tp *TaxonomyPlan) WriteText(w io.Writer) error {
+	if err := tp.Segments.WriteText(w); err != nil {
+		return err
+	}
+
+	for _, a := range tp.Prices {
+		switch a.Type {
+		case ActionCreate:
+			fmt.Fprintf(w, "+ price %s provider %d: %.2f\n", a.Code, a.Billing.DataProviderID, a.Billing.DataSegmentPrice)
+		case ActionUpdate:
+			fmt.Fprintf(w, "~ price %s provider %d: %.2f -> %.2f\n", a.Code, a.Billing.DataProviderID, a.OldPrice, a.Billing.DataSegmentPrice)
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "pricing: %d to create, %d to update\n", countPrices(tp.Prices, ActionCreate), countPrices(tp.Prices, ActionUpdate))
+
+	return err
+}
+
+func countPrices(list []PriceAction, t ActionType) int {
+	n := 0
+	for _, a := range list {
+		if a.Type == t {
+			n++
+		}
+	}
+	return n
+}
+
+// ApplyTaxonomy applies segment changes first and then pricing of the plan.
+// Prices of segments created by the plan get IDs of the created segments.
+func (c *Client) ApplyTaxonomy(ctx context.Context, t *Taxonomy, plan *TaxonomyPlan) error {
+	if err := c.Apply(ctx, plan.Segments); err != nil {
+		return err
+	}
+
+	created, err := c.createdIDs(ctx, t.MemberID, plan)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range plan.Prices {
+		bc := a.Billing
+
+		if bc.SegmentID == 0 {
+			bc.SegmentID = created[a.Code]
+		}
+
+		if bc.SegmentID == 0 {
+			return fmt.Errorf("price %s: segment does not exist", a.Code)
+		}
+
+		switch a.Type {
+		case ActionCreate:
+			_, err = c.CreateBillingCategory(ctx, t.MemberID, &bc)
+		case ActionUpdate:
+			_, err = c.UpdateBillingCategory(ctx, t.MemberID, &bc)
+		}
+
+		if err != nil {
+			return fmt.Errorf("price %s: %w", a.Code, err)
+		}
+	}
+
+	return nil
+}
+
+// createdIDs returns IDs of segments created by the plan which have prices to create.
+// Segments are listed only if there are such prices.
+func (c *Client) createdIDs(ctx context.Context, memberID int32, plan *TaxonomyPlan) (map[string]int32, error) {
+	created := make(map[string]bool)
+	for _, a := range plan.Segments.Actions {
+		if a.Type == ActionCreate {
+			created[a.Code] = true
+		}
+	}
+
+	needed := false
+	for _, a := range plan.Prices {
+		if a.Billing.SegmentID == 0 && created[a.Code] {
+			needed = true
+		}
+	}
+
+	ids := make(map[string]int32)
+	if !needed {
+		return ids, nil
+	}
+
+	segments, err := c.ListAll(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seg := range segments {
+		if created[seg.Code] {
+			ids[seg.Code] = seg.ID
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,122 @@
+package segment_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/milla-v/xandr/segment"
+)
+
+func newTaxonomy() *segment.Taxonomy {
+	return &segment.Taxonomy{
+		Desired: segment.Desired{
+			MemberID: 7,
+			Segments: []segment.Segment{
+				{Code: "sedan", ShortName: "Sedan intenders", Category: "cars"},
+				{Code: "paris", ShortName: "Paris travelers", Category: "travel"},
+			},
+		},
+		Categories: []segment.Category{
+			{Code: "intent", Name: "Intent", DataCategoryID: 11},
+			{Code: "cars", Name: "Cars", Parent: "intent"},
+			{Code: "travel", Name: "Travel", Parent: "intent", DataCategoryID: 12},
+		},
+		Pricing: []segment.SegmentPricing{
+			{Code: "sedan", Prices: []segment.Price{{DataProviderID: 5, CPM: 1.5}}},
+			{Code: "paris", Prices: []segment.Price{{DataProviderID: 5, CPM: 2}, {DataProviderID: 6, CPM: 2.5}}},
+		},
+	}
+}
+
+func TestTaxonomyValidate(t *testing.T) {
+	tax := newTaxonomy()
+	if err := tax.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tax.Categories[0].Parent = "travel"
+	err := tax.Validate()
+	if err == nil || err.Error() != "category cycle: intent -> travel -> intent" {
+		t.Fatal("invalid error:", err)
+	}
+
+	tax = newTaxonomy()
+	tax.Categories[1].Parent = "auto"
+	err = tax.Validate()
+	if err == nil || err.Error() != "category cars has orphaned parent auto" {
+		t.Fatal("invalid error:", err)
+	}
+
+	tax = newTaxonomy()
+	tax.Pricing[0].Code = "suv"
+	err = tax.Validate()
+	if err == nil || err.Error() != "pricing of unknown segment suv" {
+		t.Fatal("invalid error:", err)
+	}
+}
+
+func TestTaxonomyUpload(t *testing.T) {
+	c, srv := newClient(t)
+	ctx := context.Background()
+
+	tax := newTaxonomy()
+
+	plan, err := c.PlanTaxonomy(ctx, tax)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.Segments.Count(segment.ActionCreate) != 2 || len(plan.Prices) != 3 {
+		var out bytes.Buffer
+		plan.WriteText(&out)
+		t.Fatal("invalid plan:\n" + out.String())
+	}
+
+	if err := c.ApplyTaxonomy(ctx, tax, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	segments := srv.Segments()
+	if len(segments) != 2 || segments[0].Category != "Intent > Cars" {
+		t.Fatalf("invalid segments: %+v", segments)
+	}
+
+	billing := srv.BillingCategories()
+	if len(billing) != 3 || billing[0].SegmentID != segments[0].ID || billing[0].DataCategoryID != 11 {
+		t.Fatalf("invalid billing categories: %+v", billing)
+	}
+
+	tax.Pricing[0].Prices[0].CPM = 1.75
+
+	plan, err = c.PlanTaxonomy(ctx, tax)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := plan.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = `plan: 0 to create, 0 to update, 0 to deactivate
+~ price sedan provider 5: 1.50 -> 1.75
+pricing: 0 to create, 1 to update
+`
+
+	if out.String() != expected {
+		t.Fatal("\nexpected:\n" + expected + "\nactual:\n" + out.String())
+	}
+
+	// the reviewed plan is applied even if the taxonomy changes after planning
+	tax.Pricing[0].Prices[0].CPM = 9.99
+
+	if err := c.ApplyTaxonomy(ctx, tax, plan); err != nil {
+		t.Fatal(err)
+	}
+
+	billing = srv.BillingCategories()
+	if len(billing) != 3 || billing[0].DataSegmentPrice != 1.75 {
+		t.Fatalf("invalid billing categories: %+v", billing)
+	}
+}
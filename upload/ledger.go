@@ -0,0 +1,187 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Ledger entry statuses.
+const (
+	StatusPending  = "pending"  // job is created, the upload result is unknown
+	StatusUploaded = "uploaded" // content is uploaded
+	StatusFailed   = "failed"   // upload or processing failed, the content can be uploaded again
+)
+
+// ErrAlreadyUploaded is returned by Uploader for content found in the ledger.
+var ErrAlreadyUploaded = errors.New("content is already uploaded")
+
+// LedgerEntry records an upload attempt of content.
+type LedgerEntry struct {
+	Time     time.Time `json:"time"`
+	MemberID int32     `json:"member_id"`
+	Hash     string    `json:"hash"` // SHA-256 of the content in hex
+	Size     int64     `json:"size"`
+	Name     string    `json:"name"`
+	JobID    string    `json:"job_id,omitempty"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Forced   bool      `json:"forced,omitempty"`
+}
+
+type ledgerKey struct {
+	memberID int32
+	hash     string
+}
+
+// Ledger is an append-only file of upload attempts. Every entry is synced to disk.
+// Incomplete last entry left by a crash is discarded on open.
+type Ledger struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries []LedgerEntry
+	latest  map[ledgerKey]int // index of the latest entry of the content
+}
+
+// OpenLedger opens or creates the ledger file.
+func OpenLedger(name string) (*Ledger, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Ledger{f: f, latest: make(map[ledgerKey]int)}
+
+	br := bufio.NewReader(f)
+	var valid int64
+
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		var e LedgerEntry
+		if err := json.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: entry %d: %w", name, n, err)
+		}
+
+		l.add(e)
+		valid += int64(len(line))
+	}
+
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (l *Ledger) add(e LedgerEntry) {
+	l.latest[ledgerKey{e.MemberID, e.Hash}] = len(l.entries)
+	l.entries = append(l.entries, e)
+}
+
+// Find returns the latest entry of the content uploaded for the member.
+func (l *Ledger) Find(memberID int32, hash string) (LedgerEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i, ok := l.latest[ledgerKey{memberID, hash}]
+	if !ok {
+		return LedgerEntry{}, false
+	}
+
+	return l.entries[i], true
+}
+
+// Record appends the entry. Zero time is set to the current time.
+func (l *Ledger) Record(e LedgerEntry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	buf, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.f.Write(append(buf, '\n')); err != nil {
+		return err
+	}
+
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+
+	l.add(e)
+
+	return nil
+}
+
+// History returns all entries in the order they were recorded.
+func (l *Ledger) History() []LedgerEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LedgerEntry(nil), l.entries...)
+}
+
+// Close closes the ledger file.
+func (l *Ledger) Close() error {
+	return l.f.Close()
+}
+
+// WriteHistory writes entries as a table.
+func WriteHistory(w io.Writer, entries []LedgerEntry) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "time\tmember\tname\tsize\thash\tjob\tstatus\n")
+	for _, e := range entries {
+		status := e.Status
+		if e.Forced {
+			status += " (forced)"
+		}
+		if e.Error != "" {
+			status += ": " + e.Error
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%.12s\t%s\t%s\n",
+			e.Time.Format(time.RFC3339), e.MemberID, e.Name, e.Size, e.Hash, e.JobID, status)
+	}
+
+	return tw.Flush()
+}
+
+// ContentHash returns SHA-256 of the content in hex and its size.
+func ContentHash(r io.Reader) (string, int64, error) {
+	h := sha256.New()
+
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
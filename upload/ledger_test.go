@@ -0,0 +1,100 @@
+package upload_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/upload"
+	"github.com/milla-v/xandr/upload/uploadtest"
+)
+
+func TestUploaderLedger(t *testing.T) {
+	srv := uploadtest.NewServer()
+	defer srv.Close()
+
+	s := api.NewSession(srv.URL)
+	ctx := context.Background()
+
+	if err := s.Login(ctx, "user", uploadtest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "ledger.jsonl")
+
+	ledger, err := upload.OpenLedger(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &upload.Uploader{Client: upload.NewClient(s), MemberID: 7, Ledger: ledger}
+
+	const removals = "1234567890:#100\n"
+
+	srv.FailUploads(1)
+
+	if _, err := u.Upload(ctx, "removals.txt", strings.NewReader(removals)); err == nil {
+		t.Fatal("expected upload error")
+	}
+
+	// failed content can be uploaded again
+	jobID, err := u.Upload(ctx, "removals.txt", strings.NewReader(removals))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prevJobID, err := u.Upload(ctx, "retry.txt", strings.NewReader(removals))
+	if !errors.Is(err, upload.ErrAlreadyUploaded) || prevJobID != jobID {
+		t.Fatal("expected already uploaded error, got", prevJobID, err)
+	}
+
+	// other member can upload the same content
+	other := &upload.Uploader{Client: u.Client, MemberID: 8, Ledger: ledger}
+	if _, err := other.Upload(ctx, "removals.txt", strings.NewReader(removals)); err != nil {
+		t.Fatal(err)
+	}
+
+	u.Force = true
+	if _, err := u.Upload(ctx, "retry.txt", strings.NewReader(removals)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(srv.Uploads()); n != 3 {
+		t.Fatal("unexpected number of uploads:", n)
+	}
+
+	ledger.Close()
+
+	// incomplete entry left by a crash
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2023-05`)
+	f.Close()
+
+	ledger, err = upload.OpenLedger(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	var statuses []string
+	for _, e := range ledger.History() {
+		statuses = append(statuses, e.Status)
+	}
+
+	expected := "pending failed pending uploaded pending uploaded pending uploaded"
+	if strings.Join(statuses, " ") != expected {
+		t.Fatal("unexpected history:", statuses)
+	}
+
+	hash, _, _ := upload.ContentHash(strings.NewReader(removals))
+	if e, ok := ledger.Find(7, hash); !ok || !e.Forced || e.Status != upload.StatusUploaded {
+		t.Fatalf("unexpected latest entry: %+v", e)
+	}
+}
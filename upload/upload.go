@@ -0,0 +1,319 @@
+// Package upload implements client of Xandr Batch Segment Service
+// described on https://learn.microsoft.com/en-us/xandr/digital-platform-api/batch-segment-service
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/metrics"
+)
+
+// Upload job phases.
+const (
+	PhaseStarting   = "starting"
+	PhaseUploading  = "uploading"
+	PhaseValidating = "validating"
+	PhaseProcessing = "processing"
+	PhaseCompleted  = "completed"
+)
+
+// DefaultPollInterval is used by Wait when Client.PollInterval is not set.
+const DefaultPollInterval = 30 * time.Second
+
+// ErrJobFailed is returned by Wait when the job completes with an error.
+var ErrJobFailed = errors.New("upload job failed")
+
+// Job is a batch_segment_upload_job.
+type Job struct {
+	ID               int64  `json:"id,omitempty"`
+	JobID            string `json:"job_id"`
+	MemberID         int32  `json:"member_id,omitempty"`
+	UploadURL        string `json:"upload_url,omitempty"`
+	Phase            string `json:"phase,omitempty"`
+	NumValid         int64  `json:"num_valid,omitempty"`
+	NumInvalidFormat int64  `json:"num_invalid_format,omitempty"`
+	NumInvalidUser   int64  `json:"num_invalid_user,omitempty"`
+	ErrorCode        string `json:"error_code,omitempty"`
+	ErrorLogLines    string `json:"error_log_lines,omitempty"`
+	CompletedTime    string `json:"completed_time,omitempty"`
+}
+
+// Client calls Batch Segment Service using authenticated session.
+type Client struct {
+	PollInterval time.Duration
+	Instrument   metrics.Instrument // optional, reports upload durations, bytes and users of completed jobs
+
+	s *api.Session
+}
+
+// NewClient creates upload client sharing the session with other clients.
+func NewClient(s *api.Session) *Client {
+	return &Client{s: s, PollInterval: DefaultPollInterval}
+}
+
+type jobResponse struct {
+	Job *Job `json:"batch_segment_upload_job"`
+}
+
+// CreateJob creates upload job and returns the job with upload URL.
+func (c *Client) CreateJob(ctx context.Context, memberID int32) (*Job, error) {
+	q := url.Values{"member_id": {fmt.Sprint(memberID)}}
+
+	var resp jobResponse
+	if err := c.s.Do(ctx, http.MethodPost, "/batch-segment", q, map[string]interface{}{}, &resp); err != nil {
+		return nil, fmt.Errorf("create upload job: %w", err)
+	}
+
+	if resp.Job == nil || resp.Job.JobID == "" || resp.Job.UploadURL == "" {
+		return nil, errors.New("create upload job: response has no job")
+	}
+
+	return resp.Job, nil
+}
+
+// UploadFile sends BSS file data to the upload URL of the job.
+func (c *Client) UploadFile(ctx context.Context, job *Job, r io.Reader) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, job.UploadURL, r)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Authorization", c.s.Token())
+
+	if c.Instrument != nil && req.Body != nil {
+		// the body is wrapped after the request is created to keep its content length
+		body := &countingBody{ReadCloser: req.Body}
+		req.Body = body
+
+		start := time.Now()
+		defer func() {
+			status := "ok"
+			if err != nil {
+				status = "error"
+			}
+			c.Instrument.Observe(metrics.UploadDuration, time.Since(start), metrics.L("status", status))
+			c.Instrument.Count(metrics.UploadBytes, body.n)
+		}()
+	}
+
+	resp, err := c.s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("upload job %s: %w", job.JobID, err)
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upload job %s: status %s", job.JobID, resp.Status)
+	}
+
+	return nil
+}
+
+// Upload creates upload job and sends the file data.
+func (c *Client) Upload(ctx context.Context, memberID int32, r io.Reader) (*Job, error) {
+	job, err := c.CreateJob(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.UploadFile(ctx, job, r); err != nil {
+		return job, err
+	}
+
+	return job, nil
+}
+
+// Status returns current state of the job.
+func (c *Client) Status(ctx context.Context, memberID int32, jobID string) (*Job, error) {
+	q := url.Values{
+		"member_id": {fmt.Sprint(memberID)},
+		"job_id":    {jobID},
+	}
+
+	var resp jobResponse
+	if err := c.s.Do(ctx, http.MethodGet, "/batch-segment", q, nil, &resp); err != nil {
+		return nil, fmt.Errorf("upload job %s status: %w", jobID, err)
+	}
+
+	if resp.Job == nil {
+		return nil, fmt.Errorf("upload job %s status: response has no job", jobID)
+	}
+
+	return resp.Job, nil
+}
+
+// Wait polls job status until the job is completed.
+func (c *Client) Wait(ctx context.Context, memberID int32, jobID string) (*Job, error) {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	for {
+		job, err := c.Status(ctx, memberID, jobID)
+		if err != nil {
+			return nil, err
+		}
+
+		if job.Phase == PhaseCompleted {
+			c.recordJob(job)
+			if job.ErrorCode != "" {
+				return job, fmt.Errorf("upload job %s: %w: %s", jobID, ErrJobFailed, job.ErrorCode)
+			}
+			return job, nil
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// recordJob reports users of a completed job.
+func (c *Client) recordJob(job *Job) {
+	if c.Instrument == nil {
+		return
+	}
+
+	c.Instrument.Count(metrics.UploadedUsers, job.NumValid, metrics.L("result", "valid"))
+	c.Instrument.Count(metrics.UploadedUsers, job.NumInvalidFormat, metrics.L("result", "invalid_format"))
+	c.Instrument.Count(metrics.UploadedUsers, job.NumInvalidUser, metrics.L("result", "invalid_user"))
+}
+
+// countingBody counts bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.n += int64(n)
+	return n, err
+}
+
+// Uploader uploads export parts of a member, it can be used as export.Uploader.
+// If Ledger is set, content found in the ledger is not uploaded again unless Force is set.
+type Uploader struct {
+	Client   *Client
+	MemberID int32
+	Wait     bool // wait until each job is completed
+	Ledger   *Ledger
+	Force    bool
+}
+
+// Upload uploads the part and returns the upload job ID. For content which is already uploaded
+// it returns the previous job ID and an error wrapping ErrAlreadyUploaded.
+// Readers which are not io.ReadSeeker are read to memory to compute the content hash.
+func (u *Uploader) Upload(ctx context.Context, name string, r io.Reader) (string, error) {
+	if u.Ledger == nil {
+		return u.upload(ctx, name, r)
+	}
+
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		rs = bytes.NewReader(data)
+	}
+
+	hash, size, err := ContentHash(rs)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if prev, ok := u.Ledger.Find(u.MemberID, hash); ok && prev.Status != StatusFailed && !u.Force {
+		return prev.JobID, fmt.Errorf("%s: %w as %s by job %s at %s (%s)",
+			name, ErrAlreadyUploaded, prev.Name, prev.JobID, prev.Time.Format(time.RFC3339), prev.Status)
+	}
+
+	e := LedgerEntry{
+		MemberID: u.MemberID,
+		Hash:     hash,
+		Size:     size,
+		Name:     name,
+		Forced:   u.Force,
+	}
+
+	job, err := u.Client.CreateJob(ctx, u.MemberID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	// the content can be applied even if the upload result is lost
+	e.JobID = job.JobID
+	e.Status = StatusPending
+	if err := u.Ledger.Record(e); err != nil {
+		return job.JobID, err
+	}
+
+	e.Time = time.Time{}
+	e.Status = StatusUploaded
+
+	err = u.Client.UploadFile(ctx, job, rs)
+	switch {
+	case err != nil:
+		e.Status = StatusFailed
+	case u.Wait:
+		// content is delivered even if waiting fails, only failed processing allows uploading it again
+		_, err = u.Client.Wait(ctx, u.MemberID, job.JobID)
+		if errors.Is(err, ErrJobFailed) {
+			e.Status = StatusFailed
+		}
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+		err = fmt.Errorf("%s: %w", name, err)
+	}
+
+	if lerr := u.Ledger.Record(e); lerr != nil && err == nil {
+		err = lerr
+	}
+
+	return job.JobID, err
+}
+
+func (u *Uploader) upload(ctx context.Context, name string, r io.Reader) (string, error) {
+	job, err := u.Client.CreateJob(ctx, u.MemberID)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+
+	return job.JobID, u.uploadJob(ctx, job, name, r)
+}
+
+func (u *Uploader) uploadJob(ctx context.Context, job *Job, name string, r io.Reader) error {
+	if err := u.Client.UploadFile(ctx, job, r); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	if u.Wait {
+		if _, err := u.Client.Wait(ctx, u.MemberID, job.JobID); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package upload_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/milla-v/xandr/api"
+	"github.com/milla-v/xandr/metrics"
+	"github.com/milla-v/xandr/upload"
+	"github.com/milla-v/xandr/upload/uploadtest"
+)
+
+func TestUpload(t *testing.T) {
+	srv := uploadtest.NewServer()
+	defer srv.Close()
+
+	s := api.NewSession(srv.URL)
+	ctx := context.Background()
+
+	if err := s.Login(ctx, "user", uploadtest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	c := upload.NewClient(s)
+	c.PollInterval = time.Millisecond
+
+	job, err := c.Upload(ctx, 7, strings.NewReader("1234567890:100\n1234567891:101\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err = c.Wait(ctx, 7, job.JobID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Phase != upload.PhaseCompleted || job.NumValid != 2 || job.MemberID != 7 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	srv.FailUploads(1)
+
+	if _, err := c.Upload(ctx, 7, strings.NewReader("1234567890:100\n")); err == nil {
+		t.Fatal("expected upload error")
+	}
+
+	if n := len(srv.Uploads()); n != 1 {
+		t.Fatal("unexpected number of uploads:", n)
+	}
+}
+
+func TestUploadInstrument(t *testing.T) {
+	srv := uploadtest.NewServer()
+	defer srv.Close()
+
+	s := api.NewSession(srv.URL)
+	ctx := context.Background()
+
+	if err := s.Login(ctx, "user", uploadtest.Password); err != nil {
+		t.Fatal(err)
+	}
+
+	r := metrics.NewRegistry()
+
+	c := upload.NewClient(s)
+	c.PollInterval = time.Millisecond
+	c.Instrument = r
+
+	const data = "1234567890:100\n1234567891:101\n"
+
+	job, err := c.Upload(ctx, 7, strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Wait(ctx, 7, job.JobID); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.FailUploads(1)
+
+	if _, err := c.Upload(ctx, 7, strings.NewReader(data)); err == nil {
+		t.Fatal("expected upload error")
+	}
+
+	if n := r.Value(metrics.UploadDuration, metrics.L("status", "ok")); n != 1 {
+		t.Fatal("unexpected successful uploads:", n)
+	}
+	if n := r.Value(metrics.UploadDuration, metrics.L("status", "error")); n != 1 {
+		t.Fatal("unexpected failed uploads:", n)
+	}
+	if n := r.Value(metrics.UploadBytes); n != 2*int64(len(data)) {
+		t.Fatal("unexpected uploaded bytes:", n)
+	}
+	if n := r.Value(metrics.UploadedUsers, metrics.L("result", "valid")); n != 2 {
+		t.Fatal("unexpected valid users:", n)
+	}
+}
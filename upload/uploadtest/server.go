@@ -0,0 +1,130 @@
+// Package uploadtest provides a fake Batch Segment Service for testing.
+package uploadtest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/milla-v/xandr/api/apitest"
+	"github.com/milla-v/xandr/upload"
+)
+
+// Password accepted by the fake server.
+const Password = "secret"
+
+// Server is a fake Batch Segment Service. Uploaded jobs are completed immediately.
+type Server struct {
+	*apitest.Server
+
+	mu       sync.Mutex
+	jobs     map[string]*upload.Job
+	data     map[string][]byte
+	order    []string
+	failures int
+}
+
+// NewServer starts fake Batch Segment Service.
+func NewServer() *Server {
+	s := &Server{
+		Server: apitest.NewServer(Password),
+		jobs:   make(map[string]*upload.Job),
+		data:   make(map[string][]byte),
+	}
+
+	s.Handle("/batch-segment", s.handleJob)
+	s.Handle("/upload/", s.handleUpload)
+
+	return s
+}
+
+// FailUploads makes the next n file uploads fail.
+func (s *Server) FailUploads(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// Uploads returns data of uploaded jobs in upload order.
+func (s *Server) Uploads() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list [][]byte
+	for _, id := range s.order {
+		if data, ok := s.data[id]; ok {
+			list = append(list, data)
+		}
+	}
+
+	return list
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	memberID := q.Get("member_id")
+	if memberID == "" {
+		apitest.WriteError(w, http.StatusBadRequest, "SYNTAX", "member_id is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPost:
+		id := fmt.Sprintf("job%d", len(s.order)+1)
+		job := &upload.Job{
+			ID:        int64(len(s.order) + 1),
+			JobID:     id,
+			UploadURL: s.URL + "/upload/" + id,
+			Phase:     upload.PhaseStarting,
+		}
+		fmt.Sscan(memberID, &job.MemberID)
+		s.jobs[id] = job
+		s.order = append(s.order, id)
+		apitest.WriteResponse(w, map[string]interface{}{"batch_segment_upload_job": job})
+
+	case http.MethodGet:
+		job, ok := s.jobs[q.Get("job_id")]
+		if !ok {
+			apitest.WriteError(w, http.StatusNotFound, "NOTFOUND", "job not found")
+			return
+		}
+		apitest.WriteResponse(w, map[string]interface{}{"batch_segment_upload_job": job})
+
+	default:
+		apitest.WriteError(w, http.StatusMethodNotAllowed, "SYNTAX", "method not allowed")
+	}
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/upload/")
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok || r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/octet-stream" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if s.failures > 0 {
+		s.failures--
+		http.Error(w, "upload failed", http.StatusInternalServerError)
+		return
+	}
+
+	s.data[id] = data
+	job.Phase = upload.PhaseCompleted
+	job.NumValid = int64(strings.Count(string(data), "\n"))
+}